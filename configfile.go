@@ -0,0 +1,172 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A configFile is the parts of a plot specification file that aren't flags.
+type configFile struct {
+	inputs []string
+	// plots gives the values of plot flags for each plot, if the
+	// file specifies several plots.
+	plots []map[string]string
+}
+
+// plotFlags lists the flags that may be set separately for each plot. All
+// other flags are shared so that all plots can be produced from one pass over
+// the inputs.
+var plotFlags = []string{
+	"x", "y", "color", "row", "col", "ignore",
+	"log-scale", "geom", "ordinal-x", "break-y", "drop-empty", "direct-labels",
+	"anomalies", "diff", "watermark", "transform", "direction", "highlight",
+	"noisiest", "noise-report", "table", "table-format", "stream", "stream-mean",
+	"o",
+}
+
+// loadConfigFile reads the plot specification in the YAML file at path and
+// applies it to flags. The file is a mapping from flag names, without the
+// leading "-", to values. A sequence value sets a flag that may be repeated
+// once for each element, and sets any other flag to its elements joined with
+// commas.
+//
+// The special key "inputs" gives a list of inputs. The special key "plots"
+// gives a list of plots to produce, each of which is a mapping from the names
+// of plotFlags to values that override the file's values for that plot. Each
+// plot should set "o" to a distinct file.
+//
+// Flags in set, for example those set on the command line, take precedence
+// over the file. loadConfigFile adds the flags it sets to set. The per-plot
+// values are returned as is, and the caller skips those of flags set on the
+// command line.
+func loadConfigFile(path string, flags *flag.FlagSet, set map[string]bool) (cfg configFile, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	var spec map[string]any
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+
+	keys := make([]string, 0, len(spec))
+	for k := range spec {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		if k == "plots" {
+			if cfg.plots, err = configPlots(spec[k]); err != nil {
+				return cfg, fmt.Errorf("%s: %w", path, err)
+			}
+			continue
+		}
+		vals, err := configValues(spec[k])
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %s: %w", path, k, err)
+		}
+		if k == "inputs" {
+			cfg.inputs = vals
+			continue
+		}
+		f := flags.Lookup(k)
+		if f == nil {
+			return cfg, fmt.Errorf("%s: unknown flag %q", path, k)
+		}
+		if set[k] {
+			continue
+		}
+		set[k] = true
+		resetList(f)
+		if _, ok := f.Value.(*stringList); !ok {
+			vals = []string{strings.Join(vals, ",")}
+		}
+		for _, val := range vals {
+			if err := f.Value.Set(val); err != nil {
+				return cfg, fmt.Errorf("%s: invalid value %q for %s: %w", path, val, k, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// configPlots converts the "plots" value from a config file to a list of plot
+// flag values.
+func configPlots(v any) ([]map[string]string, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("plots: expected a sequence")
+	}
+	var plots []map[string]string
+	for i, elt := range list {
+		m, ok := elt.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("plot %d: expected a mapping", i+1)
+		}
+		plot := make(map[string]string)
+		for k, v := range m {
+			if !slices.Contains(plotFlags, k) {
+				return nil, fmt.Errorf("plot %d: flag %q cannot be set per plot", i+1, k)
+			}
+			vals, err := configValues(v)
+			if err != nil {
+				return nil, fmt.Errorf("plot %d: %s: %w", i+1, k, err)
+			}
+			plot[k] = strings.Join(vals, ",")
+		}
+		plots = append(plots, plot)
+	}
+	return plots, nil
+}
+
+// withFlags calls f with the flags named in vals temporarily set to the given
+// values. The flags must not be repeatable.
+func withFlags(flags *flag.FlagSet, vals map[string]string, f func() error) error {
+	old := make(map[string]string)
+	defer func() {
+		for name, val := range old {
+			flags.Set(name, val)
+		}
+	}()
+	for name, val := range vals {
+		old[name] = flags.Lookup(name).Value.String()
+		if err := flags.Set(name, val); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", val, name, err)
+		}
+	}
+	return f()
+}
+
+// configValues converts a scalar or sequence value from a config file to a
+// list of flag values.
+func configValues(v any) ([]string, error) {
+	switch v := v.(type) {
+	case []any:
+		vals := make([]string, 0, len(v))
+		for _, elt := range v {
+			val, err := configValues(elt)
+			if err != nil {
+				return nil, err
+			}
+			if len(val) != 1 {
+				return nil, fmt.Errorf("nested sequences are not allowed")
+			}
+			vals = append(vals, val[0])
+		}
+		return vals, nil
+	case map[string]any:
+		return nil, fmt.Errorf("expected a scalar or sequence")
+	case nil:
+		return []string{""}, nil
+	}
+	return []string{fmt.Sprint(v)}, nil
+}
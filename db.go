@@ -0,0 +1,72 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aclements/benchplot/internal/store"
+	"golang.org/x/perf/benchfmt"
+)
+
+// A recordSource is a sequence of benchmark records, such as [input.Files] or
+// a [store.Reader].
+type recordSource interface {
+	Scan() bool
+	Result() benchfmt.Record
+	Err() error
+	Units() benchfmt.UnitMetadataMap
+	// Close stops reading the records. Once a caller is done with a
+	// source, even if Scan hasn't returned false, it must call Close.
+	Close()
+}
+
+// parseWhere parses a -where option of the form "key=value,...".
+func parseWhere(opt string) (map[string]string, error) {
+	if opt == "" {
+		return nil, nil
+	}
+	where := make(map[string]string)
+	for _, kv := range strings.Split(opt, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("bad -where %q: expected key=value,...", opt)
+		}
+		where[k] = v
+	}
+	return where, nil
+}
+
+// importInputs adds the records from src that pass in's filters to db.
+func importInputs(db *store.DB, src recordSource, in *ingester, wErr io.Writer) error {
+	in.reset()
+	imp, err := db.Import()
+	if err != nil {
+		return err
+	}
+	for src.Scan() {
+		if rec := in.record(src.Result()); rec != nil {
+			if err := imp.Add(rec); err != nil {
+				imp.Rollback()
+				return err
+			}
+		}
+	}
+	if err := src.Err(); err != nil {
+		imp.Rollback()
+		return err
+	}
+	if err := in.check(); err != nil {
+		imp.Rollback()
+		return err
+	}
+	if err := imp.Commit(src.Units()); err != nil {
+		return err
+	}
+	fmt.Fprintf(wErr, "imported %d records\n", imp.Len())
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// defaultsEnv is the environment variable giving default flags.
+const defaultsEnv = "BENCHPLOT_FLAGS"
+
+// defaultsPath returns the path of the user's default flags file, or "" if
+// there is no user configuration directory.
+func defaultsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "benchplot", "config")
+}
+
+// applyDefaults sets flags from the user's default flags file and then from
+// the defaultsEnv environment variable, so the environment takes precedence.
+// Flags in set, which were set on the command line, are left alone. Flags set
+// here are not added to set, so a config file or preset may still override
+// them. Overriding a repeatable flag such as -vline replaces all of its values.
+//
+// The file contains one flag per line, written as on the command line, such as
+// "-o plots/out.png" or "-log-scale=y". A value may contain spaces. Blank lines
+// and lines starting with "#" are ignored. The environment variable contains
+// flags separated by spaces.
+func applyDefaults(flags *flag.FlagSet, set map[string]bool) error {
+	if path := defaultsPath(); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		var args []string
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			// Join the flag and its value so the value may contain spaces.
+			if i := strings.IndexFunc(line, unicode.IsSpace); i >= 0 && !strings.Contains(line[:i], "=") {
+				line = line[:i] + "=" + strings.TrimSpace(line[i:])
+			}
+			args = append(args, line)
+		}
+		if err := setDefaults(flags, set, args); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if env := os.Getenv(defaultsEnv); env != "" {
+		if err := setDefaults(flags, set, strings.Fields(env)); err != nil {
+			return fmt.Errorf("%s: %w", defaultsEnv, err)
+		}
+	}
+	return nil
+}
+
+// setDefaults parses args as flags and sets those that are not in set.
+func setDefaults(flags *flag.FlagSet, set map[string]bool, args []string) error {
+	// Parse args with a mirror of flags that records each flag, so flags
+	// are parsed exactly as on the command line.
+	var settings []defaultSetting
+	mirror := flag.NewFlagSet("", flag.ContinueOnError)
+	mirror.SetOutput(io.Discard)
+	flags.VisitAll(func(f *flag.Flag) {
+		bf, isBool := f.Value.(interface{ IsBoolFlag() bool })
+		mirror.Var(&defaultRecorder{f.Name, isBool && bf.IsBoolFlag(), &settings}, f.Name, f.Usage)
+	})
+	if err := mirror.Parse(args); err != nil {
+		return err
+	}
+	if mirror.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %s", mirror.Arg(0))
+	}
+	reset := make(map[string]bool)
+	for _, s := range settings {
+		if set[s.name] {
+			continue
+		}
+		if !reset[s.name] {
+			// Replace any values from an earlier source.
+			resetList(flags.Lookup(s.name))
+			reset[s.name] = true
+		}
+		if err := flags.Set(s.name, s.val); err != nil {
+			return fmt.Errorf("invalid value %q for -%s: %w", s.val, s.name, err)
+		}
+	}
+	return nil
+}
+
+type defaultSetting struct {
+	name, val string
+}
+
+// A defaultRecorder is a flag.Value that records each time it is set.
+type defaultRecorder struct {
+	name     string
+	isBool   bool
+	settings *[]defaultSetting
+}
+
+func (r *defaultRecorder) String() string { return "" }
+
+func (r *defaultRecorder) Set(val string) error {
+	*r.settings = append(*r.settings, defaultSetting{r.name, val})
+	return nil
+}
+
+func (r *defaultRecorder) IsBoolFlag() bool { return r.isBool }
@@ -0,0 +1,111 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aclements/benchplot/plot"
+	"golang.org/x/perf/benchfmt"
+)
+
+// export writes the records from src that in accepts to w in the Go benchmark
+// format, followed by their unit metadata. This applies the same filtering
+// and rewriting as plotting does, so the output can be plotted, or given to
+// other tools such as benchstat, without repeating those flags.
+func export(w io.Writer, src recordSource, in *ingester) error {
+	in.reset()
+	bw := benchfmt.NewWriter(w)
+	for src.Scan() {
+		rec := in.record(src.Result())
+		if rec == nil {
+			continue
+		}
+		// Drop internal keys such as .filetime. Those that benchfmt sets,
+		// such as .file, are not file configuration, so the Writer omits
+		// them anyway. rec may be reused by src, so modify a copy.
+		if slices.ContainsFunc(rec.Config, isInternalConfig) {
+			rec = rec.Clone()
+			for _, cfg := range slices.Clone(rec.Config) {
+				if isInternalConfig(cfg) {
+					rec.SetConfig(cfg.Key, "")
+				}
+			}
+		}
+		if err := bw.Write(rec); err != nil {
+			return err
+		}
+	}
+	if err := src.Err(); err != nil {
+		return err
+	}
+	units := in.units(src.Units())
+	if err := in.check(); err != nil {
+		return err
+	}
+
+	// units records metadata under both tidied and canonical units, but
+	// these share the original unit, so write each only once.
+	type key struct{ unit, key string }
+	seen := make(map[key]bool)
+	var metas []*benchfmt.UnitMetadata
+	for _, m := range units {
+		k := key{m.OrigUnit, m.Key}
+		if !seen[k] {
+			seen[k] = true
+			metas = append(metas, m)
+		}
+	}
+	slices.SortFunc(metas, func(a, b *benchfmt.UnitMetadata) int {
+		if c := strings.Compare(a.OrigUnit, b.OrigUnit); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	for _, m := range metas {
+		if err := bw.Write(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isInternalConfig reports whether cfg is a configuration key added by
+// benchplot, rather than read from an input.
+func isInternalConfig(cfg benchfmt.Config) bool {
+	return strings.HasPrefix(cfg.Key, ".")
+}
+
+// layoutCSV is a [plot.Renderer] that writes the points of a plot as CSV, one
+// row per point, with the values scaled as they are drawn. The columns give
+// the facet row and column and the color of each point, the label of each
+// axis, which includes the unit and whether it's exact, the X and Y values,
+// and the bounds of the confidence interval of Y, if there is one.
+type layoutCSV struct{}
+
+func (layoutCSV) Render(l *plot.Layout, out io.Writer) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"row", "col", "color", "x-label", "x", "y-label", "y", "y-lo", "y-hi"})
+	format := func(v float64) string {
+		if math.IsNaN(v) {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	for _, f := range l.Facets {
+		for _, s := range f.Series {
+			for _, m := range s.Marks {
+				w.Write([]string{f.RowLabel, f.ColLabel, s.Label, f.X.Label, format(m.X), f.Y.Label, format(m.Y), format(m.Lo), format(m.Hi)})
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aclements/benchplot/plot"
+	"golang.org/x/perf/benchfmt"
+)
+
+// followDelay is the minimum time between plot updates in follow mode.
+const followDelay = 500 * time.Millisecond
+
+// followFormats lists the input formats that can be read as they are written.
+// The others are documents that are parsed all at once.
+var followFormats = []string{"benchfmt", "gotest-json", "csv"}
+
+// follow reads results from src, which reads a single input as it is written,
+// and shows the plot in a gnuplot window, updating it as results arrive. If
+// the input is stdin, follow keeps the window open after the input ends until
+// the user closes it. Otherwise, it follows the file until interrupted.
+// gnuplot is the gnuplot binary to display the plot with, or "" to find it.
+func follow(src recordSource, config *plot.Config, gnuplot string, in *ingester, finish func(*plot.Plot) error, wErr io.Writer) error {
+	// Parse the input in the background so we can update the plot
+	// while waiting for more input.
+	type batch struct {
+		recs []benchfmt.Record
+		err  error
+		eof  bool
+	}
+	batches := make(chan batch, 1)
+	go func() {
+		defer src.Close()
+		var b batch
+		send := func() {
+			batches <- b
+			b = batch{}
+		}
+		for src.Scan() {
+			// The source may reuse Results, so we have to clone
+			// them.
+			rec := src.Result()
+			if res, ok := rec.(*benchfmt.Result); ok {
+				rec = res.Clone()
+			}
+			b.recs = append(b.recs, rec)
+			select {
+			case batches <- b:
+				b = batch{}
+			default:
+				// The previous batch hasn't been consumed. Keep
+				// accumulating this one.
+			}
+		}
+		b.err, b.eof = src.Err(), true
+		send()
+	}()
+
+	win, err := plot.NewGnuplotWindow(gnuplot)
+	if err != nil {
+		return err
+	}
+
+	// all accumulates every result. Each update renders a transformed
+	// clone of it, so the results don't have to be added again.
+	all, err := plot.NewPlot(config)
+	if err != nil {
+		win.Close()
+		return err
+	}
+	added := false
+	units := make(benchfmt.UnitMetadataMap)
+	in.reset()
+	update := func() error {
+		if !added {
+			return nil
+		}
+		pl := all.Clone()
+		pl.SetUnits(in.units(units))
+		if err := finish(pl); err != nil {
+			return err
+		}
+		return win.Show(pl)
+	}
+
+	var last time.Time
+	var timer <-chan time.Time
+	for {
+		select {
+		case b := <-batches:
+			for _, rec := range b.recs {
+				if um, ok := rec.(*benchfmt.UnitMetadata); ok {
+					units[um.UnitMetadataKey] = um
+				} else if res := in.record(rec); res != nil {
+					all.Add(res)
+					added = true
+				}
+			}
+			if b.eof {
+				if b.err != nil {
+					win.Close()
+					return b.err
+				}
+				if err := in.check(); err != nil {
+					win.Close()
+					return err
+				}
+				if err := update(); err != nil {
+					win.Close()
+					return err
+				}
+				return win.Wait()
+			}
+			if timer == nil {
+				timer = time.After(max(0, followDelay-time.Since(last)))
+			}
+		case <-timer:
+			timer, last = nil, time.Now()
+			if err := update(); err != nil {
+				// The data may just be incomplete.
+				fmt.Fprintf(wErr, "%s\n", err)
+			}
+		}
+	}
+}
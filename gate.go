@@ -0,0 +1,54 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aclements/benchplot/plot"
+)
+
+// checkRegressions prints each change in changes that is a significant
+// regression by more than percent to w, and returns an error if there are
+// any.
+func checkRegressions(w io.Writer, changes []plot.Change, percent float64) error {
+	n := 0
+	for _, c := range changes {
+		if !c.Regression(percent / 100) {
+			continue
+		}
+		n++
+		fmt.Fprintf(w, "regression: %s: %+.1f%% vs %s (p=%.3f)\n", pointString(c.Point), (c.Ratio-1)*100, c.Baseline, c.P)
+	}
+	if n > 0 {
+		return fmt.Errorf("%s larger than %g%%", plural(n, "significant regression"), percent)
+	}
+	return nil
+}
+
+// reportAnomalies prints each anomaly in anomalies to w.
+func reportAnomalies(w io.Writer, anomalies []plot.Anomaly) {
+	for _, a := range anomalies {
+		unit := ""
+		if a.Unit != "" {
+			unit = " " + a.Unit
+		}
+		fmt.Fprintf(w, "anomaly: %s: %.4g%s vs %.4g expected (%+.1fσ)\n", pointString(a.Point), a.Value, unit, a.Expected, a.Sigma)
+	}
+}
+
+// pointString formats the aesthetic values of a point, as given by a Change or
+// Anomaly, in the order of their flags.
+func pointString(point map[string]string) string {
+	var labels []string
+	for _, f := range aesFlags {
+		if v := point[f.aes.Name()]; v != "" {
+			labels = append(labels, f.aes.Name()+"="+v)
+		}
+	}
+	return strings.Join(labels, " ")
+}
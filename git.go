@@ -0,0 +1,119 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aclements/benchplot/internal/input"
+	"golang.org/x/perf/benchfmt"
+)
+
+// A gitHistory is the commits of a git repository, for resolving the commit
+// hashes in a configuration key into their order in the history and their
+// dates. Unlike the hashes, these can be plotted. It also resolves them into
+// short labels, for the tick marks of an ordinal axis.
+type gitHistory struct {
+	key     string
+	hashes  []string    // Sorted
+	commits []gitCommit // Commit with each hash
+}
+
+type gitCommit struct {
+	order int    // Position in topological order, from 0 for the oldest
+	date  int64  // Commit time, in Unix seconds
+	ref   string // A tag of the commit, or else its short hash
+}
+
+// shortHash is the number of digits of a short commit hash.
+const shortHash = 7
+
+// loadGitHistory reads the history of every branch of the git repository in
+// dir, for resolving the commit hashes in configuration key.
+func loadGitHistory(dir, key string) (*gitHistory, error) {
+	out, err := exec.Command("git", "-C", dir, "log", "--all", "--topo-order", "--reverse", "--format=%H %ct %D").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("reading git history of %s: %s", dir, bytes.TrimSpace(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("reading git history of %s: %w", dir, err)
+	}
+
+	type entry struct {
+		hash   string
+		commit gitCommit
+	}
+	var entries []entry
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		hash, rest, _ := strings.Cut(line, " ")
+		dateStr, refs, _ := strings.Cut(rest, " ")
+		date, err := strconv.ParseInt(dateStr, 10, 64)
+		if err != nil || len(hash) < shortHash {
+			return nil, fmt.Errorf("reading git history of %s: bad log line %q", dir, line)
+		}
+		ref := hash[:shortHash]
+		for _, r := range strings.Split(refs, ", ") {
+			if tag, ok := strings.CutPrefix(r, "tag: "); ok {
+				ref = tag
+				break
+			}
+		}
+		entries = append(entries, entry{hash, gitCommit{len(entries), date, ref}})
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		return strings.Compare(a.hash, b.hash)
+	})
+
+	h := &gitHistory{key: key}
+	for _, e := range entries {
+		h.hashes = append(h.hashes, e.hash)
+		h.commits = append(h.commits, e.commit)
+	}
+	return h, nil
+}
+
+// lookup returns the commit with hash, which may be abbreviated to any unique
+// prefix of at least four digits, like git accepts.
+func (h *gitHistory) lookup(hash string) (gitCommit, bool) {
+	hash = strings.ToLower(hash)
+	if len(hash) < 4 {
+		return gitCommit{}, false
+	}
+	i, _ := slices.BinarySearch(h.hashes, hash)
+	if i == len(h.hashes) || !strings.HasPrefix(h.hashes[i], hash) {
+		return gitCommit{}, false
+	}
+	if i+1 < len(h.hashes) && strings.HasPrefix(h.hashes[i+1], hash) {
+		// The prefix is ambiguous.
+		return gitCommit{}, false
+	}
+	return h.commits[i], true
+}
+
+// apply adds the configuration keys key-order, key-date, and key-ref to res,
+// giving the order, date, and label of the commit in h's key. It reports false
+// if res has a commit that isn't in h. Like an ingester's prepare, it may be
+// called concurrently.
+func (h *gitHistory) apply(res *benchfmt.Result) bool {
+	pos, ok := res.ConfigIndex(h.key)
+	if !ok {
+		return true
+	}
+	c, ok := h.lookup(string(res.Config[pos].Value))
+	if !ok {
+		return false
+	}
+	input.SetFileConfig(res, h.key+"-order", strconv.Itoa(c.order))
+	input.SetFileConfig(res, h.key+"-date", strconv.FormatInt(c.date, 10))
+	input.SetFileConfig(res, h.key+"-ref", c.ref)
+	return true
+}
@@ -0,0 +1,328 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"golang.org/x/perf/benchfmt"
+	"golang.org/x/perf/benchproc"
+)
+
+// An ingester filters the records read from the inputs and keeps statistics
+// on what it filtered.
+type ingester struct {
+	filter    *benchproc.Filter
+	keepUnits map[string]bool
+	unitsFlag string // For error messages
+
+	extracts  []extract
+	valueMaps []valueMap
+	// commits resolves the commit hashes of results, or is nil.
+	commits *gitHistory
+
+	// better overrides the "better" unit metadata of the inputs.
+	better map[string]string
+
+	// seen is the set of records already read, if deduplicating.
+	seen map[string]struct{}
+	key  strings.Builder
+
+	// fraction is the fraction of records to keep, if sampling. rng
+	// is seeded from seed on each reset so sampling is reproducible.
+	fraction float64
+	seed     uint64
+	rng      *rand.Rand
+
+	// problems lists the syntax errors and filter errors in the
+	// inputs. Normally these are printed as they are encountered. In
+	// strict mode, they are fatal. If summarize is set, they are
+	// printed as a summary by check instead. If errorLog is set, they
+	// are also written to that file.
+	problems  []warning
+	strict    bool
+	summarize bool
+	errorLog  string
+
+	// seenKeys and seenUnits are the sets of configuration keys, name keys
+	// (such as "/size"), and units seen in the inputs, for suggesting
+	// corrections to flags that name ones that don't exist.
+	seenKeys  map[string]bool
+	seenUnits map[string]bool
+
+	warnings *warner
+
+	nParsed, nFiltered, nUnitFiltered, nDup, nSampled int
+	// nUnknownCommit counts the results with commits that aren't in
+	// commits.
+	nUnknownCommit int
+}
+
+// reset clears the statistics collected by in.
+func (in *ingester) reset() {
+	in.nParsed, in.nFiltered, in.nUnitFiltered, in.nDup, in.nSampled = 0, 0, 0, 0, 0
+	in.nUnknownCommit = 0
+	in.problems = in.problems[:0]
+	in.seenKeys = make(map[string]bool)
+	in.seenUnits = make(map[string]bool)
+	if in.seen != nil {
+		clear(in.seen)
+	}
+	if in.rng != nil {
+		in.rng = rand.New(rand.NewPCG(in.seed, 0))
+	}
+}
+
+// sample enables keeping only a random fraction of records, chosen using a
+// generator seeded with seed.
+func (in *ingester) sample(fraction float64, seed uint64) {
+	in.fraction, in.seed = fraction, seed
+	in.rng = rand.New(rand.NewPCG(seed, 0))
+}
+
+// dedup enables dropping records that are identical to an earlier record.
+func (in *ingester) dedup() {
+	in.seen = make(map[string]struct{})
+}
+
+// isDup reports whether rec is identical to a previous record, considering its
+// configuration, name, iteration count, and values.
+func (in *ingester) isDup(rec *benchfmt.Result) bool {
+	b := &in.key
+	b.Reset()
+	for _, cfg := range rec.Config {
+		fmt.Fprintf(b, "%q:%q ", cfg.Key, cfg.Value)
+	}
+	fmt.Fprintf(b, "%q %d", rec.Name, rec.Iters)
+	for _, val := range rec.Values {
+		fmt.Fprintf(b, " %v %q", val.Value, val.Unit)
+	}
+	key := b.String()
+	if _, ok := in.seen[key]; ok {
+		return true
+	}
+	in.seen[key] = struct{}{}
+	return false
+}
+
+// note adds the keys and units of rec to the sets of those seen.
+func (in *ingester) note(rec *benchfmt.Result) {
+	for _, cfg := range rec.Config {
+		if !in.seenKeys[cfg.Key] {
+			in.seenKeys[cfg.Key] = true
+		}
+	}
+	_, parts := rec.Name.Parts()
+	for _, part := range parts {
+		if i := bytes.IndexByte(part, '='); i >= 0 && !in.seenKeys[string(part[:i])] {
+			in.seenKeys[string(part[:i])] = true
+		}
+	}
+	for _, val := range rec.Values {
+		for _, unit := range []string{val.Unit, val.OrigUnit} {
+			if unit != "" && !in.seenUnits[unit] {
+				in.seenUnits[unit] = true
+			}
+		}
+	}
+}
+
+// checkKeys warns about each key used by the projections in keys, which maps
+// from flag names to the keys used in those flags, that did not appear in any
+// input.
+func (in *ingester) checkKeys(keys map[string][]string) {
+	known := sortedKeys(in.seenKeys)
+	for _, flag := range sortedKeys(keys) {
+		for _, key := range keys[flag] {
+			if !in.seenKeys[key] {
+				in.warnings.warn(warning{Kind: "unknown-key", Msg: fmt.Sprintf("-%s: no input has key %s%s", flag, key, didYouMean(key, known))})
+			}
+		}
+	}
+}
+
+// record processes a single record read from the inputs. If rec is a
+// [benchfmt.Result] that passes the filters, it returns the Result, with its
+// values possibly filtered down. Otherwise, it returns nil. rec may also be
+// a *preparedResult from a preparingSource.
+func (in *ingester) record(rec benchfmt.Record) *benchfmt.Result {
+	switch rec := rec.(type) {
+	case *benchfmt.SyntaxError:
+		// Non-fatal result parse error. Warn
+		// but keep going.
+		in.problem(warning{Kind: "syntax", Msg: rec.Msg, File: rec.FileName, Line: rec.Line})
+	case *benchfmt.Result:
+		if in.drop() {
+			return nil
+		}
+		// prepare rewrites the Result, which the reader reuses for
+		// every record.
+		p := in.prepare(rec.Clone())
+		return in.ingest(&p)
+	case *preparedResult:
+		if in.drop() {
+			return nil
+		}
+		return in.ingest(rec)
+	}
+	return nil
+}
+
+// drop counts a parsed result and reports whether sampling drops it.
+func (in *ingester) drop() bool {
+	in.nParsed++
+	if in.rng != nil && in.rng.Float64() >= in.fraction {
+		in.nSampled++
+		return true
+	}
+	return false
+}
+
+// A preparedResult is a Result that has been rewritten by an ingester and
+// matched against its filter, but not yet ingested.
+type preparedResult struct {
+	*benchfmt.Result
+	match    benchproc.Match
+	matchErr error
+	// unknownCommit indicates that the result's commit isn't in the
+	// ingester's git history.
+	unknownCommit bool
+}
+
+// prepare rewrites rec and matches it against the filter. This is the part
+// of processing a record that doesn't depend on the other records, so
+// unlike the rest of in's methods, it may be called concurrently.
+func (in *ingester) prepare(rec *benchfmt.Result) preparedResult {
+	normalizeValues(rec)
+	for _, e := range in.extracts {
+		e.apply(rec)
+	}
+	for _, vm := range in.valueMaps {
+		vm.apply(rec)
+	}
+	known := true
+	if in.commits != nil {
+		known = in.commits.apply(rec)
+	}
+	m, err := in.filter.Match(rec)
+	return preparedResult{rec, m, err, !known}
+}
+
+// ingest finishes processing a result that passed sampling, in the order it
+// was read.
+func (in *ingester) ingest(p *preparedResult) *benchfmt.Result {
+	rec := p.Result
+	in.note(rec)
+	if p.unknownCommit {
+		in.nUnknownCommit++
+	}
+	if in.seen != nil && in.isDup(rec) {
+		in.nDup++
+		return nil
+	}
+	if !p.match.Apply(rec) {
+		in.nFiltered++
+		if p.matchErr != nil {
+			// Report the reason we rejected this result.
+			file, line := rec.Pos()
+			in.problem(warning{Kind: "filter", Msg: p.matchErr.Error(), File: file, Line: line})
+		}
+		return nil
+	}
+	if in.keepUnits != nil {
+		j := 0
+		for _, val := range rec.Values {
+			if in.keepUnits[val.Unit] || (val.OrigUnit != "" && in.keepUnits[val.OrigUnit]) {
+				rec.Values[j] = val
+				j++
+			}
+		}
+		rec.Values = rec.Values[:j]
+		if j == 0 {
+			in.nUnitFiltered++
+			return nil
+		}
+	}
+	return rec
+}
+
+// problem records a problem with the input, printing it unless it will be
+// reported later.
+func (in *ingester) problem(w warning) {
+	in.problems = append(in.problems, w)
+	// Summaries are for people, so JSON warnings always report each
+	// problem.
+	if !in.strict && (!in.summarize || in.warnings.json) {
+		in.warnings.warn(w)
+	}
+}
+
+// units returns the unit metadata to plot, given the metadata read from the
+// inputs.
+func (in *ingester) units(units benchfmt.UnitMetadataMap) benchfmt.UnitMetadataMap {
+	units = normalizeUnitMetadata(units)
+	overrideBetter(units, in.better)
+	return units
+}
+
+// check returns an error if the filters rejected all of the data or, in
+// strict mode, if there were any errors, and otherwise reports how much data
+// was filtered.
+func (in *ingester) check() error {
+	if in.errorLog != "" {
+		if err := writeErrorLog(in.errorLog, in.problems); err != nil {
+			return err
+		}
+	}
+	if in.strict && len(in.problems) > 0 {
+		// No need to sort right now because they're already in order.
+		errs := make(errorsAt, len(in.problems))
+		for i, p := range in.problems {
+			errs[i] = errorAt{p.File, p.Line, errors.New(p.Msg)}
+		}
+		return errs
+	}
+	if in.summarize {
+		if !in.warnings.json {
+			summarizeProblems(in.warnings.w, in.problems)
+		}
+	}
+	if in.nDup > 0 {
+		in.warnings.warn(warning{Kind: "duplicates", Msg: fmt.Sprintf("%d duplicate records removed", in.nDup), Count: in.nDup})
+	}
+	if in.nUnknownCommit > 0 {
+		in.warnings.warn(warning{Kind: "unknown-commit", Msg: fmt.Sprintf("-git-commits: %s with a %s not in the git history", plural(in.nUnknownCommit, "record"), in.commits.key), Count: in.nUnknownCommit})
+	}
+	known := sortedKeys(in.seenUnits)
+	for _, unit := range sortedKeys(in.keepUnits) {
+		if !in.seenUnits[unit] {
+			in.warnings.warn(warning{Kind: "unknown-unit", Msg: fmt.Sprintf("-unit: no input has unit %s%s", unit, didYouMean(unit, known))})
+		}
+	}
+	if in.nParsed == 0 {
+		return fmt.Errorf("no data")
+	} else if in.nUnitFiltered == in.nParsed {
+		return fmt.Errorf("no data has units %s", in.unitsFlag)
+	} else if in.nUnitFiltered+in.nFiltered+in.nDup+in.nSampled == in.nParsed {
+		return fmt.Errorf("all data filtered")
+	}
+	if in.nFiltered > 0 || in.nUnitFiltered > 0 {
+		if !in.warnings.json {
+			fmt.Fprintf(in.warnings.w, "%d records did not match -filter, %d records did not match -unit\n", in.nFiltered, in.nUnitFiltered)
+		} else {
+			if in.nFiltered > 0 {
+				in.warnings.warn(warning{Kind: "filtered", Msg: fmt.Sprintf("%d records did not match -filter", in.nFiltered), Count: in.nFiltered})
+			}
+			if in.nUnitFiltered > 0 {
+				in.warnings.warn(warning{Kind: "unit-filtered", Msg: fmt.Sprintf("%d records did not match -unit", in.nUnitFiltered), Count: in.nUnitFiltered})
+			}
+		}
+	}
+	return nil
+}
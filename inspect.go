@@ -0,0 +1,142 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+
+	"golang.org/x/perf/benchfmt"
+	"golang.org/x/perf/benchmath"
+)
+
+// inspectMaxValues is the number of most common values inspect shows for each
+// key.
+const inspectMaxValues = 10
+
+// A keyCounts counts the distinct values of each key. The counts are pointers
+// so they can be updated without allocating a string for each value.
+type keyCounts map[string]map[string]*int
+
+func (kc keyCounts) add(key string, val []byte) {
+	vals := kc[key]
+	if vals == nil {
+		vals = make(map[string]*int)
+		kc[key] = vals
+	}
+	if n := vals[string(val)]; n != nil {
+		*n++
+		return
+	}
+	n := 1
+	vals[string(val)] = &n
+}
+
+// write prints the keys in kc and their most common values to w.
+func (kc keyCounts) write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, key := range sortedKeys(kc) {
+		vals := kc[key]
+		plural := "s"
+		if len(vals) == 1 {
+			plural = ""
+		}
+		fmt.Fprintf(tw, "  %s\t%d value%s\n", key, len(vals), plural)
+		names := sortedKeys(vals)
+		slices.SortStableFunc(names, func(a, b string) int {
+			return cmp.Compare(*vals[b], *vals[a])
+		})
+		for i, name := range names {
+			if i == inspectMaxValues {
+				fmt.Fprintf(tw, "    ...\t%d more\n", len(names)-i)
+				break
+			}
+			fmt.Fprintf(tw, "    %s\t%d\n", name, *vals[name])
+		}
+	}
+	return tw.Flush()
+}
+
+// inspect reads the records from src that in accepts and prints a summary of
+// them to w: the configuration keys, name keys, and benchmark names with
+// their most common values, and the units.
+func inspect(w io.Writer, src recordSource, in *ingester) error {
+	in.reset()
+	nResults := 0
+	fileKeys, nameKeys := make(keyCounts), make(keyCounts)
+	counts := make(map[string]int) // Unit -> measurements
+	for src.Scan() {
+		rec := in.record(src.Result())
+		if rec == nil {
+			continue
+		}
+		nResults++
+		for _, cfg := range rec.Config {
+			fileKeys.add(cfg.Key, cfg.Value)
+		}
+		inspectName(nameKeys, rec.Name)
+		for _, val := range rec.Values {
+			counts[val.Unit]++
+		}
+	}
+	if err := src.Err(); err != nil {
+		return err
+	}
+	units := in.units(src.Units())
+	if err := in.check(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%d results\n\nconfiguration keys:\n", nResults)
+	if err := fileKeys.write(w); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nname keys:\n")
+	if err := nameKeys.write(w); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nunits:\n")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, unit := range sortedKeys(counts) {
+		var notes string
+		switch units.GetBetter(unit) {
+		case 1:
+			notes = "higher is better"
+		case -1:
+			notes = "lower is better"
+		}
+		if units.GetAssumption(unit) == benchmath.AssumeExact {
+			if notes != "" {
+				notes += ", "
+			}
+			notes += "exact"
+		}
+		if notes == "" {
+			fmt.Fprintf(tw, "  %s\t%d measurements\n", unit, counts[unit])
+			continue
+		}
+		fmt.Fprintf(tw, "  %s\t%d measurements\t%s\n", unit, counts[unit], notes)
+	}
+	return tw.Flush()
+}
+
+// inspectName adds the base name and the name keys of name to kc, using the
+// same key names as projections.
+func inspectName(kc keyCounts, name benchfmt.Name) {
+	base, parts := name.Parts()
+	kc.add(".name", base)
+	for _, part := range parts {
+		if part[0] == '-' {
+			kc.add("/gomaxprocs", part[1:])
+		} else if i := bytes.IndexByte(part, '='); i >= 0 {
+			kc.add(string(part[:i]), part[i+1:])
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"golang.org/x/perf/benchfmt"
+)
+
+// benchseriesSeries is one element of the JSON output of benchseries -jo. This
+// mirrors [golang.org/x/perf/benchseries.ComparisonSeries], but we declare it
+// here to avoid that package's plotting dependencies.
+type benchseriesSeries struct {
+	Unit       string
+	Benchmarks []string
+	Series     []string
+	Summaries  [][]*struct {
+		Low, Center, High float64
+		Date              string
+		Present           bool
+	}
+	HashPairs map[string]struct{ NumHash, DenHash string }
+}
+
+// readBenchseries reads the JSON output of benchseries -jo.
+//
+// Each benchseries comparison is the ratio of a benchmark measurement between
+// a numerator and a denominator toolchain. Each comparison becomes a Result
+// named after its benchmark, with the series point (typically a commit date)
+// in the "series" configuration key, the date the measurements were taken in
+// "date", and the compared commits in "numerator" and "denominator". The
+// result's values are the center of the comparison ratio in a unit such as
+// "ratio-sec/op" and the bounds of its confidence interval in units such as
+// "low-ratio-sec/op" and "high-ratio-sec/op".
+func readBenchseries(r io.Reader, path, label string) (reader, error) {
+	var file []benchseriesSeries
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: parsing benchseries JSON: %w", path, err)
+	}
+
+	var recs []benchfmt.Record
+	for _, cs := range file {
+		unit := "ratio-" + cs.Unit
+		for i, row := range cs.Summaries {
+			if i >= len(cs.Series) {
+				return nil, fmt.Errorf("%s: benchseries %s has more summaries than series", path, cs.Unit)
+			}
+			series := cs.Series[i]
+			hashes := cs.HashPairs[series]
+			for j, sum := range row {
+				if sum == nil || !sum.Present {
+					continue
+				}
+				if j >= len(cs.Benchmarks) {
+					return nil, fmt.Errorf("%s: benchseries %s has more summaries than benchmarks", path, cs.Unit)
+				}
+				res := newResult(cs.Benchmarks[j], label, "series", series, "date", sum.Date, "numerator", hashes.NumHash, "denominator", hashes.DenHash)
+				res.Values = append(res.Values,
+					benchfmt.Value{Value: sum.Center, Unit: unit},
+					benchfmt.Value{Value: sum.Low, Unit: "low-" + unit},
+					benchfmt.Value{Value: sum.High, Unit: "high-" + unit},
+				)
+				recs = append(recs, res)
+			}
+		}
+	}
+	return &sliceReader{recs: recs}, nil
+}
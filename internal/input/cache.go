@@ -0,0 +1,401 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package input
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/perf/benchfmt"
+)
+
+// cacheMagic starts every cache file. Changing the encoding requires changing
+// this, which also changes every cache key.
+const cacheMagic = "benchplot cache 2\n"
+
+// Record kinds in a cache file.
+const (
+	cacheResult byte = 1 + iota
+	cacheUnitMetadata
+	cacheSyntaxError
+)
+
+// cachePath returns the path in f.CacheDir of the cache of the input read from
+// file. The key hashes the content of file and the options that affect how
+// it's parsed, but not its path or label, which are filled in when the cache
+// is read. It leaves file at its start.
+func (f *Files) cachePath(file io.ReadSeeker) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s%q\n", cacheMagic, f.Format)
+	if f.Format == "csv" {
+		fmt.Fprintf(h, "%q %q", f.CSV.Name, f.CSV.Keys)
+		for _, col := range sortedKeys(f.CSV.Values) {
+			fmt.Fprintf(h, " %q=%q", col, f.CSV.Values[col])
+		}
+		fmt.Fprintf(h, "\n")
+	}
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return filepath.Join(f.CacheDir, hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// A cacheReader is a reader over the records of a cache file, which are all
+// read up front.
+type cacheReader struct {
+	sliceReader
+}
+
+// readCache reads the cache file at path for input inp. It reports false if
+// there's no usable cache.
+func readCache(path string, inp *input) (*cacheReader, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil || !bytes.HasPrefix(data, []byte(cacheMagic)) {
+		return nil, false
+	}
+	d := cacheDecoder{data: data[len(cacheMagic):]}
+	r := new(cacheReader)
+	pos := newCachePositioner(inp.path)
+	var vals []string
+	for len(d.data) > 0 && d.err == nil {
+		switch kind := d.byte(); kind {
+		case cacheResult:
+			res := pos.result(int(d.uvarint()))
+			res.Name = []byte(d.string())
+			res.Iters = int(d.uvarint())
+			// Leave room for the keys added by annotate.
+			n := d.count()
+			res.Config = make([]benchfmt.Config, n, n+2+len(inp.keys)/2)
+			// Values are modified in place, so each Result needs its
+			// own, but they can share one allocation.
+			vals = vals[:0]
+			size := 0
+			for i := range res.Config {
+				cfg := &res.Config[i]
+				var val string
+				cfg.Key, val, cfg.File = d.string(), d.string(), d.byte() != 0
+				if cfg.Key == ".file" {
+					// The label may differ from when the cache
+					// was written.
+					val = inp.label
+				}
+				vals = append(vals, val)
+				size += len(val)
+			}
+			buf := make([]byte, 0, size)
+			for i, val := range vals {
+				start := len(buf)
+				buf = append(buf, val...)
+				res.Config[i].Value = buf[start:len(buf):len(buf)]
+			}
+			res.Values = make([]benchfmt.Value, d.count())
+			for i := range res.Values {
+				val := &res.Values[i]
+				val.Unit, val.Value = d.string(), d.float64()
+				if val.OrigUnit = d.string(); val.OrigUnit != "" {
+					val.OrigValue = d.float64()
+				}
+			}
+			r.recs = append(r.recs, res)
+		case cacheUnitMetadata:
+			m := pos.unitMetadata(int(d.uvarint()))
+			m.Unit, m.Key, m.OrigUnit, m.Value = d.string(), d.string(), d.string(), d.string()
+			r.recs = append(r.recs, m)
+		case cacheSyntaxError:
+			line := int(d.uvarint())
+			r.recs = append(r.recs, &benchfmt.SyntaxError{FileName: inp.path, Line: line, Msg: d.string()})
+		default:
+			d.err = fmt.Errorf("bad record kind %d", kind)
+		}
+	}
+	if d.err != nil {
+		return nil, false
+	}
+	return r, true
+}
+
+// A cachePositioner makes the records read from a cache file report their
+// position in the input. Only a benchfmt.Reader can set the position of a
+// record, so it parses a made-up input with a line at each position that's
+// needed, and the records of the cache copy the records parsed from it.
+type cachePositioner struct {
+	br   *benchfmt.Reader
+	src  bytes.Buffer // Unread made-up input
+	line int          // Lines written to src
+
+	res      *benchfmt.Result // Positioned at resLine
+	resLine  int
+	meta     benchfmt.UnitMetadata // Positioned at metaLine
+	metaLine int
+}
+
+func newCachePositioner(path string) *cachePositioner {
+	p := new(cachePositioner)
+	p.br = benchfmt.NewReader(&p.src, path)
+	return p
+}
+
+// result returns a new Result positioned at line, or with no position if
+// line is 0.
+func (p *cachePositioner) result(line int) *benchfmt.Result {
+	if line != p.resLine && p.parse(line, "BenchmarkX 1 0 x") {
+		if res, ok := p.br.Result().(*benchfmt.Result); ok {
+			// The Reader reuses its Result.
+			p.res, p.resLine = res.Clone(), line
+		}
+	}
+	if line == 0 || line != p.resLine {
+		return new(benchfmt.Result)
+	}
+	// Clone starts a new index of the configuration keys.
+	return p.res.Clone()
+}
+
+// unitMetadata returns a new UnitMetadata positioned at line, or with no
+// position if line is 0.
+func (p *cachePositioner) unitMetadata(line int) *benchfmt.UnitMetadata {
+	// The Reader drops repeated metadata, so each line sets a distinct
+	// key.
+	if line != p.metaLine && p.parse(line, fmt.Sprintf("Unit x k%d=v", line)) {
+		if m, ok := p.br.Result().(*benchfmt.UnitMetadata); ok {
+			p.meta, p.metaLine = *m, line
+		}
+	}
+	if line == 0 || line != p.metaLine {
+		return new(benchfmt.UnitMetadata)
+	}
+	m := p.meta
+	return &m
+}
+
+// parse parses text as line of the made-up input. It reports false if the
+// input is already past line.
+func (p *cachePositioner) parse(line int, text string) bool {
+	if line <= p.line {
+		return false
+	}
+	for ; p.line < line-1; p.line++ {
+		p.src.WriteByte('\n')
+	}
+	p.src.WriteString(text + "\n")
+	p.line++
+	return p.br.Scan()
+}
+
+// A cachingReader is a reader that writes a cache file of all of the records
+// of another reader, once that reader has returned them all without error.
+type cachingReader struct {
+	reader
+	path string
+	enc  cacheEncoder
+}
+
+func newCachingReader(r reader, path string) *cachingReader {
+	c := &cachingReader{reader: r, path: path}
+	c.enc.buf = append(c.enc.buf, cacheMagic...)
+	return c
+}
+
+func (c *cachingReader) Scan() bool {
+	if c.reader.Scan() {
+		c.enc.record(c.reader.Result())
+		return true
+	}
+	if c.reader.Err() == nil && c.enc.buf != nil {
+		// Failing to write the cache only makes the next read
+		// slower, so ignore errors.
+		writeFileAtomic(c.path, c.enc.buf)
+	}
+	c.enc.buf = nil
+	return false
+}
+
+// writeFileAtomic writes data to the file at path, so that readers of path
+// see either all of data or no file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// A cacheEncoder encodes records for a cache file. Each string is written
+// in full the first time and as a reference to that after, since most strings,
+// such as configuration and units, repeat in every result.
+type cacheEncoder struct {
+	buf     []byte
+	strings map[string]uint64
+}
+
+func (e *cacheEncoder) record(rec benchfmt.Record) {
+	switch rec := rec.(type) {
+	case *benchfmt.Result:
+		e.buf = append(e.buf, cacheResult)
+		e.line(rec)
+		e.string(string(rec.Name))
+		e.uvarint(uint64(rec.Iters))
+		e.uvarint(uint64(len(rec.Config)))
+		for _, cfg := range rec.Config {
+			e.string(cfg.Key)
+			e.string(string(cfg.Value))
+			if cfg.File {
+				e.buf = append(e.buf, 1)
+			} else {
+				e.buf = append(e.buf, 0)
+			}
+		}
+		e.uvarint(uint64(len(rec.Values)))
+		for _, val := range rec.Values {
+			e.string(val.Unit)
+			e.float64(val.Value)
+			e.string(val.OrigUnit)
+			if val.OrigUnit != "" {
+				e.float64(val.OrigValue)
+			}
+		}
+	case *benchfmt.UnitMetadata:
+		e.buf = append(e.buf, cacheUnitMetadata)
+		e.line(rec)
+		e.string(rec.Unit)
+		e.string(rec.Key)
+		e.string(rec.OrigUnit)
+		e.string(rec.Value)
+	case *benchfmt.SyntaxError:
+		e.buf = append(e.buf, cacheSyntaxError)
+		e.uvarint(uint64(rec.Line))
+		e.string(rec.Msg)
+	}
+}
+
+// line writes the line of rec, or 0 if it has no position.
+func (e *cacheEncoder) line(rec benchfmt.Record) {
+	_, line := rec.Pos()
+	e.uvarint(uint64(line))
+}
+
+func (e *cacheEncoder) uvarint(x uint64) {
+	e.buf = binary.AppendUvarint(e.buf, x)
+}
+
+func (e *cacheEncoder) float64(x float64) {
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(x))
+}
+
+// string writes s as 0, its length, and its bytes the first time, and as its
+// index in the order of first writes plus 1 after that.
+func (e *cacheEncoder) string(s string) {
+	if i, ok := e.strings[s]; ok {
+		e.uvarint(i + 1)
+		return
+	}
+	if e.strings == nil {
+		e.strings = make(map[string]uint64)
+	}
+	e.strings[s] = uint64(len(e.strings))
+	e.uvarint(0)
+	e.uvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// A cacheDecoder decodes the encoding of cacheEncoder. Once it fails, it sets
+// err and returns zero values.
+type cacheDecoder struct {
+	data    []byte
+	strings []string
+	err     error
+}
+
+var errCacheTruncated = errors.New("truncated cache")
+
+func (d *cacheDecoder) byte() byte {
+	if len(d.data) < 1 {
+		d.err = errCacheTruncated
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *cacheDecoder) uvarint() uint64 {
+	x, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = errCacheTruncated
+		d.data = nil
+		return 0
+	}
+	d.data = d.data[n:]
+	return x
+}
+
+// count decodes the length of a sequence. Each element takes at least a
+// byte, so it fails rather than return more than the remaining data.
+func (d *cacheDecoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.data)) {
+		d.err = errCacheTruncated
+		d.data = nil
+		return 0
+	}
+	return int(n)
+}
+
+func (d *cacheDecoder) float64() float64 {
+	if len(d.data) < 8 {
+		d.err = errCacheTruncated
+		d.data = nil
+		return 0
+	}
+	x := math.Float64frombits(binary.LittleEndian.Uint64(d.data))
+	d.data = d.data[8:]
+	return x
+}
+
+func (d *cacheDecoder) string() string {
+	i := d.uvarint()
+	if i > 0 {
+		if i > uint64(len(d.strings)) {
+			d.err = errCacheTruncated
+			d.data = nil
+			return ""
+		}
+		return d.strings[i-1]
+	}
+	n := d.uvarint()
+	if n > uint64(len(d.data)) {
+		d.err = errCacheTruncated
+		d.data = nil
+		return ""
+	}
+	s := string(d.data[:n])
+	d.data = d.data[n:]
+	d.strings = append(d.strings, s)
+	return s
+}
@@ -0,0 +1,158 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package input
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/perf/benchfmt"
+)
+
+// Criterion stores the results of each benchmark in a directory such as
+// target/criterion/<group>/<function>/<value>/new, containing raw.csv with
+// every sample, estimates.json with summary statistics, and benchmark.json
+// identifying the benchmark. A "base" directory next to "new" holds the
+// results of the previous run.
+
+// criterionInclude are the files read by default from directory inputs in the
+// criterion format.
+var criterionInclude = []string{"raw.csv"}
+
+// criterionSkip reports whether path is a criterion file that should be
+// skipped when expanding a directory. This skips saved baselines, which would
+// otherwise double up the current results.
+func criterionSkip(path string) bool {
+	return filepath.Base(filepath.Dir(path)) != "new"
+}
+
+// criterionName returns the Go benchmark name for a criterion benchmark. The
+// group becomes the base name, and the function and value become name keys.
+func criterionName(group, function, value string) string {
+	name := group
+	if function != "" {
+		name += "/function=" + function
+	}
+	if value != "" {
+		name += "/value=" + value
+	}
+	return name
+}
+
+// readCriterion reads a criterion raw.csv or estimates.json file, depending on
+// the base name of path.
+//
+// Each row of raw.csv becomes a Result with the time per iteration, plus the
+// throughput if the benchmark measured it. Since estimates.json only has
+// summary statistics, it becomes a single Result with the mean time per
+// iteration.
+func readCriterion(r io.Reader, path, label string) (reader, error) {
+	if filepath.Base(path) == "estimates.json" {
+		return readCriterionEstimates(r, path, label)
+	}
+
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading criterion CSV header: %w", path, err)
+	}
+	cols := make(map[string]int)
+	for i, col := range header {
+		cols[col] = i
+	}
+	for _, col := range []string{"group", "function", "value", "sample_measured_value", "unit", "iteration_count"} {
+		if _, ok := cols[col]; !ok {
+			return nil, fmt.Errorf("%s: criterion CSV missing %q column", path, col)
+		}
+	}
+	get := func(row []string, col string) string {
+		if i, ok := cols[col]; ok {
+			return row[i]
+		}
+		return ""
+	}
+
+	var recs []benchfmt.Record
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		syntaxErr := func(msg string, err error) {
+			recs = append(recs, &benchfmt.SyntaxError{FileName: path, Line: line, Msg: msg + ": " + err.Error()})
+		}
+
+		measured, err := strconv.ParseFloat(get(row, "sample_measured_value"), 64)
+		if err != nil {
+			syntaxErr("parsing sample_measured_value", err)
+			continue
+		}
+		iters, err := strconv.ParseFloat(get(row, "iteration_count"), 64)
+		if err != nil {
+			syntaxErr("parsing iteration_count", err)
+			continue
+		}
+		res := newResult(criterionName(get(row, "group"), get(row, "function"), get(row, "value")), label)
+		res.Iters = int(iters)
+		perOp := measured / iters
+		unit := get(row, "unit")
+		res.Values = append(res.Values, tidyValue(perOp, unit+"/op"))
+
+		// Add throughput, if any.
+		if tnum := get(row, "throughput_num"); tnum != "" && unit == "ns" {
+			num, err := strconv.ParseFloat(tnum, 64)
+			if err != nil {
+				syntaxErr("parsing throughput_num", err)
+				continue
+			}
+			switch get(row, "throughput_type") {
+			case "bytes", "bytes_decimal":
+				res.Values = append(res.Values, benchfmt.Value{Value: num / (perOp / 1e9), Unit: "B/s"})
+			case "elements":
+				res.Values = append(res.Values, benchfmt.Value{Value: num / (perOp / 1e9), Unit: "elements/s"})
+			}
+		}
+		recs = append(recs, res)
+	}
+	return &sliceReader{recs: recs}, nil
+}
+
+func readCriterionEstimates(r io.Reader, path, label string) (reader, error) {
+	var est struct {
+		Mean struct {
+			PointEstimate float64 `json:"point_estimate"`
+		}
+	}
+	if err := json.NewDecoder(r).Decode(&est); err != nil {
+		return nil, fmt.Errorf("%s: parsing criterion estimates: %w", path, err)
+	}
+
+	// The benchmark identity is in benchmark.json next to estimates.json.
+	bpath := filepath.Join(filepath.Dir(path), "benchmark.json")
+	data, err := os.ReadFile(bpath)
+	if err != nil {
+		return nil, err
+	}
+	var bench struct {
+		GroupID    string `json:"group_id"`
+		FunctionID string `json:"function_id"`
+		ValueStr   string `json:"value_str"`
+	}
+	if err := json.Unmarshal(data, &bench); err != nil {
+		return nil, fmt.Errorf("%s: %w", bpath, err)
+	}
+
+	// Criterion always records estimates in nanoseconds.
+	res := newResult(criterionName(bench.GroupID, bench.FunctionID, bench.ValueStr), label)
+	res.Values = append(res.Values, tidyValue(est.Mean.PointEstimate, "ns/op"))
+	return &sliceReader{recs: []benchfmt.Record{res}}, nil
+}
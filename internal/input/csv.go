@@ -0,0 +1,154 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package input
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+
+	"golang.org/x/perf/benchfmt"
+)
+
+// CSVConfig describes how to map the columns of a CSV file to benchmark
+// results. The first row of a CSV file names the columns, and each other row
+// is one Result.
+type CSVConfig struct {
+	// Name is the column giving the benchmark name. If "", it
+	// defaults to "name".
+	Name string
+
+	// Keys lists the columns giving configuration keys. Values maps
+	// from a column giving a measurement to the unit of that column.
+	// If the unit is "", it's the name of the column.
+	//
+	// If Values is empty, all columns other than Name and Keys are
+	// measurements. Otherwise, if Keys is empty, all columns other than
+	// Name and Values are keys.
+	Keys   []string
+	Values map[string]string
+}
+
+type csvReader struct {
+	r        *csv.Reader
+	path     string
+	label    string
+	line     int
+	nameCol  int
+	keyCols  []int
+	valCols  []int
+	valUnits []string
+	header   []string
+
+	result benchfmt.Result
+	rec    benchfmt.Record
+	err    error
+}
+
+func newCSVReader(r io.Reader, path, label string, cfg *CSVConfig) (*csvReader, error) {
+	cr := &csvReader{r: csv.NewReader(r), path: path, label: label}
+	cr.r.ReuseRecord = true
+
+	header, err := cr.r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading CSV header: %w", path, err)
+	}
+	cr.header = slices.Clone(header)
+	cr.line = 1
+
+	nameName := cfg.Name
+	if nameName == "" {
+		nameName = "name"
+	}
+	cr.nameCol = -1
+	for i, col := range cr.header {
+		_, isVal := cfg.Values[col]
+		switch {
+		case col == nameName:
+			cr.nameCol = i
+		case slices.Contains(cfg.Keys, col), len(cfg.Keys) == 0 && len(cfg.Values) > 0 && !isVal:
+			cr.keyCols = append(cr.keyCols, i)
+		case isVal, len(cfg.Values) == 0:
+			unit := cfg.Values[col]
+			if unit == "" {
+				unit = col
+			}
+			cr.valCols = append(cr.valCols, i)
+			cr.valUnits = append(cr.valUnits, unit)
+		}
+	}
+	if cr.nameCol < 0 {
+		return nil, fmt.Errorf("%s: no CSV column %q", path, nameName)
+	}
+	for _, col := range cfg.Keys {
+		if !slices.Contains(cr.header, col) {
+			return nil, fmt.Errorf("%s: no CSV column %q", path, col)
+		}
+	}
+	for col := range cfg.Values {
+		if !slices.Contains(cr.header, col) {
+			return nil, fmt.Errorf("%s: no CSV column %q", path, col)
+		}
+	}
+	return cr, nil
+}
+
+func (r *csvReader) Scan() bool {
+	if r.err != nil {
+		return false
+	}
+	row, err := r.r.Read()
+	if err == io.EOF {
+		return false
+	}
+	r.line++
+	if err != nil {
+		if perr, ok := err.(*csv.ParseError); ok {
+			// Like benchfmt, syntax errors are not fatal.
+			r.rec = &benchfmt.SyntaxError{FileName: r.path, Line: perr.Line, Msg: perr.Err.Error()}
+			return true
+		}
+		r.err = err
+		return false
+	}
+
+	// Start a fresh Result, but reuse its buffers where we can.
+	res := &r.result
+	*res = benchfmt.Result{Name: res.Name[:0], Values: res.Values[:0]}
+	res.SetConfig(".file", r.label)
+	for _, i := range r.keyCols {
+		res.SetConfig(r.header[i], row[i])
+	}
+	res.Name = append(res.Name, row[r.nameCol]...)
+	res.Iters = 1
+	for j, i := range r.valCols {
+		if row[i] == "" {
+			// Missing measurement.
+			continue
+		}
+		val, err := strconv.ParseFloat(row[i], 64)
+		if err != nil {
+			r.rec = &benchfmt.SyntaxError{FileName: r.path, Line: r.line, Msg: fmt.Sprintf("parsing column %s: %s", r.header[i], err)}
+			return true
+		}
+		res.Values = append(res.Values, tidyValue(val, r.valUnits[j]))
+	}
+	if len(res.Values) == 0 {
+		r.rec = &benchfmt.SyntaxError{FileName: r.path, Line: r.line, Msg: "missing measurements"}
+		return true
+	}
+	r.rec = res
+	return true
+}
+
+func (r *csvReader) Result() benchfmt.Record {
+	return r.rec
+}
+
+func (r *csvReader) Err() error {
+	return r.err
+}
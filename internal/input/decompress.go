@@ -0,0 +1,62 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompress wraps r in a decompressor if r is compressed. It detects the
+// compression format from the first few bytes of r rather than its name, so
+// this works for unnamed inputs like stdin.
+func decompress(r io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	// Peek returns an error if the input is shorter than the magic, in
+	// which case it can't be compressed, so we ignore the error.
+	magic, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		return readCloser{zr, func() error {
+			zr.Close()
+			return r.Close()
+		}}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		return readCloser{zr, func() error {
+			zr.Close()
+			return r.Close()
+		}}, nil
+	}
+	return readCloser{br, r.Close}, nil
+}
+
+// readCloser combines a Reader with a custom Close function.
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error {
+	return r.close()
+}
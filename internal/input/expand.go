@@ -0,0 +1,161 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package input
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// expand expands path into the list of files it names. If path is a
+// directory, it returns all files under path whose base names match one of
+// include, or all files if include is empty. If path contains glob
+// metacharacters, it returns all files matching the glob, where a "**" path
+// element matches zero or more directories. Otherwise, it returns just path.
+// It also returns the directories that were searched for files, or the
+// directory of path if it's a file.
+//
+// The files are in lexical order.
+func expand(path string, include []string) (paths, dirs []string, err error) {
+	if hasMeta(path) {
+		return expandGlob(path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		// Let the caller report the error when it opens path.
+		return []string{path}, []string{filepath.Dir(path)}, nil
+	}
+
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			dirs = append(dirs, p)
+			return nil
+		}
+		if len(include) > 0 {
+			match := false
+			for _, pat := range include {
+				if ok, _ := filepath.Match(pat, d.Name()); ok {
+					match = true
+					break
+				}
+			}
+			if !match {
+				return nil
+			}
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	return paths, dirs, err
+}
+
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// expandGlob returns the files matching pattern, where a "**" path element
+// matches zero or more directories, and the directories that could contain
+// them.
+func expandGlob(pattern string) (paths, dirs []string, err error) {
+	// Validate the pattern up front, since Match only reports syntax errors
+	// lazily.
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, nil, err
+	}
+
+	// Walk from the longest prefix of pattern without metacharacters.
+	patElems := strings.Split(filepath.ToSlash(filepath.Clean(pattern)), "/")
+	var rootElems []string
+	for len(patElems) > 1 && !hasMeta(patElems[0]) {
+		rootElems, patElems = append(rootElems, patElems[0]), patElems[1:]
+	}
+	root := "."
+	if len(rootElems) > 0 {
+		root = strings.Join(rootElems, "/")
+		if root == "" {
+			// The pattern was absolute.
+			root = "/"
+		}
+	}
+	root = filepath.FromSlash(root)
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				// Nothing matches.
+				return fs.SkipAll
+			}
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		relElems := strings.Split(filepath.ToSlash(rel), "/")
+		if d.IsDir() {
+			if p != root && !matchDir(patElems, relElems) {
+				return fs.SkipDir
+			}
+			dirs = append(dirs, p)
+			return nil
+		}
+		if matchElems(patElems, relElems) {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	slices.Sort(paths)
+	return paths, dirs, err
+}
+
+// matchDir reports whether files under the directory with path elements dir
+// may match the pattern elements pat.
+func matchDir(pat, dir []string) bool {
+	for len(dir) > 0 {
+		if len(pat) == 0 {
+			return false
+		}
+		if pat[0] == "**" {
+			return true
+		}
+		if ok, _ := filepath.Match(pat[0], dir[0]); !ok {
+			return false
+		}
+		pat, dir = pat[1:], dir[1:]
+	}
+	// A file must still follow.
+	return len(pat) > 0
+}
+
+// matchElems reports whether the path elements name match the pattern
+// elements pat.
+func matchElems(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			// Try matching zero or more elements.
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pat[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
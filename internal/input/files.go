@@ -0,0 +1,733 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package input reads benchmark results from a sequence of inputs.
+//
+// It is an extension of [benchfmt.Files] that understands more kinds of
+// inputs.
+package input
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/perf/benchfmt"
+)
+
+// A Files reads benchmark results from a sequence of input files.
+//
+// Like [benchfmt.Files], this adds a ".file" configuration key to the output
+// Results corresponding to each path read in, and supports label=path syntax
+// if AllowLabels is set.
+//
+// Unlike benchfmt.Files, inputs may be compressed with gzip or zstd. This is
+// detected from the content of the input, so it works for stdin, too.
+//
+// For inputs read from files, Files also adds a ".filetime" configuration key
+// giving the time of the file as seconds since the Unix epoch. This is taken
+// from a date in the file's base name, such as "2006-01-02" or "20060102", if
+// there is one, and otherwise from the file's modification time.
+//
+// If there are several inputs and none of them are labeled, Files also adds a
+// ".run" configuration key identifying each input. This is the base name of
+// the input, without its extensions, if those are all distinct, or otherwise
+// its ordinal in the sequence of inputs, starting at 1.
+type Files struct {
+	// Paths is the list of file names to read in.
+	//
+	// If AllowLabels is set, these strings may be of the form
+	// label=path, and the label part will be used for the
+	// ".file" key in the results.
+	Paths []string
+
+	// AllowStdin indicates that the path "-" should be treated as
+	// stdin and if the file list is empty, it should be treated
+	// as consisting of stdin.
+	AllowStdin bool
+
+	// AllowLabels indicates that custom labels are allowed in
+	// Paths.
+	AllowLabels bool
+
+	// Include is a list of file name patterns, in the syntax of
+	// [filepath.Match]. If a path names a directory, all files
+	// under that directory whose base name matches one of these
+	// patterns are read. If Include is empty, all files are read.
+	//
+	// Paths may also be glob patterns, where a "**" path element
+	// matches zero or more directories.
+	Include []string
+
+	// Paths may also be http:// or https:// URLs, which are
+	// fetched using Client. If Client is nil, each fetch times out
+	// after five minutes. Header gives additional headers to send
+	// with each request, such as authorization headers.
+	Client *http.Client
+	Header http.Header
+
+	// Paths may also be queries of the form "perfdata:query",
+	// which fetch the results matching query from the perf data
+	// server at PerfDataServer, or DefaultPerfDataServer if
+	// PerfDataServer is "". For example,
+	// "perfdata:upload:20240101.1" fetches a single upload. See
+	// [golang.org/x/perf/storage.Client.Query] for the query
+	// syntax.
+	PerfDataServer string
+
+	// Paths may also be queries of the form "influx:tag=value,...",
+	// which read the points matching the given tags from the InfluxDB
+	// server configured by Influx. See [InfluxConfig] for how points
+	// become results.
+	Influx InfluxConfig
+
+	// Format is the format of the inputs. It must be one of
+	// Formats. If "", inputs are in the Go benchmark format.
+	Format string
+
+	// CSV configures how to read inputs in the "csv" format.
+	CSV CSVConfig
+
+	// CacheDir, if non-empty, is a directory where Files caches the
+	// results it parses from local files, keyed by a hash of the
+	// content of each file and of the options that affect parsing.
+	// Reading an unchanged file again reads its cached results, which
+	// skips decompressing and parsing it. Nothing is ever removed from
+	// CacheDir.
+	CacheDir string
+
+	// Follow, if set, makes Files wait for more data at the end of each
+	// local file rather than ending it, so results are read as they're
+	// written. Followed files are not cached.
+	Follow bool
+
+	// FileKeys gives additional configuration keys to add to every
+	// Result from particular inputs. It maps from an input to an
+	// alternating sequence of keys and values. An input matches an
+	// entry if the entry is the input as given in Paths, or its
+	// path or label, or, for inputs expanded from a directory or
+	// glob, the path of the individual file.
+	FileKeys map[string][]string
+
+	// Context, if non-nil, cancels reading the inputs. Once Context is
+	// done, Scan stops any parsing and fetches in progress and returns
+	// false, and Err returns Context's error.
+	Context context.Context
+
+	// inputs is the sequence of remaining inputs, or nil if this
+	// Files has not started yet. Note that this distinguishes nil
+	// from length 0.
+	inputs []input
+
+	// nInputs is the total number of inputs, and nOpened is the number
+	// of those that have been opened, for Progress.
+	nInputs, nOpened int
+
+	reader   benchfmt.Reader // For the benchfmt format
+	cur      reader
+	curInput input
+	rec      benchfmt.Record // Record returned by Result
+	file     io.ReadCloser
+	err      error
+
+	// units is the unit metadata of all of the inputs read so far.
+	units benchfmt.UnitMetadataMap
+
+	// For parallel parsing, parsed is the reader of each remaining
+	// input, in order. Receiving from sem makes room for another
+	// worker, and stop stops them all.
+	parsed []*parsingReader
+	sem    chan struct{}
+	stop   context.CancelFunc
+}
+
+type input struct {
+	path      string
+	label     string
+	isStdin   bool
+	isLabeled bool
+	keys      []string // Extra configuration from FileKeys
+	run       string   // Value of .run, or "" for none
+	time      string   // Value of .filetime, set when opened
+}
+
+// init does first-use initialization of f.
+func (f *Files) init() error {
+	// Set f.inputs to a non-nil slice to indicate initialization
+	// has happened.
+	f.inputs = []input{}
+	f.units = make(benchfmt.UnitMetadataMap)
+
+	// Parse the paths. Doing this first simplifies iteration and
+	// disambiguation.
+	pathCount := make(map[string]int)
+	if f.AllowStdin && len(f.Paths) == 0 {
+		f.inputs = append(f.inputs, input{path: "-", label: "-", isStdin: true, keys: f.FileKeys["-"]})
+	}
+	for _, arg := range f.Paths {
+		label, path, isLabeled := f.splitLabel(arg)
+		if !isLabeled {
+			pathCount[path]++
+		}
+
+		keys := f.fileKeys(arg, path, label)
+		if f.AllowStdin && path == "-" {
+			f.inputs = append(f.inputs, input{path: path, label: label, isStdin: true, isLabeled: isLabeled, keys: keys})
+			continue
+		}
+		if isURL(path) || isPerfData(path) || isInflux(path) {
+			f.inputs = append(f.inputs, input{path: path, label: label, isLabeled: isLabeled, keys: keys})
+			continue
+		}
+
+		// Expand directories and globs.
+		paths, _, err := f.expand(path)
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("%s: no files match", path)
+		}
+		for _, path1 := range paths {
+			label1 := label
+			if !isLabeled {
+				label1 = path1
+				if path1 != path {
+					pathCount[path1]++
+				}
+			}
+			keys1 := keys
+			if keys1 == nil {
+				keys1 = f.FileKeys[path1]
+			}
+			f.inputs = append(f.inputs, input{path: path1, label: label1, isLabeled: isLabeled, keys: keys1})
+		}
+	}
+
+	// If the same path is given multiple times, disambiguate its
+	// .file, just like benchfmt.Files.
+	pathI := make(map[string]int)
+	for i := range f.inputs {
+		inp := &f.inputs[i]
+		if inp.isLabeled || pathCount[inp.path] == 1 {
+			continue
+		}
+		// Disambiguate.
+		inp.label = fmt.Sprintf("%s#%d", inp.path, pathI[inp.path])
+		pathI[inp.path]++
+	}
+
+	f.setRuns()
+	return nil
+}
+
+// splitLabel splits input argument arg into its label and path, and reports
+// whether it has a label. If not, the label is arg.
+func (f *Files) splitLabel(arg string) (label, path string, isLabeled bool) {
+	// URLs may contain "=" in their query, and influx queries always do,
+	// so don't mistake that for a label.
+	if i := strings.Index(arg, "="); f.AllowLabels && i >= 0 && !strings.Contains(arg[:i], "://") && !isInflux(arg) {
+		return arg[:i], arg[i+1:], true
+	}
+	return arg, arg, false
+}
+
+// expand expands the directory or glob input path into the files f reads, as
+// well as the directories that may contain them, as for [expand].
+func (f *Files) expand(path string) (paths, dirs []string, err error) {
+	include := f.Include
+	if len(include) == 0 && f.Format == "criterion" {
+		include = criterionInclude
+	}
+	paths, dirs, err = expand(path, include)
+	if f.Format == "criterion" {
+		paths = slices.DeleteFunc(paths, func(path1 string) bool {
+			return path1 != path && criterionSkip(path1)
+		})
+	}
+	return paths, dirs, err
+}
+
+// Watch returns the local files that f reads, with directories and globs
+// expanded, and the directories in which changes to these inputs may appear:
+// the directory of each file and, for directory and glob inputs, every
+// directory that could contain a matching file. Inputs that aren't local
+// files, such as URLs, are left out.
+func (f *Files) Watch() (files, dirs []string, err error) {
+	if f.AllowStdin && len(f.Paths) == 0 {
+		return nil, nil, fmt.Errorf("cannot watch stdin")
+	}
+	for _, arg := range f.Paths {
+		_, path, _ := f.splitLabel(arg)
+		if f.AllowStdin && path == "-" {
+			return nil, nil, fmt.Errorf("cannot watch stdin")
+		}
+		if isURL(path) || isPerfData(path) || isInflux(path) {
+			continue
+		}
+		paths, dirs1, err := f.expand(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, paths...)
+		dirs = append(dirs, dirs1...)
+	}
+	return files, dirs, nil
+}
+
+// setRuns assigns a .run to each input if there are several inputs and none
+// are labeled.
+func (f *Files) setRuns() {
+	if len(f.inputs) < 2 {
+		return
+	}
+	names := make(map[string]bool)
+	for _, inp := range f.inputs {
+		if inp.isLabeled {
+			return
+		}
+		names[runName(inp.path)] = true
+	}
+	ordinal := len(names) < len(f.inputs)
+	for i := range f.inputs {
+		inp := &f.inputs[i]
+		if ordinal {
+			inp.run = fmt.Sprint(i + 1)
+		} else {
+			inp.run = runName(inp.path)
+		}
+	}
+}
+
+// runName returns the base name of path without its extensions, including
+// compression extensions. For example, "x/old.txt.gz" becomes "old".
+func runName(path string) string {
+	name := filepath.Base(path)
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// fileNameDate matches a date in a file name. The separators are optional.
+var fileNameDate = regexp.MustCompile(`(?:^|[^0-9])([0-9]{4})-?([0-9]{2})-?([0-9]{2})(?:[^0-9]|$)`)
+
+// fileTime returns the value of .filetime for the file at path, which was
+// modified at mtime.
+func fileTime(path string, mtime time.Time) string {
+	if m := fileNameDate.FindStringSubmatch(filepath.Base(path)); m != nil {
+		if t, err := time.Parse("2006-01-02", m[1]+"-"+m[2]+"-"+m[3]); err == nil {
+			return fmt.Sprint(t.Unix())
+		}
+	}
+	return fmt.Sprint(mtime.Unix())
+}
+
+// fileKeys returns the extra configuration for the input given in Paths as
+// arg, which has the given path and label.
+func (f *Files) fileKeys(arg, path, label string) []string {
+	for _, k := range []string{arg, label, path} {
+		if keys, ok := f.FileKeys[k]; ok {
+			return keys
+		}
+	}
+	return nil
+}
+
+// Scan advances the reader to the next result in the sequence of
+// files and reports whether a result was read. The caller should use
+// the Result method to get the result. If Scan reaches the end of the
+// file sequence, or if an I/O error occurs, it returns false. In this
+// case, the caller should use the Err method to check for errors.
+//
+// If there are several inputs, Scan parses up to GOMAXPROCS of them
+// concurrently, but it still returns results in input order.
+func (f *Files) Scan() bool {
+	if f.err != nil {
+		return false
+	}
+
+	if f.inputs == nil {
+		if err := f.init(); err != nil {
+			f.err = err
+			return false
+		}
+		f.nInputs = len(f.inputs)
+		if len(f.inputs) > 1 {
+			f.startParallel()
+		}
+	}
+
+	for {
+		if err := f.ctxErr(); err != nil {
+			f.fail(err)
+			return false
+		}
+		if f.cur == nil {
+			// Open the next file.
+			if !f.next() {
+				// We're out of inputs, or there was an error.
+				return false
+			}
+		}
+
+		// Try to get the next result.
+		_, parsing := f.cur.(*parsingReader)
+		if f.cur.Scan() {
+			f.rec = f.cur.Result()
+			switch rec := f.rec.(type) {
+			case *benchfmt.Result:
+				if !parsing {
+					// Parsing workers annotate their own
+					// results.
+					f.curInput.annotate(rec)
+				}
+			case *benchfmt.UnitMetadata:
+				f.rec = f.addUnit(rec)
+			}
+			return true
+		}
+		err := f.cur.Err()
+		if err != nil {
+			f.fail(err)
+			break
+		}
+		// Just an EOF. Close this file and open the next.
+		if f.file != nil {
+			f.file.Close()
+			f.file = nil
+		}
+		if parsing {
+			// Make room for another worker.
+			<-f.sem
+		}
+		f.cur = nil
+	}
+	// We're out of files.
+	return false
+}
+
+// next opens the next input and sets f.cur to its reader. It reports whether
+// there was a next input. If there was an error, it sets f.err.
+func (f *Files) next() bool {
+	if f.parsed != nil {
+		if len(f.parsed) == 0 {
+			f.stopParsing()
+			return false
+		}
+		r := f.parsed[0]
+		f.parsed = f.parsed[1:]
+		f.cur, f.curInput = r, r.inp
+		f.nOpened++
+		return true
+	}
+
+	if len(f.inputs) == 0 {
+		return false
+	}
+	inp := f.inputs[0]
+	f.inputs = f.inputs[1:]
+	// Reuse f.reader so unit metadata accumulates across files.
+	cur, file, err := f.open(f.ctx(), &inp, &f.reader)
+	if err != nil {
+		f.err = err
+		return false
+	}
+	f.cur, f.file, f.curInput = cur, file, inp
+	f.nOpened++
+	return true
+}
+
+// addUnit adds unit metadata m to f.units. Each input's reader checks the
+// metadata it reads against the metadata it has read before, but not against
+// the other inputs' readers, so addUnit reports metadata that conflicts with
+// another input's as a syntax error in place of m, like [benchfmt.Reader].
+func (f *Files) addUnit(m *benchfmt.UnitMetadata) benchfmt.Record {
+	have, ok := f.units[m.UnitMetadataKey]
+	if !ok {
+		f.units[m.UnitMetadataKey] = m
+		return m
+	}
+	if have.Value == m.Value {
+		return m
+	}
+	file, line := m.Pos()
+	if file == "" {
+		file = f.curInput.path
+	}
+	return &benchfmt.SyntaxError{FileName: file, Line: line, Msg: fmt.Sprintf("metadata %s of unit %s already set to %s", m.Key, m.OrigUnit, have.Value)}
+}
+
+// ctx returns f.Context, or the background context if it's nil.
+func (f *Files) ctx() context.Context {
+	if f.Context == nil {
+		return context.Background()
+	}
+	return f.Context
+}
+
+// ctxErr returns f.Context's error if it's done, or nil. It's cheap enough to
+// call for every result.
+func (f *Files) ctxErr() error {
+	select {
+	case <-f.ctx().Done():
+		return f.ctx().Err()
+	default:
+		return nil
+	}
+}
+
+// fail sets f.err to err and stops any parallel parsing.
+func (f *Files) fail(err error) {
+	f.err = err
+	f.stopParsing()
+}
+
+// stopParsing stops any parallel parsing workers.
+func (f *Files) stopParsing() {
+	if f.stop != nil {
+		f.stop()
+		f.stop = nil
+	}
+}
+
+// Close stops reading f's inputs, including any parsing and fetches in
+// progress, and closes the input being read. After Close, Scan returns false.
+// Callers that stop calling Scan before it returns false must call Close.
+func (f *Files) Close() {
+	f.stopParsing()
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	f.inputs, f.parsed, f.cur = []input{}, nil, nil
+}
+
+// Progress returns the number of inputs that have been opened so far, and the
+// total number of inputs. The total is 0 until the first call to Scan.
+func (f *Files) Progress() (opened, total int) {
+	return f.nOpened, f.nInputs
+}
+
+// open opens input inp and returns a reader for it, using br for formats
+// based on the Go benchmark format. It also sets inp.time. Fetches are
+// canceled by ctx. The caller must close the returned file.
+func (f *Files) open(ctx context.Context, inp *input, br *benchfmt.Reader) (reader, io.ReadCloser, error) {
+	var file io.ReadCloser
+	var cachePath string // Cache to write, if any
+	influx := f.Influx.withDefaults()
+	if inp.isStdin {
+		file = io.NopCloser(os.Stdin)
+	} else if isInflux(inp.path) {
+		var err error
+		file, err = queryInflux(ctx, f.Client, &influx, f.Header, inp.path)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if isURL(inp.path) || isPerfData(inp.path) {
+		url := inp.path
+		if isPerfData(url) {
+			url = perfDataURL(f.PerfDataServer, url)
+		}
+		var err error
+		file, err = fetch(ctx, f.Client, url, f.Header)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		osFile, err := os.Open(inp.path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if fi, err := osFile.Stat(); err == nil {
+			inp.time = fileTime(inp.path, fi.ModTime())
+		}
+		if f.CacheDir != "" && !f.Follow {
+			cachePath, err = f.cachePath(osFile)
+			if err != nil {
+				osFile.Close()
+				return nil, nil, fmt.Errorf("%s: %w", inp.path, err)
+			}
+			if r, ok := readCache(cachePath, inp); ok {
+				return r, osFile, nil
+			}
+		}
+		file = osFile
+		if f.Follow {
+			file = followFile{osFile}
+		}
+	}
+	file, err := decompress(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", inp.path, err)
+	}
+
+	// Prepare the reader.
+	var cur reader
+	if isInflux(inp.path) {
+		cur, err = readInflux(file, inp.path, inp.label, &influx)
+	} else {
+		cur, err = f.newReader(file, inp.path, inp.label, br)
+	}
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if cachePath != "" {
+		cur = newCachingReader(cur, cachePath)
+	}
+	return cur, file, nil
+}
+
+// annotate adds inp's extra configuration keys to res.
+func (inp *input) annotate(res *benchfmt.Result) {
+	if inp.time != "" {
+		SetFileConfig(res, ".filetime", inp.time)
+	}
+	if inp.run != "" {
+		SetFileConfig(res, ".run", inp.run)
+	}
+	for i := 0; i < len(inp.keys); i += 2 {
+		SetFileConfig(res, inp.keys[i], inp.keys[i+1])
+	}
+}
+
+// parseBatch is the number of records a parallel parsing worker sends at a
+// time, and parseBuffer is the number of batches it may get ahead of the
+// consumer, which bounds memory use no matter how large the input is.
+const (
+	parseBatch  = 256
+	parseBuffer = 4
+)
+
+// A parsingReader is a reader over the records of an input that a parallel
+// parsing worker is reading. The worker sends the records in batches, and
+// sets err before closing batches.
+type parsingReader struct {
+	inp     input
+	batches chan []benchfmt.Record
+	batch   []benchfmt.Record
+	rec     benchfmt.Record
+	err     error
+}
+
+func (r *parsingReader) Scan() bool {
+	for len(r.batch) == 0 {
+		batch, ok := <-r.batches
+		if !ok {
+			return false
+		}
+		r.batch = batch
+	}
+	r.rec, r.batch = r.batch[0], r.batch[1:]
+	return true
+}
+
+func (r *parsingReader) Result() benchfmt.Record {
+	return r.rec
+}
+
+func (r *parsingReader) Err() error {
+	return r.err
+}
+
+// startParallel starts parsing f's inputs concurrently. Each input is read by
+// a worker, which sends its records to the input's reader in f.parsed. At most
+// GOMAXPROCS inputs are parsing or waiting to be consumed at a time.
+func (f *Files) startParallel() {
+	ctx, stop := context.WithCancel(f.ctx())
+	f.sem = make(chan struct{}, runtime.GOMAXPROCS(0))
+	f.stop = stop
+	f.parsed = make([]*parsingReader, len(f.inputs))
+	for i, inp := range f.inputs {
+		f.parsed[i] = &parsingReader{inp: inp, batches: make(chan []benchfmt.Record, parseBuffer)}
+	}
+	parsed := f.parsed
+	f.inputs = f.inputs[:0]
+	go func() {
+		for _, r := range parsed {
+			select {
+			case f.sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go f.parse(ctx, r)
+		}
+	}()
+}
+
+// parse reads the input of r and sends its records to r, until ctx is done.
+func (f *Files) parse(ctx context.Context, r *parsingReader) {
+	defer close(r.batches)
+	inp := r.inp
+	var br benchfmt.Reader
+	src, file, err := f.open(ctx, &inp, &br)
+	if err != nil {
+		r.err = err
+		return
+	}
+	defer file.Close()
+	_, cached := src.(*cacheReader)
+	var batch []benchfmt.Record
+	send := func() bool {
+		select {
+		case r.batches <- batch:
+			batch = nil
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	for src.Scan() {
+		if err := ctx.Err(); err != nil {
+			r.err = err
+			return
+		}
+		rec := src.Result()
+		if res, ok := rec.(*benchfmt.Result); ok {
+			if !cached {
+				// benchfmt.Reader reuses its Result.
+				res = res.Clone()
+			}
+			inp.annotate(res)
+			rec = res
+		}
+		batch = append(batch, rec)
+		if len(batch) == parseBatch && !send() {
+			return
+		}
+	}
+	// The consumer reads err once batches is closed.
+	r.err = src.Err()
+	if len(batch) > 0 {
+		send()
+	}
+}
+
+// Result returns the record that was just read by Scan.
+// See [benchfmt.Reader.Result].
+func (f *Files) Result() benchfmt.Record {
+	return f.rec
+}
+
+// Err returns the I/O error that stopped Scan, if any.
+// If Scan stopped because it read each file to completion,
+// or if Scan has not yet returned false, Err returns nil.
+func (f *Files) Err() error {
+	return f.err
+}
+
+// Units returns the accumulated unit metadata.
+// See [benchfmt.Reader.Units].
+func (f *Files) Units() benchfmt.UnitMetadataMap {
+	return f.units
+}
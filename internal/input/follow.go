@@ -0,0 +1,30 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package input
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// followPoll is how often to check a followed file for new data.
+const followPoll = 200 * time.Millisecond
+
+// A followFile reads from a file that may still be growing. Rather than
+// returning io.EOF at the end of the file, it waits for more data.
+type followFile struct {
+	*os.File
+}
+
+func (f followFile) Read(b []byte) (int, error) {
+	for {
+		n, err := f.File.Read(b)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+		time.Sleep(followPoll)
+	}
+}
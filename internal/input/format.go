@@ -0,0 +1,124 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package input
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"golang.org/x/perf/benchfmt"
+	"golang.org/x/perf/benchunit"
+)
+
+// A reader reads benchmark records from a single input in some format. Its
+// methods have the same meaning as those of [benchfmt.Reader].
+type reader interface {
+	Scan() bool
+	Result() benchfmt.Record
+	Err() error
+}
+
+// Formats lists the supported input formats. The first is the default.
+var Formats = []string{"benchfmt", "csv", "gotest-json", "gbench", "jmh", "criterion", "pytest", "benchseries"}
+
+// newReader returns a reader for input r in f's format. path is used in error
+// messages, and label is the value of ".file" for all Results. Formats based
+// on the Go benchmark format use br, so unit metadata accumulates in br.
+func (f *Files) newReader(r io.Reader, path, label string, br *benchfmt.Reader) (reader, error) {
+	switch f.Format {
+	case "", "benchfmt":
+		// Because ".file" is not valid syntax for file
+		// configuration keys in the file itself, there's no
+		// danger of it being overwritten.
+		br.Reset(r, path, ".file", label)
+		return br, nil
+	case "gotest-json":
+		// This is just the Go benchmark format wrapped in JSON.
+		br.Reset(newTestJSONReader(r), path, ".file", label)
+		return br, nil
+	case "csv":
+		return newCSVReader(r, path, label, &f.CSV)
+	case "gbench":
+		return readGBench(r, path, label)
+	case "jmh":
+		return readJMH(r, path, label)
+	case "criterion":
+		return readCriterion(r, path, label)
+	case "pytest":
+		return readPytest(r, path, label)
+	case "benchseries":
+		return readBenchseries(r, path, label)
+	}
+	return nil, fmt.Errorf("unknown input format %q", f.Format)
+}
+
+// tidyValue returns a benchfmt.Value for val in unit, tidied the same way
+// [benchfmt.Reader] tidies values.
+func tidyValue(val float64, unit string) benchfmt.Value {
+	tidyVal, tidyUnit := benchunit.Tidy(val, unit)
+	if tidyVal == val && tidyUnit == unit {
+		return benchfmt.Value{Value: val, Unit: unit}
+	}
+	return benchfmt.Value{Value: tidyVal, Unit: tidyUnit, OrigValue: val, OrigUnit: unit}
+}
+
+// A sliceReader is a reader over records that were all read up front. This is
+// convenient for formats that consist of a single document.
+type sliceReader struct {
+	recs []benchfmt.Record
+	pos  int
+	err  error // Returned by Err after all records
+}
+
+func (r *sliceReader) Scan() bool {
+	if r.pos >= len(r.recs) {
+		return false
+	}
+	r.pos++
+	return true
+}
+
+func (r *sliceReader) Result() benchfmt.Record {
+	return r.recs[r.pos-1]
+}
+
+func (r *sliceReader) Err() error {
+	return r.err
+}
+
+// newResult returns a new Result with the given name and ".file" label, and
+// with configuration keys set from config, which is an alternating sequence of
+// keys and values. Keys with empty values are omitted.
+func newResult(name, label string, config ...string) *benchfmt.Result {
+	res := &benchfmt.Result{Name: benchfmt.Name(name), Iters: 1}
+	for i := 0; i < len(config); i += 2 {
+		if config[i+1] != "" {
+			res.Config = append(res.Config, benchfmt.Config{Key: config[i], Value: []byte(config[i+1]), File: true})
+		}
+	}
+	res.SetConfig(".file", label)
+	return res
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// SetFileConfig sets key to val in res and marks it as file configuration, so
+// it behaves like a key read from the input itself. If val is "", it deletes
+// key.
+func SetFileConfig(res *benchfmt.Result, key, val string) {
+	res.SetConfig(key, val)
+	if pos, ok := res.ConfigIndex(key); ok {
+		res.Config[pos].File = true
+	}
+}
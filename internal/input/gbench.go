@@ -0,0 +1,129 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/perf/benchfmt"
+)
+
+// gbenchFile is the output of Google Benchmark's --benchmark_format=json.
+type gbenchFile struct {
+	Context    map[string]any
+	Benchmarks []map[string]any
+}
+
+// gbenchContext lists the context fields we turn into configuration keys.
+var gbenchContext = []string{"host_name", "executable", "num_cpus", "mhz_per_cpu", "library_build_type"}
+
+// gbenchFields lists the benchmark fields that aren't user counters.
+var gbenchFields = []string{
+	"name", "family_index", "per_family_instance_index", "run_name",
+	"run_type", "repetitions", "repetition_index", "threads", "iterations",
+	"real_time", "cpu_time", "time_unit", "label", "aggregate_name",
+	"aggregate_unit", "error_occurred", "error_message", "big_o", "rms",
+	"complexity_n",
+}
+
+// gbenchTimeScale converts from a Google Benchmark time unit to nanoseconds.
+var gbenchTimeScale = map[string]float64{"ns": 1, "us": 1e3, "ms": 1e6, "s": 1e9}
+
+// readGBench reads Google Benchmark JSON output.
+//
+// Benchmark arguments become name keys: a name like "BM_Foo/8/size:16" becomes
+// "BM_Foo/arg0=8/size=16". Real and CPU time become sec/op and cpu-sec/op,
+// byte and item rates become B/s and items/s, and user counters are each
+// reported in a unit named after the counter. Aggregate results, such as
+// means, are skipped, since benchplot computes its own statistics.
+func readGBench(r io.Reader, path, label string) (reader, error) {
+	var file gbenchFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: parsing Google Benchmark JSON: %w", path, err)
+	}
+
+	var config []string
+	for _, key := range gbenchContext {
+		if val, ok := file.Context[key]; ok {
+			config = append(config, key, fmt.Sprint(val))
+		}
+	}
+
+	var recs []benchfmt.Record
+	for i, b := range file.Benchmarks {
+		if b["run_type"] == "aggregate" {
+			continue
+		}
+		if b["error_occurred"] == true {
+			msg, _ := b["error_message"].(string)
+			recs = append(recs, &benchfmt.SyntaxError{FileName: path, Line: i + 1, Msg: "benchmark error: " + msg})
+			continue
+		}
+		name, _ := b["run_name"].(string)
+		if name == "" {
+			name, _ = b["name"].(string)
+		}
+		label1, _ := b["label"].(string)
+		res := newResult(gbenchName(name), label, append(config[:len(config):len(config)], "label", label1)...)
+		if iters, ok := b["iterations"].(float64); ok {
+			res.Iters = int(iters)
+		}
+
+		scale, ok := gbenchTimeScale[fmt.Sprint(b["time_unit"])]
+		if !ok {
+			scale = 1
+		}
+		if t, ok := b["real_time"].(float64); ok {
+			res.Values = append(res.Values, tidyValue(t*scale, "ns/op"))
+		}
+		if t, ok := b["cpu_time"].(float64); ok {
+			res.Values = append(res.Values, tidyValue(t*scale, "cpu-ns/op"))
+		}
+		// Counters are in map order. Sort them for determinism.
+		var counters []string
+		for k, v := range b {
+			if _, ok := v.(float64); ok && !slices.Contains(gbenchFields, k) {
+				counters = append(counters, k)
+			}
+		}
+		slices.Sort(counters)
+		for _, k := range counters {
+			unit := k
+			switch k {
+			case "bytes_per_second":
+				unit = "B/s"
+			case "items_per_second":
+				unit = "items/s"
+			}
+			res.Values = append(res.Values, tidyValue(b[k].(float64), unit))
+		}
+		if len(res.Values) == 0 {
+			recs = append(recs, &benchfmt.SyntaxError{FileName: path, Line: i + 1, Msg: "missing measurements"})
+			continue
+		}
+		recs = append(recs, res)
+	}
+	return &sliceReader{recs: recs}, nil
+}
+
+// gbenchName converts a Google Benchmark name to a Go benchmark name.
+func gbenchName(name string) string {
+	parts := strings.Split(name, "/")
+	arg := 0
+	for i, part := range parts[1:] {
+		if k, v, ok := strings.Cut(part, ":"); ok {
+			parts[i+1] = k + "=" + v
+		} else if _, err := strconv.ParseFloat(part, 64); err == nil {
+			parts[i+1] = fmt.Sprintf("arg%d=%s", arg, part)
+			arg++
+		}
+	}
+	return strings.Join(parts, "/")
+}
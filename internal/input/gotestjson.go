@@ -0,0 +1,105 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// A testJSONReader extracts the test output from a "go test -json" event
+// stream, producing the text that go test would have printed without -json.
+//
+// Output events from different packages may be interleaved, and may split a
+// single output line, so this accumulates output per package and only emits
+// complete lines.
+type testJSONReader struct {
+	s *bufio.Scanner
+
+	// pending is the incomplete last line of output from each package.
+	pending map[string][]byte
+	pkgs    []string // Keys of pending, in order of appearance
+
+	buf []byte // Complete output ready to be read
+	eof bool
+	err error // Error reading the events, returned once buf is empty
+}
+
+// testEvent is the subset of a test2json event we need.
+type testEvent struct {
+	Action  string
+	Package string
+	Output  *string
+}
+
+func newTestJSONReader(r io.Reader) *testJSONReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 16<<20)
+	return &testJSONReader{s: s, pending: make(map[string][]byte)}
+}
+
+func (r *testJSONReader) Read(b []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.eof {
+			return 0, io.EOF
+		}
+		r.fill()
+	}
+	n := copy(b, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// fill processes the next event into r.buf.
+func (r *testJSONReader) fill() {
+	if !r.s.Scan() {
+		if err := r.s.Err(); err != nil {
+			// Such as an event longer than the Scanner's
+			// buffer, which would otherwise end the input
+			// silently.
+			r.err = fmt.Errorf("reading go test -json events: %w", err)
+			return
+		}
+		// Flush incomplete lines.
+		for _, pkg := range r.pkgs {
+			if line := r.pending[pkg]; len(line) > 0 {
+				r.buf = append(r.buf, line...)
+				r.buf = append(r.buf, '\n')
+			}
+		}
+		r.eof = true
+		return
+	}
+
+	line := r.s.Bytes()
+	var ev testEvent
+	if len(line) == 0 || line[0] != '{' || json.Unmarshal(line, &ev) != nil {
+		// go test -json can print non-JSON lines, such as build
+		// errors. Pass these through.
+		r.buf = append(r.buf, line...)
+		r.buf = append(r.buf, '\n')
+		return
+	}
+	if ev.Action != "output" || ev.Output == nil {
+		return
+	}
+
+	if _, ok := r.pending[ev.Package]; !ok {
+		r.pkgs = append(r.pkgs, ev.Package)
+	}
+	out := append(r.pending[ev.Package], *ev.Output...)
+	if i := bytes.LastIndexByte(out, '\n'); i >= 0 {
+		r.buf = append(r.buf, out[:i+1]...)
+		out = slices.Clone(out[i+1:])
+	}
+	r.pending[ev.Package] = out
+}
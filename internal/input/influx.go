@@ -0,0 +1,247 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package input
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/perf/benchfmt"
+)
+
+// influxPrefix is the prefix of inputs that query an InfluxDB server.
+const influxPrefix = "influx:"
+
+// InfluxConfig describes how to query an InfluxDB server for "influx:tags"
+// inputs, and how to map the points it returns to benchmark results. The
+// defaults match the storage of the Go performance dashboard.
+//
+// Each point of Measurement in Bucket becomes one Result, named by the
+// NameTag tag. The Center field is its value in the unit given by the UnitTag
+// tag, and the Low and High fields, if present, are values in units with a
+// "low-" or "high-" prefix, like the bounds read from benchseries inputs. For
+// the Go performance dashboard, these summarize the change from a baseline
+// commit. The other tags become configuration keys, and the time of the
+// point becomes the "time" key, in seconds since the Unix epoch.
+type InfluxConfig struct {
+	// Server is the URL of the InfluxDB server, such as
+	// "http://localhost:8086". It must be set to read influx inputs.
+	Server string
+
+	// Token is the API token to authenticate with, if any, and Org is the
+	// organization to query.
+	Token string
+	Org   string
+
+	// Bucket and Measurement select the points to read. If "", they
+	// default to "perf" and "benchmark-result".
+	Bucket      string
+	Measurement string
+
+	// Start is the start of the time range to query, as a Flux duration
+	// relative to now, such as "-30d", or an RFC 3339 time. If "", it
+	// defaults to "-30d".
+	Start string
+
+	// NameTag and UnitTag are the tags giving the benchmark name and
+	// unit. If "", they default to "name" and "unit".
+	NameTag, UnitTag string
+
+	// Center, Low, and High are the fields giving the value and its
+	// bounds. If "", they default to "center", "low", and "high".
+	Center, Low, High string
+}
+
+func (c *InfluxConfig) withDefaults() InfluxConfig {
+	out := *c
+	def := func(s *string, d string) {
+		if *s == "" {
+			*s = d
+		}
+	}
+	def(&out.Bucket, "perf")
+	def(&out.Measurement, "benchmark-result")
+	def(&out.Start, "-30d")
+	def(&out.NameTag, "name")
+	def(&out.UnitTag, "unit")
+	def(&out.Center, "center")
+	def(&out.Low, "low")
+	def(&out.High, "high")
+	return out
+}
+
+// isInflux reports whether path is an InfluxDB query.
+func isInflux(path string) bool {
+	return strings.HasPrefix(path, influxPrefix)
+}
+
+// fluxString returns s as a Flux string literal.
+func fluxString(s string) string {
+	// Flux strings support the same escapes as Go for the characters
+	// strconv.Quote escapes, but "${" starts an interpolation.
+	return strings.ReplaceAll(strconv.Quote(s), "${", `\${`)
+}
+
+// influxQuery returns the Flux query for an input such as
+// "influx:goos=linux,pkg=runtime", which selects the points whose tags have
+// the given values. "influx:" selects all points.
+func influxQuery(cfg *InfluxConfig, path string) (string, error) {
+	var q strings.Builder
+	fmt.Fprintf(&q, "from(bucket: %s)\n", fluxString(cfg.Bucket))
+	start := cfg.Start
+	if _, err := time.Parse(time.RFC3339, start); err == nil {
+		start = "time(v: " + fluxString(start) + ")"
+	}
+	fmt.Fprintf(&q, "  |> range(start: %s)\n", start)
+	fmt.Fprintf(&q, "  |> filter(fn: (r) => r._measurement == %s)\n", fluxString(cfg.Measurement))
+	if tags := strings.TrimPrefix(path, influxPrefix); tags != "" {
+		for _, kv := range strings.Split(tags, ",") {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok || k == "" {
+				return "", fmt.Errorf("bad input %q: expected influx:tag=value,...", path)
+			}
+			fmt.Fprintf(&q, "  |> filter(fn: (r) => r[%s] == %s)\n", fluxString(k), fluxString(v))
+		}
+	}
+	q.WriteString(`  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")` + "\n")
+	return q.String(), nil
+}
+
+// queryInflux starts the query for influx input path and returns the
+// response body, which is CSV.
+func queryInflux(ctx context.Context, client *http.Client, cfg *InfluxConfig, header http.Header, path string) (io.ReadCloser, error) {
+	if cfg.Server == "" {
+		return nil, fmt.Errorf("%s: no InfluxDB server configured", path)
+	}
+	query, err := influxQuery(cfg, path)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]any{
+		"query":   query,
+		"type":    "flux",
+		"dialect": map[string]any{"header": true, "annotations": []string{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	u := strings.TrimSuffix(cfg.Server, "/") + "/api/v2/query?" + url.Values{"org": {cfg.Org}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/csv")
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+cfg.Token)
+	}
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		var e struct{ Message string }
+		if json.Unmarshal(msg, &e) == nil && e.Message != "" {
+			return nil, fmt.Errorf("%s: querying InfluxDB: %s: %s", path, resp.Status, e.Message)
+		}
+		return nil, fmt.Errorf("%s: querying InfluxDB: %s", path, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// influxSkipColumns are the columns of a query response that aren't tags or
+// fields.
+var influxSkipColumns = map[string]bool{
+	"": true, "result": true, "table": true, "_start": true, "_stop": true, "_measurement": true,
+}
+
+// readInflux reads the CSV response to an InfluxDB query. Each table in the
+// response starts with its own header row.
+func readInflux(r io.Reader, path, label string, cfg *InfluxConfig) (reader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	var recs []benchfmt.Record
+	var header []string
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: reading InfluxDB response: %w", path, err)
+		}
+		if len(row) > 2 && row[1] == "result" && row[2] == "table" {
+			header = row
+			continue
+		}
+		if header == nil {
+			return nil, fmt.Errorf("%s: InfluxDB response has no header", path)
+		}
+		var name, unit string
+		var config []string
+		vals := make(map[string]float64)
+		for i, col := range header {
+			if i >= len(row) || influxSkipColumns[col] {
+				continue
+			}
+			switch col {
+			case cfg.NameTag:
+				name = row[i]
+			case cfg.UnitTag:
+				unit = row[i]
+			case "_time":
+				t, err := time.Parse(time.RFC3339Nano, row[i])
+				if err != nil {
+					return nil, fmt.Errorf("%s: bad time %q: %w", path, row[i], err)
+				}
+				config = append(config, "time", strconv.FormatInt(t.Unix(), 10))
+			case cfg.Center, cfg.Low, cfg.High:
+				if row[i] == "" {
+					continue
+				}
+				v, err := strconv.ParseFloat(row[i], 64)
+				if err != nil {
+					return nil, fmt.Errorf("%s: bad %s %q: %w", path, col, row[i], err)
+				}
+				vals[col] = v
+			default:
+				config = append(config, col, row[i])
+			}
+		}
+		center, ok := vals[cfg.Center]
+		if !ok || name == "" || unit == "" {
+			// Not a result.
+			continue
+		}
+		res := newResult(name, label, config...)
+		res.Values = append(res.Values, benchfmt.Value{Value: center, Unit: unit})
+		if v, ok := vals[cfg.Low]; ok {
+			res.Values = append(res.Values, benchfmt.Value{Value: v, Unit: "low-" + unit})
+		}
+		if v, ok := vals[cfg.High]; ok {
+			res.Values = append(res.Values, benchfmt.Value{Value: v, Unit: "high-" + unit})
+		}
+		recs = append(recs, res)
+	}
+	return &sliceReader{recs: recs}, nil
+}
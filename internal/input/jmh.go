@@ -0,0 +1,174 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package input
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/perf/benchfmt"
+)
+
+// jmhResult is a single benchmark in JMH's JSON output (-rf json).
+type jmhResult struct {
+	Benchmark        string
+	Mode             string
+	Threads          int
+	Forks            int
+	JdkVersion       string
+	VMName           string
+	Params           map[string]string
+	PrimaryMetric    jmhMetric
+	SecondaryMetrics map[string]jmhMetric
+}
+
+type jmhMetric struct {
+	Score     float64
+	ScoreUnit string
+	RawData   [][]float64
+}
+
+// readJMH reads JMH results in either JSON (-rf json) or CSV (-rf csv) format.
+//
+// Benchmark parameters become name keys, so a benchmark "org.Foo.bar" with
+// parameter size=10 becomes "org.Foo.bar/size=10". The benchmark mode, thread
+// count, and JVM become configuration keys. JSON results include each
+// iteration's measurement, so each iteration becomes a separate Result. CSV
+// results only include the score, which becomes a single Result.
+func readJMH(r io.Reader, path, label string) (reader, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err != nil || (b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n') {
+			if err == nil && b[0] == '[' {
+				return readJMHJSON(br, path, label)
+			}
+			return readJMHCSV(br, path, label)
+		}
+		br.ReadByte()
+	}
+}
+
+func readJMHJSON(r io.Reader, path, label string) (reader, error) {
+	var results []jmhResult
+	if err := json.NewDecoder(r).Decode(&results); err != nil {
+		return nil, fmt.Errorf("%s: parsing JMH JSON: %w", path, err)
+	}
+
+	var recs []benchfmt.Record
+	for _, jr := range results {
+		name := jmhName(jr.Benchmark, jr.Params)
+		config := []string{"mode", jr.Mode, "threads", strconv.Itoa(jr.Threads), "jdk", jr.JdkVersion, "vm", jr.VMName}
+		secondary := sortedKeys(jr.SecondaryMetrics)
+
+		// Produce a Result for each iteration of each fork.
+		for fork, iters := range jr.PrimaryMetric.RawData {
+			for iter, val := range iters {
+				res := newResult(name, label, config...)
+				res.Values = append(res.Values, jmhValue(val, jr.PrimaryMetric.ScoreUnit))
+				for _, k := range secondary {
+					m := jr.SecondaryMetrics[k]
+					if fork < len(m.RawData) && iter < len(m.RawData[fork]) {
+						res.Values = append(res.Values, jmhValue(m.RawData[fork][iter], jmhSecondaryUnit(k, m.ScoreUnit)))
+					}
+				}
+				recs = append(recs, res)
+			}
+		}
+		if len(jr.PrimaryMetric.RawData) == 0 {
+			// Fall back to the score.
+			res := newResult(name, label, config...)
+			res.Values = append(res.Values, jmhValue(jr.PrimaryMetric.Score, jr.PrimaryMetric.ScoreUnit))
+			recs = append(recs, res)
+		}
+	}
+	return &sliceReader{recs: recs}, nil
+}
+
+func readJMHCSV(r io.Reader, path, label string) (reader, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading JMH CSV header: %w", path, err)
+	}
+	cols := make(map[string]int)
+	for i, col := range header {
+		cols[col] = i
+	}
+	for _, col := range []string{"Benchmark", "Mode", "Threads", "Score", "Unit"} {
+		if _, ok := cols[col]; !ok {
+			return nil, fmt.Errorf("%s: JMH CSV missing %q column", path, col)
+		}
+	}
+
+	var recs []benchfmt.Record
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		params := make(map[string]string)
+		for i, col := range header {
+			if k, ok := strings.CutPrefix(col, "Param: "); ok && row[i] != "" {
+				params[k] = row[i]
+			}
+		}
+		bench, unit := row[cols["Benchmark"]], row[cols["Unit"]]
+		if b, secondary, ok := strings.Cut(bench, ":"); ok {
+			bench, unit = b, jmhSecondaryUnit(secondary, unit)
+		}
+		score, err := strconv.ParseFloat(row[cols["Score"]], 64)
+		if err != nil {
+			recs = append(recs, &benchfmt.SyntaxError{FileName: path, Line: line, Msg: "parsing score: " + err.Error()})
+			continue
+		}
+		res := newResult(jmhName(bench, params), label, "mode", row[cols["Mode"]], "threads", row[cols["Threads"]])
+		res.Values = append(res.Values, jmhValue(score, unit))
+		recs = append(recs, res)
+	}
+	return &sliceReader{recs: recs}, nil
+}
+
+func jmhName(bench string, params map[string]string) string {
+	var name strings.Builder
+	name.WriteString(bench)
+	for _, k := range sortedKeys(params) {
+		fmt.Fprintf(&name, "/%s=%s", k, params[k])
+	}
+	return name.String()
+}
+
+// jmhSecondaryUnit returns the unit for a secondary metric, such as
+// "gc.alloc.rate:MB/sec".
+func jmhSecondaryUnit(metric, unit string) string {
+	// JMH prefixes profiler metrics with a middle dot.
+	metric = strings.TrimPrefix(metric, "·")
+	return metric + ":" + unit
+}
+
+// jmhTimeScale converts from JMH time units to nanoseconds.
+var jmhTimeScale = map[string]float64{"ns": 1, "us": 1e3, "ms": 1e6, "s": 1e9, "min": 60e9}
+
+// jmhValue converts a JMH measurement into Go benchmark units. Times per
+// operation are converted to ns/op, and rates are converted to ops/s.
+func jmhValue(val float64, unit string) benchfmt.Value {
+	if num, denom, ok := strings.Cut(unit, "/"); ok {
+		if scale, ok := jmhTimeScale[num]; ok && denom == "op" {
+			return tidyValue(val*scale, "ns/op")
+		}
+		if scale, ok := jmhTimeScale[denom]; ok {
+			return benchfmt.Value{Value: val * 1e9 / scale, Unit: num + "/s", OrigValue: val, OrigUnit: unit}
+		}
+	}
+	return tidyValue(val, unit)
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/perf/benchfmt"
+)
+
+// pytestFile is the JSON output of pytest-benchmark (--benchmark-json).
+type pytestFile struct {
+	MachineInfo map[string]any `json:"machine_info"`
+	CommitInfo  map[string]any `json:"commit_info"`
+	Benchmarks  []struct {
+		Group  string
+		Name   string
+		Params map[string]any
+		Stats  struct {
+			Min, Max, Mean, Stddev, Median, Ops float64
+			Rounds                              int
+			Iterations                          int
+			Data                                []float64
+		}
+	}
+}
+
+// pytestMachine and pytestCommit list the machine_info and commit_info fields
+// we turn into configuration keys, and the key names we give them.
+var (
+	pytestMachine = []string{"python_implementation", "python", "python_version", "python-version", "system", "system", "machine", "machine", "node", "node"}
+	pytestCommit  = []string{"id", "commit", "branch", "branch"}
+)
+
+// readPytest reads pytest-benchmark JSON output.
+//
+// Test parameters become name keys, so "test_foo[10]" with parameter n=10
+// becomes "test_foo/n=10", and the benchmark group, machine, and commit become
+// configuration keys. If the output includes the raw timing data
+// (--benchmark-save-data), each round becomes a Result in sec/op. Otherwise,
+// each benchmark becomes a single Result with the mean in sec/op, plus the
+// other summary statistics in units like "median-sec/op" and the rate in
+// ops/s.
+func readPytest(r io.Reader, path, label string) (reader, error) {
+	var file pytestFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: parsing pytest-benchmark JSON: %w", path, err)
+	}
+
+	var config []string
+	addConfig := func(info map[string]any, fields []string) {
+		for i := 0; i < len(fields); i += 2 {
+			if val, ok := info[fields[i]]; ok && val != nil {
+				config = append(config, fields[i+1], fmt.Sprint(val))
+			}
+		}
+	}
+	addConfig(file.MachineInfo, pytestMachine)
+	addConfig(file.CommitInfo, pytestCommit)
+
+	var recs []benchfmt.Record
+	for _, b := range file.Benchmarks {
+		// Strip the parameter ID, since we add the individual
+		// parameters as keys.
+		name, _, _ := strings.Cut(b.Name, "[")
+		for _, k := range sortedKeys(b.Params) {
+			name += fmt.Sprintf("/%s=%v", k, b.Params[k])
+		}
+		config1 := append(config[:len(config):len(config)], "group", b.Group)
+
+		if len(b.Stats.Data) > 0 {
+			for _, sec := range b.Stats.Data {
+				res := newResult(name, label, config1...)
+				res.Iters = max(1, b.Stats.Iterations)
+				res.Values = append(res.Values, benchfmt.Value{Value: sec, Unit: "sec/op"})
+				recs = append(recs, res)
+			}
+			continue
+		}
+
+		st := &b.Stats
+		res := newResult(name, label, config1...)
+		res.Iters = max(1, st.Rounds*st.Iterations)
+		res.Values = append(res.Values,
+			benchfmt.Value{Value: st.Mean, Unit: "sec/op"},
+			benchfmt.Value{Value: st.Median, Unit: "median-sec/op"},
+			benchfmt.Value{Value: st.Min, Unit: "min-sec/op"},
+			benchfmt.Value{Value: st.Max, Unit: "max-sec/op"},
+			benchfmt.Value{Value: st.Stddev, Unit: "stddev-sec/op"},
+			benchfmt.Value{Value: st.Ops, Unit: "ops/s"},
+		)
+		recs = append(recs, res)
+	}
+	return &sliceReader{recs: recs}, nil
+}
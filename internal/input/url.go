@@ -0,0 +1,73 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package input
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// fetchTimeout bounds each fetch made with defaultClient, including reading
+// the response, so an unresponsive server can't hang the reader forever.
+const fetchTimeout = 5 * time.Minute
+
+// defaultClient is the client for fetches when [Files.Client] is nil.
+var defaultClient = &http.Client{Timeout: fetchTimeout}
+
+// isURL reports whether path should be fetched over HTTP.
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// fetch starts fetching url and returns the response body.
+func fetch(ctx context.Context, client *http.Client, url string, header http.Header) (io.ReadCloser, error) {
+	if client == nil {
+		client = defaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// perfDataPrefix is the prefix of inputs that query a perf data server.
+const perfDataPrefix = "perfdata:"
+
+// DefaultPerfDataServer is the default perf data server to query.
+const DefaultPerfDataServer = "https://perfdata.golang.org"
+
+// isPerfData reports whether path is a perf data server query.
+func isPerfData(path string) bool {
+	return strings.HasPrefix(path, perfDataPrefix)
+}
+
+// perfDataURL returns the URL for fetching the results of a perf data server
+// query such as "perfdata:upload:20240101.1".
+func perfDataURL(server, path string) string {
+	if server == "" {
+		server = DefaultPerfDataServer
+	}
+	q := strings.TrimPrefix(path, perfDataPrefix)
+	return strings.TrimSuffix(server, "/") + "/search?" + url.Values{"q": []string{q}}.Encode()
+}
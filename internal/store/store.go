@@ -0,0 +1,300 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package store is a local sqlite database of parsed benchmark results.
+//
+// A store lets large benchmark histories be accumulated over time and
+// queried without re-parsing the original inputs.
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"golang.org/x/perf/benchfmt"
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS records (
+	id INTEGER PRIMARY KEY,
+	name BLOB NOT NULL,
+	iters INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS config (
+	record INTEGER NOT NULL REFERENCES records(id),
+	pos INTEGER NOT NULL,
+	key TEXT NOT NULL,
+	value BLOB NOT NULL,
+	file INTEGER NOT NULL,
+	PRIMARY KEY (record, pos)
+);
+CREATE INDEX IF NOT EXISTS config_kv ON config(key, value);
+CREATE TABLE IF NOT EXISTS vals (
+	record INTEGER NOT NULL REFERENCES records(id),
+	pos INTEGER NOT NULL,
+	unit TEXT NOT NULL,
+	value REAL NOT NULL,
+	orig_unit TEXT NOT NULL,
+	orig_value REAL NOT NULL,
+	PRIMARY KEY (record, pos)
+);
+CREATE TABLE IF NOT EXISTS units (
+	unit TEXT NOT NULL,
+	key TEXT NOT NULL,
+	orig_unit TEXT NOT NULL,
+	value TEXT NOT NULL,
+	PRIMARY KEY (unit, key)
+);
+`
+
+// A DB is a store of benchmark results.
+type DB struct {
+	db *sql.DB
+}
+
+// Open opens the store at path, creating it if necessary.
+func Open(path string) (*DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: initializing database: %w", path, err)
+	}
+	return &DB{db}, nil
+}
+
+// Close closes the store.
+func (db *DB) Close() error {
+	return db.db.Close()
+}
+
+// An Importer adds results to a store in a single transaction.
+type Importer struct {
+	tx                     *sql.Tx
+	insRec, insCfg, insVal *sql.Stmt
+	n                      int
+}
+
+// Import starts importing results into db. The caller must call Commit to
+// finish the import.
+func (db *DB) Import() (*Importer, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	imp := &Importer{tx: tx}
+	for _, s := range []struct {
+		stmt **sql.Stmt
+		sql  string
+	}{
+		{&imp.insRec, "INSERT INTO records (name, iters) VALUES (?, ?)"},
+		{&imp.insCfg, "INSERT INTO config (record, pos, key, value, file) VALUES (?, ?, ?, ?, ?)"},
+		{&imp.insVal, "INSERT INTO vals (record, pos, unit, value, orig_unit, orig_value) VALUES (?, ?, ?, ?, ?, ?)"},
+	} {
+		*s.stmt, err = tx.Prepare(s.sql)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return imp, nil
+}
+
+// Add adds a single result.
+func (imp *Importer) Add(res *benchfmt.Result) error {
+	r, err := imp.insRec.Exec([]byte(res.Name), res.Iters)
+	if err != nil {
+		return err
+	}
+	id, err := r.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for i, cfg := range res.Config {
+		if _, err := imp.insCfg.Exec(id, i, cfg.Key, cfg.Value, cfg.File); err != nil {
+			return err
+		}
+	}
+	for i, val := range res.Values {
+		if _, err := imp.insVal.Exec(id, i, val.Unit, val.Value, val.OrigUnit, val.OrigValue); err != nil {
+			return err
+		}
+	}
+	imp.n++
+	return nil
+}
+
+// Len returns the number of results added so far.
+func (imp *Importer) Len() int {
+	return imp.n
+}
+
+// Commit adds units to the store's unit metadata and commits the import.
+func (imp *Importer) Commit(units benchfmt.UnitMetadataMap) error {
+	for k, u := range units {
+		_, err := imp.tx.Exec("INSERT OR REPLACE INTO units (unit, key, orig_unit, value) VALUES (?, ?, ?, ?)", k.Unit, k.Key, u.OrigUnit, u.Value)
+		if err != nil {
+			imp.tx.Rollback()
+			return err
+		}
+	}
+	return imp.tx.Commit()
+}
+
+// Rollback abandons the import.
+func (imp *Importer) Rollback() error {
+	return imp.tx.Rollback()
+}
+
+// Query returns a reader over the results in db whose configuration matches
+// every key=value pair in where. If where is empty, it returns all results.
+func (db *DB) Query(where map[string]string) *Reader {
+	q := "SELECT id, name, iters FROM records"
+	var args []any
+	var conds []string
+	for k, v := range where {
+		conds = append(conds, "id IN (SELECT record FROM config WHERE key = ? AND value = ?)")
+		args = append(args, k, []byte(v))
+	}
+	if len(conds) > 0 {
+		q += " WHERE " + strings.Join(conds, " AND ")
+	}
+	q += " ORDER BY id"
+
+	r := &Reader{db: db}
+	r.units, r.err = db.units()
+	if r.err != nil {
+		return r
+	}
+	r.rows, r.err = db.db.Query(q, args...)
+	if r.err == nil {
+		r.cfgStmt, r.err = db.db.Prepare("SELECT key, value, file FROM config WHERE record = ? ORDER BY pos")
+	}
+	if r.err == nil {
+		r.valStmt, r.err = db.db.Prepare("SELECT unit, value, orig_unit, orig_value FROM vals WHERE record = ? ORDER BY pos")
+	}
+	return r
+}
+
+func (db *DB) units() (benchfmt.UnitMetadataMap, error) {
+	rows, err := db.db.Query("SELECT unit, key, orig_unit, value FROM units")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	units := make(benchfmt.UnitMetadataMap)
+	for rows.Next() {
+		u := new(benchfmt.UnitMetadata)
+		if err := rows.Scan(&u.Unit, &u.Key, &u.OrigUnit, &u.Value); err != nil {
+			return nil, err
+		}
+		units[u.UnitMetadataKey] = u
+	}
+	return units, rows.Err()
+}
+
+// A Reader reads results from a store. Its methods have the same meaning as
+// those of [benchfmt.Files].
+type Reader struct {
+	db               *DB
+	rows             *sql.Rows
+	cfgStmt, valStmt *sql.Stmt
+	units            benchfmt.UnitMetadataMap
+
+	res benchfmt.Result
+	err error
+}
+
+func (r *Reader) Scan() bool {
+	if r.err != nil {
+		return false
+	}
+	if !r.rows.Next() {
+		r.err = r.rows.Err()
+		r.Close()
+		return false
+	}
+	var id int64
+	var name []byte
+	res := &r.res
+	// The Result's configuration index can't be reset, so start fresh.
+	*res = benchfmt.Result{Values: res.Values[:0]}
+	if r.err = r.rows.Scan(&id, &name, &res.Iters); r.err != nil {
+		return false
+	}
+	res.Name = name
+	if r.err = r.scanConfig(id); r.err != nil {
+		return false
+	}
+	if r.err = r.scanValues(id); r.err != nil {
+		return false
+	}
+	return true
+}
+
+func (r *Reader) scanConfig(id int64) error {
+	rows, err := r.cfgStmt.Query(id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cfg benchfmt.Config
+		if err := rows.Scan(&cfg.Key, &cfg.Value, &cfg.File); err != nil {
+			return err
+		}
+		r.res.Config = append(r.res.Config, cfg)
+	}
+	return rows.Err()
+}
+
+func (r *Reader) scanValues(id int64) error {
+	rows, err := r.valStmt.Query(id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var val benchfmt.Value
+		if err := rows.Scan(&val.Unit, &val.Value, &val.OrigUnit, &val.OrigValue); err != nil {
+			return err
+		}
+		r.res.Values = append(r.res.Values, val)
+	}
+	return rows.Err()
+}
+
+// Result returns the result that was just read by Scan. The caller should not
+// retain the Result, as it will be overwritten by the next call to Scan.
+func (r *Reader) Result() benchfmt.Record {
+	return &r.res
+}
+
+// Err returns the error that stopped Scan, if any.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Units returns the unit metadata in the store.
+func (r *Reader) Units() benchfmt.UnitMetadataMap {
+	return r.units
+}
+
+// Close releases the resources of r. It's called automatically when Scan
+// reaches the end of the results.
+func (r *Reader) Close() {
+	if r.rows != nil {
+		r.rows.Close()
+	}
+	if r.cfgStmt != nil {
+		r.cfgStmt.Close()
+	}
+	if r.valStmt != nil {
+		r.valStmt.Close()
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// keepTempPrefix returns the path prefix in dir for the files kept for the
+// plot written to output. For example, the files for "out/plot.png" are
+// dir/plot.gp and dir/plot.yaml.
+func keepTempPrefix(dir, output string) string {
+	base := filepath.Base(output)
+	return filepath.Join(dir, strings.TrimSuffix(base, filepath.Ext(base)))
+}
+
+// resolvedConfig returns the values of flags that differ from their defaults
+// and the inputs in the format read by loadConfigFile. This records the
+// combined effect of the default flags, the config file, any preset, and the
+// command line.
+func resolvedConfig(flags *flag.FlagSet, inputs []string) ([]byte, error) {
+	spec := make(map[string]any)
+	flags.VisitAll(func(f *flag.Flag) {
+		switch f.Name {
+		case "c", "keep-temp":
+			// The config file has already been applied, and
+			// rendering with the kept config shouldn't
+			// overwrite it.
+			return
+		}
+		if l, ok := f.Value.(*stringList); ok {
+			if len(*l) > 0 {
+				spec[f.Name] = []string(*l)
+			}
+			return
+		}
+		if val := f.Value.String(); val != f.DefValue {
+			spec[f.Name] = val
+		}
+	})
+	if len(inputs) > 0 {
+		spec["inputs"] = inputs
+	}
+	return yaml.Marshal(spec)
+}
@@ -5,21 +5,37 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"maps"
+	"math"
+	"net/http"
 	"os"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/aclements/benchplot/internal/plot"
+	"github.com/aclements/benchplot/internal/input"
+	"github.com/aclements/benchplot/internal/store"
+	"github.com/aclements/benchplot/plot"
 	"golang.org/x/perf/benchfmt"
 	"golang.org/x/perf/benchproc"
 )
 
+// subcommands lists the benchplot subcommands. The default is "plot".
+var subcommands = []string{"plot", "compare", "inspect", "export", "serve", "import", "query"}
+
 func main() {
-	if err := benchplot(os.Stdout, os.Stderr, os.Args[1:]); err != nil {
+	cmd, args := "plot", os.Args[1:]
+	if len(args) > 0 && slices.Contains(subcommands, args[0]) {
+		cmd, args = args[0], args[1:]
+	}
+	if err := benchplot(os.Stdout, os.Stderr, cmd, args); err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}
@@ -39,17 +55,24 @@ var aesFlags = []aesFlag{
 	{plot.AesCol, "", "map values of `projection` to facet columns"},
 }
 
-type transformOpt struct {
-	doc string
-	do  func(p *plot.Plot) error
+// maxFacets is the most facets a plot may have without -force.
+const maxFacets = 100
+
+type presetOpt struct {
+	doc   string
+	flags []string // Alternating flag names and values
 }
 
-var transformOpts = map[string]transformOpt{
-	"compare": {"normalize each value against the first value at the same X",
-		(*plot.Plot).TransformCompare},
+var presetOpts = map[string]presetOpt{
+	"history": {"plot each benchmark over time, with a row per unit",
+		[]string{"x", "commit-date", "color", ".fullname", "row", ".unit"}},
+	"compare": {"compare each input file against the first, with a row per unit",
+		[]string{"color", ".file", "row", ".unit", "transform", "compare"}},
 }
 
-func benchplot(w, wErr io.Writer, args []string) error {
+// benchplot runs benchplot subcommand cmd with the given arguments. All
+// subcommands share the same flags and the same ingestion of their inputs.
+func benchplot(w, wErr io.Writer, cmd string, args []string) error {
 	flags := flag.NewFlagSet("", flag.ExitOnError)
 	flags.SetOutput(wErr)
 
@@ -60,7 +83,40 @@ func benchplot(w, wErr io.Writer, args []string) error {
 	aesFlagSet.SetOutput(wErr)
 
 	flags.Usage = func() {
-		fmt.Fprintf(wErr, `Usage: benchplot [flags] inputs...
+		fmt.Fprintf(wErr, `Usage: benchplot [plot] [flags] inputs...
+       benchplot compare [flags] old new
+       benchplot inspect [flags] inputs...
+       benchplot export [flags] inputs...
+       benchplot serve [flags] inputs...
+       benchplot import [flags] db inputs...
+       benchplot query [flags] db
+
+The plot subcommand, which is the default, plots the results in inputs. The
+compare subcommand plots the change of each benchmark from input old to
+input new as a dot, with a row per unit, and writes it to old-vs-new.png;
+it's shorthand for plot with -preset compare -geom dot-interval. The
+inspect subcommand lists the configuration keys, name keys, and units in
+inputs, with the most common values of each key, to help choose projections
+and filters. The export subcommand writes the results in inputs to stdout in
+the Go benchmark format, after filtering and rewriting them as for plotting.
+The serve subcommand reads inputs once and serves a web UI on -http for
+exploring them, where the aesthetics, filter, and transforms can be changed
+from menus and the plot re-renders. Results in the Go benchmark format
+POSTed to /results are added to the plot, and open pages update their plot
+as the results change. The server also serves the plot as /plot.png, where
+query parameters such as ?x=.fullname&transform=compare set plot flags, for
+embedding plots by URL, and likewise as /plot.svg, and the plotted points as
+/export.csv and as Prometheus metrics at /metrics, for monitoring systems.
+With -views, the current view can be saved by name, and /view/name links to
+it.
+
+The import subcommand adds the results from inputs to the sqlite database db,
+creating it if necessary. The query subcommand plots the results in db.
+
+With -i, benchplot reads its inputs once and then reads commands such as
+"x=.fullname", "color=goos", and "render" from stdin, so different aesthetics
+can be tried without re-reading the inputs.
+
 `)
 		mainFlagSet.PrintDefaults()
 
@@ -79,21 +135,42 @@ For the syntax of projections, see
 
 In addition, any projection may be one of the following:
 
-  .unit    The unit of each benchmark-reported metric
-  .value   The value of the metric corresponding to .unit
-  .residue All fields that were not in some other projection
+  .unit     The unit of each benchmark-reported metric
+  .value    The value of the metric corresponding to .unit
+  .residue  All fields that were not in some other projection
+  .filetime The date of each input file, in seconds since 1970, from
+            a date in its name such as 2006-01-02, or else its mtime.
+            It's not part of .residue
 `)
 
 		// Print transforms.
 		fmt.Fprintf(wErr, "\nTransformations:\n")
-		var names []string
-		for name := range transformOpts {
+		names := plot.TransformNames()
+		for _, name := range names {
+			fmt.Fprintf(wErr, "  %s\n    \t%s\n", name, plot.TransformDoc(name))
+		}
+
+		// Print presets.
+		fmt.Fprintf(wErr, "\nPresets:\n")
+		names = names[:0]
+		for name := range presetOpts {
 			names = append(names, name)
 		}
 		slices.Sort(names)
 		for _, name := range names {
-			fmt.Fprintf(wErr, "  %s\n    \t%s\n", name, transformOpts[name].doc)
+			p := presetOpts[name]
+			var flags []string
+			for i := 0; i < len(p.flags); i += 2 {
+				flags = append(flags, fmt.Sprintf("-%s %s", p.flags[i], p.flags[i+1]))
+			}
+			fmt.Fprintf(wErr, "  %s\n    \t%s\n    \t(%s)\n", name, p.doc, strings.Join(flags, " "))
 		}
+
+		fmt.Fprintf(wErr, `
+Default flags are read from %s, one per line, and then
+from the %s environment variable, separated by spaces. Flags given on
+the command line, by -c, or by -preset override the defaults.
+`, defaultsPath(), defaultsEnv)
 	}
 
 	// Register aesthetic flags.
@@ -120,8 +197,84 @@ In addition, any projection may be one of the following:
 	// This is a convenience filter, since if you want to filter on anything,
 	// it's usually this.
 	flagUnits := mainFlagSet.String("unit", "", "comma-separated list of `units` to show")
+	flagInclude := mainFlagSet.String("include", "", "comma-separated file name `patterns` to read from directory inputs (default all files)")
+	var flagHeaders stringList
+	mainFlagSet.Var(&flagHeaders, "header", "send `name: value` header when fetching URL inputs (may be repeated)")
+	flagPerfData := mainFlagSet.String("perfdata", input.DefaultPerfDataServer, "perf data server `URL` for perfdata:query inputs")
+	flagInflux := mainFlagSet.String("influx", "", "InfluxDB server `URL` for influx:tag=value,... inputs\nThe API token is read from $INFLUX_TOKEN")
+	flagInfluxOrg := mainFlagSet.String("influx-org", "", "InfluxDB `organization` to query")
+	flagInfluxBucket := mainFlagSet.String("influx-bucket", "perf", "InfluxDB `bucket` to query")
+	flagInfluxMeasurement := mainFlagSet.String("influx-measurement", "benchmark-result", "InfluxDB `measurement` giving benchmark results")
+	flagInfluxStart := mainFlagSet.String("influx-start", "-30d", "read InfluxDB points since `time`, as a duration before now or an RFC 3339 time")
+	flagFormat := mainFlagSet.String("format", input.Formats[0], "read inputs in `format`: "+strings.Join(input.Formats, ", "))
+	flagCSVName := mainFlagSet.String("csv-name", "name", "CSV `column` giving the benchmark name")
+	flagCSVKeys := mainFlagSet.String("csv-keys", "", "comma-separated CSV `columns` giving configuration keys")
+	flagCSVValues := mainFlagSet.String("csv-values", "", "comma-separated CSV `columns` giving measurements\nUse column=unit to set a unit other than the column name")
+	flagCache := mainFlagSet.String("cache", "", "cache the results parsed from input files in `dir`, so re-reading unchanged files is faster\nEntries are keyed by file content and never removed")
+	var flagFileKeys stringList
+	mainFlagSet.Var(&flagFileKeys, "file-key", "add configuration to every result from an input, as `input:key=value,...` (may be repeated)")
+	var flagExtracts stringList
+	mainFlagSet.Var(&flagExtracts, "extract", "derive keys from the named groups of a regexp, as `source:/regexp/`\nsource is \"name\" for the full benchmark name, or a configuration key (may be repeated)")
+	var flagMaps stringList
+	mainFlagSet.Var(&flagMaps, "map", "rename values of a key before filtering and projection, as `key: old=new, ...`\nkey may be a configuration key or a /name key (may be repeated)")
+	flagGitCommits := mainFlagSet.String("git-commits", "", "resolve the commit hashes in configuration `key` using the git repository in -git-dir,\nadding key-order, each commit's position in the history, key-date, its commit time in Unix seconds,\nand key-ref, its tag or short hash, so history can be plotted in commit order")
+	flagGitDir := mainFlagSet.String("git-dir", ".", "read the history for -git-commits from the git repository in `dir`")
+	flagDedup := mainFlagSet.Bool("dedup", false, "drop records identical to an earlier record")
+	flagSample := mainFlagSet.Float64("sample", 1, "keep a random `fraction` of records, for quick plots of huge inputs")
+	flagSeed := mainFlagSet.Uint64("seed", 1, "random `seed` for -sample")
+	flagStrict := mainFlagSet.Bool("strict", false, "fail on malformed input and filter errors instead of warning")
+	flagErrorSummary := mainFlagSet.Bool("error-summary", false, "print a summary of input errors grouped by file instead of each error")
+	flagErrorLog := mainFlagSet.String("error-log", "", "write every input error to `file` as JSON, one per line")
+	flagWarnings := mainFlagSet.String("warnings", "text", "write warnings in `format`: text, or json for one JSON object per line\nJSON warnings also report residue mismatches and small samples in each point")
+	flagWarningsFile := mainFlagSet.String("warnings-file", "", "write warnings to `file`, or to file descriptor N for fd:N (default stderr)")
+	flagWhere := mainFlagSet.String("where", "", "for query, use only results with configuration matching comma-separated `key=value` pairs\nThis is faster than -filter because it is done by the database")
 	flagLogScale := mainFlagSet.String("log-scale", "", "comma-separated `list` of options to plot on a log scale\nUse name:base to set a log base other than 10")
+	flagOrdinalX := mainFlagSet.String("ordinal-x", "", "space the X values evenly in order, such as a sequence of commits, labeling each with the values of `keys`\nWith -git-commits, use -x key-order and -ordinal-x key-ref")
+	flagBreakY := mainFlagSet.Bool("break-y", false, "break the Y axis of each row of facets whose values fall in two clusters far apart,\nso series orders of magnitude smaller than the rest aren't flattened")
+	flagDropEmpty := mainFlagSet.Bool("drop-empty", false, "drop facets with no data and wrap the rest into a smaller grid, titling each with its row and column")
+	flagCaption := mainFlagSet.Bool("caption", false, "draw a caption under the plot giving its filter, inputs, number of results, and date,\nso the plot can be understood without the command that made it")
+	flagWatermark := mainFlagSet.String("watermark", "", "draw `text`, such as a team name or dashboard URL, in small type in the bottom right corner of the plot")
+	flagDirectLabels := mainFlagSet.Bool("direct-labels", false, "label each series at the right end of its line instead of in a key")
+	flagAnomalies := mainFlagSet.Float64("anomalies", 0, "mark and report each point more than `sigma` times its series' noise from the median of the points before it\nThe noise is estimated from the differences between consecutive points (0 to disable)")
+	flagDiff := mainFlagSet.String("diff", "", "draw a panel beneath each facet showing the percent change from one color to another, as `base,target`\nThe panel shares the facet's X axis")
+	var flagVLines stringList
+	mainFlagSet.Var(&flagVLines, "vline", "draw a vertical line across every facet, as `x=value[:label=text]` (may be repeated)")
+	var flagHLines, flagHBands stringList
+	mainFlagSet.Var(&flagHLines, "hline", "draw a horizontal line across every facet, such as a target, as `y=value[:label=text]` (may be repeated)")
+	mainFlagSet.Var(&flagHBands, "hband", "shade a band across every facet between two Y values, as `lo:hi[:label=text]` (may be repeated)")
+	var flagRegions stringList
+	mainFlagSet.Var(&flagRegions, "region", "shade a region across every facet between two X values, such as a range of commits, as `x1:x2[:label]` (may be repeated)")
+	var flagAnnotations stringList
+	mainFlagSet.Var(&flagAnnotations, "annotate", "draw text at a point in data coordinates, as `x=value:y=value:label=text` (may be repeated)\nBefore label, arrow=true sets off the text with an arrow, and row=label and col=label restrict it to a facet")
 	flagTransform := mainFlagSet.String("transform", "", "comma-separated `list` of data transformations")
+	flagBetter := mainFlagSet.String("better", "", "comma-separated `unit=higher|lower` pairs giving the direction of improvement for units\nThis overrides or supplies \"better\" unit metadata for comparisons")
+	flagStream := mainFlagSet.Bool("stream", false, "summarize data as it is read to bound memory use on very large inputs\nThis is incompatible with transforms that need every measurement, such as cov.\nGroups of more than 1024 values are summarized from a sample, which is the same on every run")
+	flagStreamMean := mainFlagSet.Bool("stream-mean", false, "like -stream, but summarize each group by the mean of all of its measurements\nrather than the median of a sample of them, assuming they are normally distributed")
+	flagNoisiest := mainFlagSet.Int("noisiest", 10, "keep the `n` noisiest series in the noisiest transform (0 for all)")
+	flagNoiseReport := mainFlagSet.String("noise-report", "", "write the ranking computed by the noisiest transform as JSON to `file`")
+	flagTable := mainFlagSet.Bool("table", false, "also print a benchstat-style table comparing each color against the first to stdout, or to stderr with -print\nThe table is computed before any transforms")
+	flagTableFormat := mainFlagSet.String("table-format", "text", "print the -table in `format`: text or markdown")
+	flagQuiet := mainFlagSet.Bool("q", false, "print only errors, suppressing warnings and filtering statistics")
+	flagVerbose := mainFlagSet.Bool("v", false, "report how the data was grouped into each facet, series, and point")
+	flagVersion := mainFlagSet.Bool("version", false, "print version information and exit")
+	flagPreset := mainFlagSet.String("preset", "", "set defaults from the named `preset` (see below)")
+	flagConfig := mainFlagSet.String("c", "", "read flags and inputs from the YAML plot specification in `file`\nFlags given on the command line override the file")
+	flagOutput := mainFlagSet.String("o", "benchplot.png", "write the plot to `file`")
+	flagPrint := mainFlagSet.Bool("print", false, "write the gnuplot script to stdout instead of rendering the plot")
+	flagFailRegression := mainFlagSet.Float64("fail-regression", 0, "with the compare transform, exit with status 1 if any change is a statistically significant\nregression by more than `percent`, after writing the plot (0 to disable)")
+	flagForce := mainFlagSet.Bool("force", false, fmt.Sprintf("render plots with more than %d facets, which usually means -row or -col maps a key with too many values", maxFacets))
+	flagDryRun := mainFlagSet.Bool("n", false, "print the number of facets, series, and points in the plot instead of rendering it")
+	flagFollow := mainFlagSet.Bool("follow", false, "read a growing input as it is written and show the plot in a window, updating it as results arrive")
+	flagWatch := mainFlagSet.Bool("watch", false, "re-render the plot whenever an input file changes\nWith serve, this updates the plot in open pages")
+	flagGeom := mainFlagSet.String("geom", "line", "draw the points of each series with `geometry`: line, or dot-interval for dots with error bars\nDots allow non-numeric X values, such as benchmark names, which are spaced evenly")
+	flagDirection := mainFlagSet.String("direction", "both", "highlight only `direction` of change in comparisons: both, regressions, or improvements")
+	flagHighlight := mainFlagSet.String("highlight", "", "draw the series of results matching benchfilter `query` with thicker lines and dim the rest")
+	flagInteractive := mainFlagSet.Bool("i", false, "read the inputs once, then read commands from stdin to change plot flags and re-render")
+	flagProgress := mainFlagSet.Bool("progress", false, "periodically report progress reading the inputs to stderr")
+	flagKeepTemp := mainFlagSet.String("keep-temp", "", "save the gnuplot script and resolved flags for each plot in `dir`, for debugging")
+	flagGnuplot := mainFlagSet.String("gnuplot", "", "run the gnuplot binary at `path` (default $GNUPLOT, or gnuplot from PATH)")
+	flagHTTP := mainFlagSet.String("http", "localhost:8080", "for serve, listen on `address`")
+	flagViews := mainFlagSet.String("views", "", "for serve, save named views of the plot in `file`, which is created if needed")
 
 	// Merge flag sets.
 	mergeFlags := func(dst, src *flag.FlagSet) {
@@ -134,18 +287,124 @@ In addition, any projection may be one of the following:
 
 	// Parse flags. Finally!
 	flags.Parse(args)
-	if flags.NArg() == 0 {
-		flags.Usage()
-		os.Exit(2)
+	// set tracks which flags were set explicitly, so the default flags,
+	// config file, and preset only fill in the rest.
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	cmdLine := maps.Clone(set)
+	if err := applyDefaults(flags, set); err != nil {
+		return err
+	}
+	if *flagVersion {
+		return printVersion(w, *flagGnuplot)
+	}
+	paths := flags.Args()
+	var cfgFile configFile
+	if *flagConfig != "" {
+		var err error
+		cfgFile, err = loadConfigFile(*flagConfig, flags, set)
+		if err != nil {
+			return err
+		}
+		if cmd != "import" && cmd != "query" && len(paths) == 0 {
+			paths = cfgFile.inputs
+		}
+	}
+	if *flagPreset != "" {
+		// Apply the preset after the config file so it can set -preset.
+		preset, ok := presetOpts[*flagPreset]
+		if !ok {
+			return fmt.Errorf("unknown preset %s%s", *flagPreset, didYouMean(*flagPreset, sortedKeys(presetOpts)))
+		}
+		for i := 0; i < len(preset.flags); i += 2 {
+			if !set[preset.flags[i]] {
+				resetList(flags.Lookup(preset.flags[i]))
+				flags.Set(preset.flags[i], preset.flags[i+1])
+				set[preset.flags[i]] = true
+			}
+		}
+	}
+	if cmd == "compare" {
+		if len(paths) != 2 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		// Like a preset, this only fills in the flags that weren't
+		// set, and is then an ordinary plot.
+		opts := append(slices.Clip(presetOpts["compare"].flags), "geom", plot.GeomDotInterval.Name(), "o", compareOutput(paths[0], paths[1]))
+		for i := 0; i < len(opts); i += 2 {
+			if !set[opts[i]] {
+				resetList(flags.Lookup(opts[i]))
+				flags.Set(opts[i], opts[i+1])
+				set[opts[i]] = true
+			}
+		}
+		cmd = "plot"
+	}
+	inputs := paths
+	var dbPath string
+	switch cmd {
+	case "plot", "inspect", "export", "serve":
+		if len(paths) == 0 && !(cmd == "plot" && *flagFollow) {
+			// -follow may read from stdin.
+			flags.Usage()
+			os.Exit(2)
+		}
+	case "import":
+		if len(paths) < 2 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		dbPath, paths = paths[0], paths[1:]
+	case "query":
+		if len(paths) != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		dbPath, paths = paths[0], nil
+	}
+	if cmd != "plot" && cmd != "query" && (*flagInteractive || *flagFollow) {
+		return fmt.Errorf("-i and -follow are only for plotting")
+	}
+	if cmd != "plot" && cmd != "query" && cmd != "serve" && *flagWatch {
+		return fmt.Errorf("-watch is only for plotting and serve")
+	}
+	if *flagKeepTemp != "" {
+		if err := os.MkdirAll(*flagKeepTemp, 0777); err != nil {
+			return err
+		}
 	}
-
-	config := plot.NewConfig()
 
 	// Parse filter options.
 	filter, err := benchproc.NewFilter(*flagFilter)
 	if err != nil {
 		return fmt.Errorf("parsing -filter: %s", err)
 	}
+	var extracts []extract
+	for _, opt := range flagExtracts {
+		e, err := parseExtract(opt)
+		if err != nil {
+			return err
+		}
+		extracts = append(extracts, e)
+	}
+	var valueMaps []valueMap
+	for _, opt := range flagMaps {
+		vm, err := parseValueMap(opt)
+		if err != nil {
+			return err
+		}
+		valueMaps = append(valueMaps, vm)
+	}
+	var commits *gitHistory
+	if *flagGitCommits != "" {
+		commits, err = loadGitHistory(*flagGitDir, *flagGitCommits)
+		if err != nil {
+			return fmt.Errorf("-git-commits: %w", err)
+		}
+	}
 	var keepUnits map[string]bool
 	if *flagUnits != "" {
 		keepUnits = make(map[string]bool)
@@ -154,172 +413,916 @@ In addition, any projection may be one of the following:
 		}
 	}
 
-	// Parse projection options.
-	var parser benchproc.ProjectionParser
-	var parseResidue []*aesFlagReg
-	for i := range aesFlagRegs {
-		f := &aesFlagRegs[i]
-		switch *f.flagString {
-		case ".unit":
-			// TODO: Ideally you should be able to combine .unit with other
-			// projection bits. I remember specifically not wanting this for
-			// benchstat, so maybe this has to be an option to the parser.
-			proj, _, _ := parser.ParseWithUnit("", filter)
-			f.proj = proj
-		case ".value":
-			f.dv = true
-		case ".residue":
-			parseResidue = append(parseResidue, f)
-		default:
-			proj, err := parser.Parse(*f.flagString, filter)
-			if err != nil {
-				return fmt.Errorf("parsing -%s: %s", f.aes.Name(), err)
+	var include []string
+	if *flagInclude != "" {
+		include = strings.Split(*flagInclude, ",")
+	}
+
+	header := make(http.Header)
+	for _, h := range flagHeaders {
+		name, val, ok := strings.Cut(h, ":")
+		if !ok {
+			return fmt.Errorf("bad -header %q: expected name: value", h)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(val))
+	}
+
+	if !slices.Contains(input.Formats, *flagFormat) {
+		return fmt.Errorf("unknown -format %s%s", *flagFormat, didYouMean(*flagFormat, input.Formats))
+	}
+	csvConfig := input.CSVConfig{Name: *flagCSVName}
+	if *flagCSVKeys != "" {
+		csvConfig.Keys = strings.Split(*flagCSVKeys, ",")
+	}
+	if *flagCSVValues != "" {
+		csvConfig.Values = make(map[string]string)
+		for _, opt := range strings.Split(*flagCSVValues, ",") {
+			col, unit, _ := strings.Cut(opt, "=")
+			csvConfig.Values[col] = unit
+		}
+	}
+
+	fileKeys := make(map[string][]string)
+	for _, opt := range flagFileKeys {
+		i := strings.LastIndex(opt, ":")
+		if i < 0 {
+			return fmt.Errorf("bad -file-key %q: expected input:key=value,...", opt)
+		}
+		path := opt[:i]
+		for _, kv := range strings.Split(opt[i+1:], ",") {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok || k == "" {
+				return fmt.Errorf("bad -file-key %q: expected input:key=value,...", opt)
 			}
-			f.proj = proj
+			fileKeys[path] = append(fileKeys[path], k, v)
+		}
+	}
+
+	if *flagFailRegression > 0 && (*flagStream || *flagStreamMean) {
+		// Streaming leaves nothing to test significance with.
+		return fmt.Errorf("-fail-regression cannot be used with -stream or -stream-mean")
+	}
+	if *flagFailRegression > 0 {
+		// Check this before reading the inputs, so a misconfigured
+		// check fails fast.
+		transforms := []string{*flagTransform}
+		if len(cfgFile.plots) > 0 {
+			transforms = nil
+			for _, vals := range cfgFile.plots {
+				if t, ok := vals["transform"]; ok && !cmdLine["transform"] {
+					transforms = append(transforms, t)
+				} else {
+					transforms = append(transforms, *flagTransform)
+				}
+			}
+		}
+		compared := slices.ContainsFunc(transforms, func(t string) bool {
+			return slices.ContainsFunc(strings.Split(t, ","), func(opt string) bool {
+				name, _, _ := strings.Cut(opt, ":")
+				return name == "compare"
+			})
+		})
+		if !compared {
+			return fmt.Errorf("-fail-regression requires the compare transform")
 		}
 	}
 
-	// Process projection residue.
-	_, err = parser.Parse(*flagIgnore, filter)
+	better, err := parseBetter(*flagBetter)
+	if err != nil {
+		return err
+	}
+	where, err := parseWhere(*flagWhere)
 	if err != nil {
-		return fmt.Errorf("parsing -ignore: %s", err)
+		return err
+	}
+
+	// parsePlot builds a plot specification from the current values of
+	// the plot flags.
+	parsePlot := func() (*plotSpec, error) {
+		config := plot.NewConfig()
+
+		// Parse projection options.
+		var parser benchproc.ProjectionParser
+		var parseResidue []*aesFlagReg
+		for i := range aesFlagRegs {
+			f := &aesFlagRegs[i]
+			f.dv, f.proj = false, nil
+			switch *f.flagString {
+			case ".unit":
+				// TODO: Ideally you should be able to combine .unit with other
+				// projection bits. I remember specifically not wanting this for
+				// benchstat, so maybe this has to be an option to the parser.
+				proj, _, _ := parser.ParseWithUnit("", filter)
+				f.proj = proj
+			case ".value":
+				f.dv = true
+			case ".residue":
+				parseResidue = append(parseResidue, f)
+			default:
+				proj, err := parser.Parse(*f.flagString, filter)
+				if err != nil {
+					return nil, fmt.Errorf("parsing -%s: %s", f.aes.Name(), err)
+				}
+				f.proj = proj
+			}
+		}
+
+		// Process projection residue.
+		_, err := parser.Parse(*flagIgnore, filter)
+		if err != nil {
+			return nil, fmt.Errorf("parsing -ignore: %s", err)
+		}
+		var xLabels *benchproc.Projection
+		if *flagOrdinalX != "" {
+			xLabels, err = parser.Parse(*flagOrdinalX, filter)
+			if err != nil {
+				return nil, fmt.Errorf("parsing -ordinal-x: %s", err)
+			}
+		}
+		// .filetime only restates which input each result came from, and
+		// the keys -git-commits adds only restate the commit, so they're
+		// left out of the residue unless a projection names them. If one
+		// of the commit's keys is projected, the rest are too.
+		named := fieldNames(xLabels)
+		for _, f := range aesFlagRegs {
+			maps.Copy(named, fieldNames(f.proj))
+		}
+		var derived []string
+		if !named[".filetime"] {
+			derived = append(derived, ".filetime")
+		}
+		if key := *flagGitCommits; key != "" {
+			commitKeys := []string{key, key + "-order", key + "-date", key + "-ref"}
+			anyNamed := slices.ContainsFunc(commitKeys, func(k string) bool { return named[k] })
+			for i, k := range commitKeys {
+				if !named[k] && (anyNamed || i > 0) {
+					derived = append(derived, k)
+				}
+			}
+		}
+		if len(derived) > 0 {
+			if _, err := parser.Parse(strings.Join(derived, ","), filter); err != nil {
+				return nil, err
+			}
+		}
+		residue := parser.Residue()
+		if len(parseResidue) > 0 {
+			// If any of the projections are the residue, set them and
+			// clear the explicit residue.
+			//
+			// TODO: Otherwise, report residue mismatches.
+			for _, f := range parseResidue {
+				f.proj = residue
+			}
+			residue = nil
+		}
+
+		// Bind projections to aesthetics.
+		keys := make(map[string][]string)
+		for _, f := range aesFlagRegs {
+			if f.dv {
+				config.SetDV(f.aes)
+				continue
+			}
+			config.SetIV(f.aes, f.proj)
+			if *f.flagString == ".residue" || f.proj == nil {
+				continue
+			}
+			for _, field := range f.proj.Fields() {
+				switch field.Name {
+				case ".name", ".fullname", ".unit", ".config":
+					// Always present.
+				default:
+					keys[f.aes.Name()] = append(keys[f.aes.Name()], field.Name)
+				}
+			}
+		}
+		if xLabels != nil {
+			config.SetOrdinalX(xLabels)
+			for _, field := range xLabels.Fields() {
+				keys["ordinal-x"] = append(keys["ordinal-x"], field.Name)
+			}
+		}
+		// The serve UI shows the residue of each point.
+		if (*flagVerbose || *flagWarnings == "json" || cmd == "serve") && residue != nil {
+			config.SetResidue(residue)
+		}
+
+		// Parse log-scale option.
+		if *flagLogScale != "" {
+			for _, opt := range strings.Split(*flagLogScale, ",") {
+				opt, baseStr, hasBase := strings.Cut(opt, ":")
+				aes, ok := plot.AesFromName(opt)
+				if !ok {
+					var names []string
+					for _, f := range aesFlags {
+						names = append(names, f.aes.Name())
+					}
+					return nil, fmt.Errorf("unknown option %s in -log-scale=%s%s", opt, *flagLogScale, didYouMean(opt, names))
+				}
+				base := 10
+				if hasBase {
+					base2, err := strconv.ParseInt(baseStr, 10, 0)
+					if err != nil {
+						return nil, fmt.Errorf("bad base %s in -log-scale=%s: %w", baseStr, *flagLogScale, err)
+					}
+					base = int(base2)
+				}
+				config.SetLogScale(aes, base)
+			}
+
+		}
+
+		geom, ok := plot.GeomFromName(*flagGeom)
+		if !ok {
+			var names []string
+			for g := plot.GeomLine; g <= plot.GeomDotInterval; g++ {
+				names = append(names, g.Name())
+			}
+			return nil, fmt.Errorf("unknown geometry %s in -geom%s", *flagGeom, didYouMean(*flagGeom, names))
+		}
+		config.SetGeom(geom)
+
+		// Parse direction option.
+		direction, ok := plot.DirectionFromName(*flagDirection)
+		if !ok {
+			var names []string
+			for d := plot.DirectionBoth; d <= plot.DirectionImprovements; d++ {
+				names = append(names, d.Name())
+			}
+			return nil, fmt.Errorf("unknown direction %s in -direction%s", *flagDirection, didYouMean(*flagDirection, names))
+		}
+		config.SetDirection(direction)
+
+		if *flagHighlight != "" {
+			highlight, err := benchproc.NewFilter(*flagHighlight)
+			if err != nil {
+				return nil, fmt.Errorf("parsing -highlight: %s", err)
+			}
+			config.SetHighlight(highlight)
+		}
+
+		config.SetBreakY(*flagBreakY)
+		config.SetDropEmpty(*flagDropEmpty)
+		config.SetDirectLabels(*flagDirectLabels)
+		if !(*flagAnomalies >= 0) || math.IsInf(*flagAnomalies, 0) {
+			return nil, fmt.Errorf("bad -anomalies %g: expected a non-negative number of standard deviations", *flagAnomalies)
+		}
+		config.SetAnomalies(*flagAnomalies)
+		if *flagDiff != "" {
+			base, target, ok := strings.Cut(*flagDiff, ",")
+			if !ok || base == "" || target == "" || strings.Contains(target, ",") {
+				return nil, fmt.Errorf("bad -diff %q: expected base,target", *flagDiff)
+			}
+			config.SetDiff(base, target)
+		}
+		config.SetWatermark(*flagWatermark)
+		for _, opt := range flagVLines {
+			line, err := parseVLine(opt)
+			if err != nil {
+				return nil, err
+			}
+			config.AddVLine(line)
+		}
+		for _, opt := range flagHLines {
+			line, err := parseHLine(opt)
+			if err != nil {
+				return nil, err
+			}
+			config.AddHLine(line)
+		}
+		for _, opt := range flagHBands {
+			band, err := parseHBand(opt)
+			if err != nil {
+				return nil, err
+			}
+			config.AddHBand(band)
+		}
+		for _, opt := range flagRegions {
+			region, err := parseRegion(opt)
+			if err != nil {
+				return nil, err
+			}
+			config.AddRegion(region)
+		}
+		for _, opt := range flagAnnotations {
+			note, err := parseAnnotation(opt)
+			if err != nil {
+				return nil, err
+			}
+			config.AddAnnotation(note)
+		}
+		config.SetNoisiest(*flagNoisiest)
+		config.SetStreaming(*flagStream || *flagStreamMean)
+		config.SetStreamingMean(*flagStreamMean)
+		config.SetGnuplot(*flagGnuplot)
+
+		// Parse transforms.
+		var transforms []plot.Transform
+		if *flagTransform != "" {
+			for _, opt := range strings.Split(*flagTransform, ",") {
+				name, _, _ := strings.Cut(opt, ":")
+				if names := plot.TransformNames(); !slices.Contains(names, name) {
+					return nil, fmt.Errorf("unknown transform %s%s", name, didYouMean(name, names))
+				}
+				if name == "compare" && *flagDiff != "" {
+					// compare relabels the series by their
+					// baseline, so -diff can't find its colors.
+					return nil, fmt.Errorf("-diff cannot be used with the compare transform")
+				}
+				t, err := plot.ParseTransform(opt)
+				if err != nil {
+					return nil, fmt.Errorf("bad -transform %q: %w", opt, err)
+				}
+				transforms = append(transforms, t)
+			}
+		}
+
+		spec := &plotSpec{config: config, transforms: transforms, output: *flagOutput, noiseReport: *flagNoiseReport, anomalies: *flagAnomalies > 0, keys: keys}
+		if *flagTable {
+			format, ok := plot.TableFormatFromName(*flagTableFormat)
+			if !ok {
+				var names []string
+				for f := plot.TableText; f <= plot.TableMarkdown; f++ {
+					names = append(names, f.Name())
+				}
+				return nil, fmt.Errorf("unknown format %s in -table-format%s", *flagTableFormat, didYouMean(*flagTableFormat, names))
+			}
+			if *flagStream || *flagStreamMean {
+				// Streaming leaves nothing to compare.
+				return nil, fmt.Errorf("-table cannot be used with -stream or -stream-mean")
+			}
+			spec.table, spec.tableFormat = true, format
+		}
+		if *flagKeepTemp != "" {
+			// Snapshot the flags now, since the config file may
+			// set them differently for each plot.
+			spec.keepTemp = keepTempPrefix(*flagKeepTemp, *flagOutput)
+			config.SetKeepScript(spec.keepTemp + ".gp")
+			if spec.resolved, err = resolvedConfig(flags, inputs); err != nil {
+				return nil, err
+			}
+		}
+		return spec, nil
 	}
-	residue := parser.Residue()
-	if len(parseResidue) > 0 {
-		// If any of the projections are the residue, set them and
-		// clear the explicit residue.
-		//
-		// TODO: Otherwise, report residue mismatches.
-		for _, f := range parseResidue {
-			f.proj = residue
+	var specs []*plotSpec
+	if len(cfgFile.plots) == 0 {
+		spec, err := parsePlot()
+		if err != nil {
+			return err
 		}
-		residue = nil
+		specs = append(specs, spec)
+	}
+	for i, plotFlags := range cfgFile.plots {
+		// The command line takes precedence over each plot, too.
+		plotFlags = maps.Clone(plotFlags)
+		maps.DeleteFunc(plotFlags, func(name, _ string) bool { return cmdLine[name] })
+		var spec *plotSpec
+		err := withFlags(flags, plotFlags, func() error {
+			var err error
+			spec, err = parsePlot()
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s: plot %d: %w", *flagConfig, i+1, err)
+		}
+		specs = append(specs, spec)
 	}
 
-	// Bind projections to aesthetics.
-	for _, f := range aesFlagRegs {
-		if f.dv {
-			config.SetDV(f.aes)
+	// Open the inputs or database.
+	var db *store.DB
+	if dbPath != "" {
+		db, err = store.Open(dbPath)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+	// watchInputs returns the inputs to watch for -watch, which are
+	// expanded like the inputs that are read.
+	watchInputs := func() *input.Files {
+		return &input.Files{Paths: paths, AllowStdin: true, AllowLabels: true, Include: include, Format: *flagFormat}
+	}
+	openInputs := func() recordSource {
+		var src recordSource
+		if cmd == "query" {
+			src = db.Query(where)
 		} else {
-			config.SetIV(f.aes, f.proj)
+			src = &input.Files{
+				Paths:          paths,
+				AllowStdin:     !*flagInteractive, // -i reads commands from stdin
+				AllowLabels:    true,
+				Include:        include,
+				Header:         header,
+				PerfDataServer: *flagPerfData,
+				Format:         *flagFormat,
+				CSV:            csvConfig,
+				FileKeys:       fileKeys,
+				CacheDir:       *flagCache,
+				Follow:         *flagFollow,
+				Influx: input.InfluxConfig{
+					Server:      *flagInflux,
+					Token:       os.Getenv("INFLUX_TOKEN"),
+					Org:         *flagInfluxOrg,
+					Bucket:      *flagInfluxBucket,
+					Measurement: *flagInfluxMeasurement,
+					Start:       *flagInfluxStart,
+				},
+			}
 		}
+		if *flagProgress {
+			src = newProgressSource(src, wErr)
+		}
+		return src
 	}
 
-	// Parse log-scale option.
-	if *flagLogScale != "" {
-		for _, opt := range strings.Split(*flagLogScale, ",") {
-			opt, baseStr, hasBase := strings.Cut(opt, ":")
-			aes, ok := plot.AesFromName(opt)
-			if !ok {
-				return fmt.Errorf("unknown option %s in -log-scale=%s", opt, *flagLogScale)
+	// Informational messages and warnings go to wInfo, which -q silences.
+	wInfo := wErr
+	if *flagQuiet {
+		wInfo = io.Discard
+	}
+
+	warnings := &warner{w: wInfo}
+	switch *flagWarnings {
+	case "text":
+	case "json":
+		warnings.json = true
+	default:
+		return fmt.Errorf("unknown -warnings %s%s", *flagWarnings, didYouMean(*flagWarnings, []string{"text", "json"}))
+	}
+	if *flagWarningsFile != "" {
+		f, err := openWarnings(*flagWarningsFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		warnings.w = f
+	}
+	// plotWarnings reports the warnings about the points in pl. These
+	// are only reported as JSON because they are common and usually
+	// harmless, so they would be noise as text.
+	plotWarnings := func(pl *plot.Plot) {
+		if !warnings.json {
+			return
+		}
+		for _, w := range pl.Warnings() {
+			warnings.warn(warning{Kind: w.Kind, Msg: w.Msg, Point: w.Point})
+		}
+	}
+
+	in := &ingester{
+		filter:    filter,
+		keepUnits: keepUnits,
+		unitsFlag: *flagUnits,
+		extracts:  extracts,
+		valueMaps: valueMaps,
+		commits:   commits,
+		better:    better,
+		strict:    *flagStrict,
+		summarize: *flagErrorSummary,
+		errorLog:  *flagErrorLog,
+		warnings:  warnings,
+	}
+	if *flagDedup {
+		in.dedup()
+	}
+	if *flagSample != 1 {
+		if *flagSample <= 0 || *flagSample > 1 {
+			return fmt.Errorf("-sample must be in (0, 1]")
+		}
+		in.sample(*flagSample, *flagSeed)
+	}
+
+	// finish applies spec's transforms to pl and writes any reports.
+	finish := func(spec *plotSpec, pl *plot.Plot) error {
+		if spec.table {
+			// The table compares the measurements, so it must be
+			// written before transforms replace them. Keep it out
+			// of the script written by -print.
+			tw := w
+			if *flagPrint {
+				tw = wErr
 			}
-			base := 10
-			if hasBase {
-				base2, err := strconv.ParseInt(baseStr, 10, 0)
-				if err != nil {
-					return fmt.Errorf("bad base %s in -log-scale=%s: %w", baseStr, *flagLogScale, err)
+			if err := pl.WriteTable(tw, spec.tableFormat); err != nil {
+				return fmt.Errorf("-table: %w", err)
+			}
+		}
+		if err := pl.Apply(spec.transforms...); err != nil {
+			return err
+		}
+		if *flagCaption {
+			pl.SetCaption(provenance(*flagFilter, inputs, pl.Shape().Results, time.Now()))
+		}
+
+		if spec.noiseReport != "" {
+			if err := writeNoiseReport(spec.noiseReport, pl.Noise()); err != nil {
+				return err
+			}
+		}
+		if spec.anomalies {
+			// If there's no layout, rendering reports why.
+			if anomalies, err := pl.Anomalies(); err == nil {
+				reportAnomalies(wInfo, anomalies)
+			}
+		}
+		return nil
+	}
+
+	// output writes pl to spec's output file. If gnuplot is unavailable,
+	// it writes the gnuplot script next to the output file instead, so the
+	// work of reading the inputs isn't lost.
+	output := func(spec *plotSpec, pl *plot.Plot) error {
+		if spec.keepTemp != "" {
+			if err := os.WriteFile(spec.keepTemp+".yaml", spec.resolved, 0666); err != nil {
+				return err
+			}
+		}
+		if *flagDryRun {
+			s := pl.Shape()
+			fmt.Fprintf(w, "%s: %s (%s by %s), %d series, %s from %s\n", spec.output,
+				plural(s.Facets, "facet"), plural(s.Rows, "row"), plural(s.Cols, "column"),
+				s.Series, plural(s.Points, "point"), plural(s.Measurements, "measurement"))
+			return nil
+		}
+		if s := pl.Shape(); s.Rows*s.Cols > maxFacets && !*flagForce {
+			// Such a grid is rarely intended, and gnuplot takes
+			// a long time to draw it.
+			return fmt.Errorf("%s: %s by %s of facets is more than %d; check -row and -col, or use -force to plot it anyway", spec.output,
+				plural(s.Rows, "row"), plural(s.Cols, "column"), maxFacets)
+		}
+		if *flagPrint {
+			// Write the gnuplot script instead of rendering it.
+			return pl.Render(plot.RenderOptions{Format: plot.FormatScript}, w)
+		}
+		f, err := os.Create(spec.output)
+		if err != nil {
+			return err
+		}
+		err = pl.Render(plot.RenderOptions{Format: plot.FormatPNG}, f)
+		f.Close()
+		var unavail *plot.UnavailableError
+		if !errors.As(err, &unavail) {
+			return err
+		}
+		os.Remove(spec.output)
+		script := strings.TrimSuffix(spec.output, filepath.Ext(spec.output)) + ".gp"
+		f, err = os.Create(script)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := pl.Render(plot.RenderOptions{Format: plot.FormatScript}, f); err != nil {
+			return err
+		}
+		fmt.Fprintf(wErr, "%s\nwrote gnuplot script to %s instead; render it with gnuplot -p %s\n", unavail, script, script)
+		return nil
+	}
+
+	// render reads the inputs and produces the plot. In watch mode, this is
+	// called each time the inputs change.
+	render := func() error {
+		// Read inputs. We read them once for all plots.
+		in.reset()
+		pls := make([]*plot.Plot, len(specs))
+		for i, spec := range specs {
+			var err error
+			pls[i], err = plot.NewPlot(spec.config)
+			if err != nil {
+				return err
+			}
+		}
+		files := in.pipeline(openInputs())
+		defer files.Close()
+		var batch []*benchfmt.Result
+		for files.Scan() {
+			if rec := in.record(files.Result()); rec != nil {
+				batch = append(batch, rec)
+				if len(batch) == pipelineBatch {
+					addAll(pls, batch)
+					batch = batch[:0]
 				}
-				base = int(base2)
 			}
-			config.SetLogScale(aes, base)
+		}
+		addAll(pls, batch)
+		if err := files.Err(); err != nil {
+			return err
+		}
+		units := in.units(files.Units())
+		if err := in.check(); err != nil {
+			return err
+		}
+		for _, spec := range specs {
+			in.checkKeys(spec.keys)
 		}
 
+		var changes []plot.Change
+		for i, pl := range pls {
+			pl.SetUnits(units)
+			if err := finish(specs[i], pl); err != nil {
+				return err
+			}
+			if *flagVerbose {
+				if len(specs) > 1 {
+					fmt.Fprintf(wErr, "plot %s:\n", specs[i].output)
+				}
+				pl.WriteDiagnostics(wErr)
+			}
+			plotWarnings(pl)
+			if err := output(specs[i], pl); err != nil {
+				return err
+			}
+			changes = append(changes, pl.Changes()...)
+		}
+		if *flagFailRegression > 0 {
+			return checkRegressions(wErr, changes, *flagFailRegression)
+		}
+		return nil
 	}
 
-	// Parse transforms.
-	var transforms []func(p *plot.Plot) error
-	if *flagTransform != "" {
-		for _, opt := range strings.Split(*flagTransform, ",") {
-			t, ok := transformOpts[opt]
-			if !ok {
-				return fmt.Errorf("unknown transform %s", opt)
+	// readAll reads all of the inputs into memory, for plotting them
+	// repeatedly without reading them again.
+	readAll := func() ([]*benchfmt.Result, benchfmt.UnitMetadataMap, error) {
+		in.reset()
+		var recs []*benchfmt.Result
+		files := in.pipeline(openInputs())
+		defer files.Close()
+		for files.Scan() {
+			if rec := in.record(files.Result()); rec != nil {
+				recs = append(recs, rec)
 			}
-			transforms = append(transforms, t.do)
 		}
+		if err := files.Err(); err != nil {
+			return nil, nil, err
+		}
+		units := in.units(files.Units())
+		if err := in.check(); err != nil {
+			return nil, nil, err
+		}
+		fmt.Fprintf(wInfo, "read %d results\n", len(recs))
+		return recs, units, nil
 	}
 
-	// Read inputs.
-	var errors []errorAt
-	var nParsed, nFiltered, nUnitFiltered int
-	pl, err := plot.NewPlot(config)
-	if err != nil {
-		return err
+	// plotRecords plots recs using the current values of the plot flags.
+	plotRecords := func(recs []*benchfmt.Result, units benchfmt.UnitMetadataMap) (*plotSpec, *plot.Plot, error) {
+		spec, err := parsePlot()
+		if err != nil {
+			return nil, nil, err
+		}
+		in.checkKeys(spec.keys)
+		pl, err := plot.NewPlot(spec.config)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, rec := range recs {
+			pl.Add(rec)
+		}
+		pl.SetUnits(units)
+		if err := finish(spec, pl); err != nil {
+			return nil, nil, err
+		}
+		if *flagVerbose {
+			pl.WriteDiagnostics(wErr)
+		}
+		plotWarnings(pl)
+		return spec, pl, nil
 	}
-	files := benchfmt.Files{Paths: flags.Args(), AllowStdin: true, AllowLabels: true}
-	for files.Scan() {
-		switch rec := files.Result(); rec := rec.(type) {
-		case *benchfmt.SyntaxError:
-			// Non-fatal result parse error. Warn
-			// but keep going.
-			fmt.Fprintln(wErr, rec)
-		case *benchfmt.Result:
-			nParsed++
-			if ok, err := filter.Apply(rec); !ok {
-				nFiltered++
+
+	switch cmd {
+	case "import":
+		src := openInputs()
+		defer src.Close()
+		return importInputs(db, src, in, wInfo)
+	case "inspect":
+		src := openInputs()
+		defer src.Close()
+		return inspect(w, src, in)
+	case "export":
+		src := openInputs()
+		defer src.Close()
+		return export(w, src, in)
+	case "serve":
+		recs, units, err := readAll()
+		if err != nil {
+			return err
+		}
+		data := newServeData(recs, units)
+		var views *savedViews
+		if *flagViews != "" {
+			views, err = loadViews(*flagViews)
+			if err != nil {
+				return err
+			}
+		}
+		s := &server{
+			data:     data,
+			defaults: view{"filter": "*"},
+			views:    views,
+			newPlot: func(v view) (*plot.Plot, error) {
+				recs, units := data.results()
+				recs, err := filterRecords(recs, v["filter"])
 				if err != nil {
-					// Print the reason we rejected this result.
-					fmt.Fprintln(wErr, err)
+					return nil, err
 				}
-				continue
-			}
-			if keepUnits != nil {
-				j := 0
-				for _, val := range rec.Values {
-					if keepUnits[val.Unit] || (val.OrigUnit != "" && keepUnits[val.OrigUnit]) {
-						rec.Values[j] = val
-						j++
+				vals := maps.Clone(v)
+				delete(vals, "filter")
+				var pl *plot.Plot
+				err = withFlags(flags, vals, func() error {
+					var err error
+					_, pl, err = plotRecords(recs, units)
+					return err
+				})
+				return pl, err
+			},
+			read: func(r io.Reader) ([]*benchfmt.Result, benchfmt.UnitMetadataMap, error) {
+				var recs []*benchfmt.Result
+				reader := benchfmt.NewReader(r, "POST")
+				for reader.Scan() {
+					if rec := in.record(reader.Result()); rec != nil {
+						recs = append(recs, rec.Clone())
 					}
 				}
-				rec.Values = rec.Values[:j]
-				if j == 0 {
-					nUnitFiltered++
-					continue
+				if err := reader.Err(); err != nil {
+					return nil, nil, err
 				}
+				return recs, in.units(reader.Units()), nil
+			},
+		}
+		for _, name := range viewFlags {
+			if name != "filter" {
+				s.defaults[name] = flags.Lookup(name).Value.String()
 			}
-
-			pl.Add(rec)
 		}
+		if *flagWatch {
+			go func() {
+				err := watch(watchInputs(), func() error {
+					data.mu.Lock()
+					defer data.mu.Unlock()
+					recs, units, err := readAll()
+					if err != nil {
+						return err
+					}
+					data.setInputs(recs, units)
+					return nil
+				}, wErr, wInfo)
+				if err != nil {
+					fmt.Fprintf(wErr, "%s\n", err)
+				}
+			}()
+		}
+		return s.serve(*flagHTTP, wInfo)
 	}
-	if err := files.Err(); err != nil {
-		return err
+	if *flagInteractive {
+		if *flagFollow || *flagWatch {
+			return fmt.Errorf("-i cannot be used with -follow or -watch")
+		}
+		recs, units, err := readAll()
+		if err != nil {
+			return err
+		}
+		return repl(os.Stdin, w, flags, func() error {
+			spec, pl, err := plotRecords(recs, units)
+			if err != nil {
+				return err
+			}
+			if err := output(spec, pl); err != nil {
+				return err
+			}
+			if !*flagPrint && !*flagDryRun {
+				fmt.Fprintf(wInfo, "wrote %s\n", spec.output)
+			}
+			return nil
+		})
 	}
-	pl.SetUnits(files.Units())
-	if nParsed == 0 {
-		return fmt.Errorf("no data")
-	} else if nUnitFiltered == nParsed {
-		return fmt.Errorf("no data has units %s", *flagUnits)
-	} else if nUnitFiltered+nFiltered == nParsed {
-		return fmt.Errorf("all data filtered")
+	if *flagFollow {
+		if cmd == "query" {
+			return fmt.Errorf("-follow cannot be used with query")
+		}
+		if *flagWatch {
+			return fmt.Errorf("-follow and -watch are mutually exclusive")
+		}
+		if *flagPrint {
+			return fmt.Errorf("-print cannot be used with -follow")
+		}
+		if len(specs) > 1 {
+			return fmt.Errorf("-follow supports only one plot")
+		}
+		if len(paths) > 1 {
+			return fmt.Errorf("-follow requires at most one input")
+		}
+		if !slices.Contains(followFormats, *flagFormat) {
+			return fmt.Errorf("-follow cannot be used with -format %s, whose inputs are read all at once", *flagFormat)
+		}
+		return follow(openInputs(), specs[0].config, *flagGnuplot, in, func(pl *plot.Plot) error {
+			return finish(specs[0], pl)
+		}, wErr)
 	}
-	if len(errors) > 0 {
-		// No need to sort right now because they're already in order.
-		return errorsAt(errors)
+	if !*flagWatch {
+		return render()
 	}
-	if nFiltered > 0 || nUnitFiltered > 0 {
-		fmt.Fprintf(wErr, "%d records did not match -filter, %d records did not match -unit\n", nFiltered, nUnitFiltered)
+	if cmd == "query" {
+		return fmt.Errorf("-watch cannot be used with query")
 	}
+	return watch(watchInputs(), render, wErr, wInfo)
+}
 
-	// Apply transforms.
-	for _, transform := range transforms {
-		if err := transform(pl); err != nil {
-			return err
-		}
+// compareOutput returns the name of the plot of the compare subcommand, such
+// as "old-vs-new.png" for inputs old.txt and new.txt.
+func compareOutput(old, new string) string {
+	name := func(path string) string {
+		base := filepath.Base(path)
+		return strings.TrimSuffix(base, filepath.Ext(base))
 	}
+	return name(old) + "-vs-" + name(new) + ".png"
+}
 
-	//code, err := plot.GnuplotCode()
-	f, err := os.Create("benchplot.png")
-	if err != nil {
-		return err
+// plural returns n followed by word, pluralized if n != 1.
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
 	}
-	defer f.Close()
-	err = pl.Gnuplot("png", f)
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+// maxCaptionInputs is the number of inputs named in a caption. The rest are
+// only counted.
+const maxCaptionInputs = 4
+
+// provenance returns the caption of a plot of n results read from inputs with
+// filter, made at now.
+func provenance(filter string, inputs []string, n int, now time.Time) string {
+	names := inputs
+	if len(names) > maxCaptionInputs {
+		names = append(slices.Clip(names[:maxCaptionInputs]), fmt.Sprintf("and %d more", len(inputs)-maxCaptionInputs))
+	}
+	return fmt.Sprintf("filter %s; inputs %s; %s; generated %s", filter, strings.Join(names, ", "), plural(n, "result"), now.Format(time.DateOnly))
+}
+
+func writeNoiseReport(path string, noise []plot.Noise) error {
+	if noise == nil {
+		return fmt.Errorf("-noise-report requires the noisiest transform")
+	}
+	data, err := json.MarshalIndent(noise, "", "\t")
 	if err != nil {
 		return err
 	}
-	//fmt.Fprint(w, code)
+	return os.WriteFile(path, append(data, '\n'), 0666)
+}
+
+// A plotSpec is the specification of a single plot.
+type plotSpec struct {
+	config      *plot.Config
+	transforms  []plot.Transform
+	output      string
+	noiseReport string
+	// anomalies indicates that the plot marks anomalies, which are also
+	// reported.
+	anomalies bool
+	// table indicates that a comparison table of the plot is printed
+	// in tableFormat.
+	table       bool
+	tableFormat plot.TableFormat
+
+	// keys maps from aesthetic flag names to the keys projected by
+	// that flag, for checking against the keys in the inputs.
+	keys map[string][]string
+
+	// keepTemp is the path prefix for the files saved by -keep-temp, or
+	// "". resolved is the resolved configuration to save.
+	keepTemp string
+	resolved []byte
+}
+
+// fieldNames returns the set of names of the fields of proj, which may be nil.
+func fieldNames(proj *benchproc.Projection) map[string]bool {
+	names := make(map[string]bool)
+	if proj != nil {
+		for _, f := range proj.FlattenedFields() {
+			names[f.Name] = true
+		}
+	}
+	return names
+}
+
+// stringList is a flag.Value that accumulates each use of a flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
 	return nil
 }
 
+// resetList clears flag f if it accumulates, so a source of flags that
+// overrides an earlier one replaces its values rather than adding to them.
+func resetList(f *flag.Flag) {
+	if l, ok := f.Value.(*stringList); ok {
+		*l = nil
+	}
+}
+
 type errorAt struct {
 	file string
 	line int
@@ -0,0 +1,117 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aclements/benchplot/plot"
+)
+
+// parseMarkFields parses the fields of a reference mark flag, which are
+// key=value pairs separated by colons. The label field, if any, must be last,
+// and its value extends to the end of opt, so it may contain colons. keys
+// lists the fields that are allowed.
+func parseMarkFields(opt string, keys ...string) (map[string]string, bool) {
+	fields := make(map[string]string)
+	for opt != "" {
+		var field string
+		if strings.HasPrefix(opt, "label=") {
+			field, opt = opt, ""
+		} else {
+			field, opt, _ = strings.Cut(opt, ":")
+		}
+		k, v, ok := strings.Cut(field, "=")
+		if _, dup := fields[k]; !ok || dup || !slices.Contains(keys, k) {
+			return nil, false
+		}
+		fields[k] = v
+	}
+	return fields, true
+}
+
+// parseVLine parses a -vline flag, which has the form x=value[:label=text].
+func parseVLine(opt string) (plot.RefLine, error) {
+	fields, ok := parseMarkFields(opt, "x", "label")
+	if !ok || fields["x"] == "" {
+		return plot.RefLine{}, fmt.Errorf("bad -vline %q: expected x=value or x=value:label=text", opt)
+	}
+	x, err := strconv.ParseFloat(fields["x"], 64)
+	if err != nil {
+		return plot.RefLine{}, fmt.Errorf("bad -vline %q: %w", opt, err)
+	}
+	return plot.RefLine{At: x, Label: fields["label"]}, nil
+}
+
+// parseHLine parses a -hline flag, which has the form y=value[:label=text].
+func parseHLine(opt string) (plot.RefLine, error) {
+	fields, ok := parseMarkFields(opt, "y", "label")
+	if !ok || fields["y"] == "" {
+		return plot.RefLine{}, fmt.Errorf("bad -hline %q: expected y=value or y=value:label=text", opt)
+	}
+	y, err := strconv.ParseFloat(fields["y"], 64)
+	if err != nil {
+		return plot.RefLine{}, fmt.Errorf("bad -hline %q: %w", opt, err)
+	}
+	return plot.RefLine{At: y, Label: fields["label"]}, nil
+}
+
+// parseAnnotation parses an -annotate flag, which has the form
+// x=value:y=value[:arrow=bool][:row=label][:col=label]:label=text.
+func parseAnnotation(opt string) (plot.Annotation, error) {
+	bad := fmt.Errorf("bad -annotate %q: expected x=value:y=value:label=text, optionally with arrow=true, row=label, or col=label before label", opt)
+	fields, ok := parseMarkFields(opt, "x", "y", "arrow", "row", "col", "label")
+	if !ok || fields["label"] == "" {
+		return plot.Annotation{}, bad
+	}
+	note := plot.Annotation{Label: fields["label"], Row: fields["row"], Col: fields["col"]}
+	var err1, err2, err3 error
+	note.X, err1 = strconv.ParseFloat(fields["x"], 64)
+	note.Y, err2 = strconv.ParseFloat(fields["y"], 64)
+	if arrow, ok := fields["arrow"]; ok {
+		note.Arrow, err3 = strconv.ParseBool(arrow)
+	}
+	if err1 != nil || err2 != nil || err3 != nil {
+		return plot.Annotation{}, bad
+	}
+	return note, nil
+}
+
+// parseHBand parses a -hband flag, which has the form lo:hi[:label=text].
+func parseHBand(opt string) (plot.RefBand, error) {
+	bad := fmt.Errorf("bad -hband %q: expected lo:hi or lo:hi:label=text", opt)
+	loStr, rest, ok1 := strings.Cut(opt, ":")
+	hiStr, rest, _ := strings.Cut(rest, ":")
+	fields, ok2 := parseMarkFields(rest, "label")
+	if !ok1 || !ok2 {
+		return plot.RefBand{}, bad
+	}
+	lo, err1 := strconv.ParseFloat(loStr, 64)
+	hi, err2 := strconv.ParseFloat(hiStr, 64)
+	if err1 != nil || err2 != nil || !(lo <= hi) {
+		return plot.RefBand{}, bad
+	}
+	return plot.RefBand{Lo: lo, Hi: hi, Label: fields["label"]}, nil
+}
+
+// parseRegion parses a -region flag, which has the form x1:x2[:label]. The
+// label is the rest of the flag, so it may contain colons.
+func parseRegion(opt string) (plot.RefBand, error) {
+	bad := fmt.Errorf("bad -region %q: expected x1:x2 or x1:x2:label", opt)
+	loStr, rest, ok := strings.Cut(opt, ":")
+	hiStr, label, _ := strings.Cut(rest, ":")
+	if !ok {
+		return plot.RefBand{}, bad
+	}
+	lo, err1 := strconv.ParseFloat(loStr, 64)
+	hi, err2 := strconv.ParseFloat(hiStr, 64)
+	if err1 != nil || err2 != nil || !(lo <= hi) {
+		return plot.RefBand{}, bad
+	}
+	return plot.RefBand{Lo: lo, Hi: hi, Label: label}, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/aclements/benchplot/plot"
+)
+
+// prometheusMetrics is a [plot.Renderer] that writes the summary of each point
+// of a plot as metrics in the Prometheus text exposition format, so
+// monitoring systems can scrape them. Each point gives a benchplot_value
+// metric and, if it has a confidence interval, benchplot_lo and benchplot_hi
+// metrics. The values are scaled as they are drawn. The labels are the
+// projected fields of the point, the unit, and the Y axis label, which says
+// how the values are scaled, such as "delta sec/op" for a comparison.
+type prometheusMetrics struct{}
+
+var metricHelp = []struct{ name, help string }{
+	{"benchplot_value", "Center of the summarized measurements of each point, as plotted."},
+	{"benchplot_lo", "Lower bound of the confidence interval of each point, as plotted."},
+	{"benchplot_hi", "Upper bound of the confidence interval of each point, as plotted."},
+}
+
+func (prometheusMetrics) Render(l *plot.Layout, out io.Writer) error {
+	// The exposition format requires all samples of a metric to be
+	// together, so collect the samples of each one.
+	var samples [3][]string
+	for _, f := range l.Facets {
+		for _, s := range f.Series {
+			for _, m := range s.Marks {
+				labels := metricLabels(m.Point, f.Y.Label)
+				for i, v := range []float64{m.Y, m.Lo, m.Hi} {
+					if !math.IsNaN(v) {
+						samples[i] = append(samples[i], labels+" "+strconv.FormatFloat(v, 'g', -1, 64))
+					}
+				}
+			}
+		}
+	}
+
+	w := bufio.NewWriter(out)
+	for i, m := range metricHelp {
+		if len(samples[i]) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", m.name, m.help, m.name)
+		for _, s := range samples[i] {
+			fmt.Fprintf(w, "%s%s\n", m.name, s)
+		}
+	}
+	return w.Flush()
+}
+
+// metricLabels returns the label set for a point, such as
+// {axis="sec/op",goos="linux",unit="sec/op"}.
+func metricLabels(pt plot.Point, axis string) string {
+	labels := map[string]string{"unit": pt.Unit(), "axis": axis}
+	for name, val := range pt.Fields() {
+		name = metricLabelName(name)
+		if _, ok := labels[name]; !ok {
+			labels[name] = val
+		}
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range sortedKeys(labels) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", name, metricEscaper.Replace(labels[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var metricEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricLabelName turns a field name, such as ".file" or "/size", into a valid
+// label name, such as "file" or "size".
+func metricLabelName(field string) string {
+	name := []byte(strings.TrimLeft(field, "./"))
+	for i, c := range name {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || i > 0 && '0' <= c && c <= '9') {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || strings.HasPrefix(string(name), "__") {
+		// Names starting with __ are reserved.
+		return "key_" + string(name)
+	}
+	return string(name)
+}
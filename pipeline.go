@@ -0,0 +1,165 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/aclements/benchplot/plot"
+	"golang.org/x/perf/benchfmt"
+)
+
+// pipelineBatch is the number of records each goroutine of a pipeline works
+// on at a time. Handing off each record separately would cost more than
+// preparing it.
+const pipelineBatch = 256
+
+// A preparingSource is a recordSource that reads another source on its own
+// goroutine and prepares the results for an ingester on GOMAXPROCS more, so
+// reading, rewriting, and filtering the inputs all run in parallel with
+// plotting them. It returns the records in the order the source did, with
+// each Result as a *preparedResult, so ingesting them is deterministic.
+//
+// Unlike most sources, a preparingSource never reuses a Result.
+type preparingSource struct {
+	src recordSource
+
+	// batches delivers each batch in order. A worker sends the batch
+	// on its channel once it's prepared.
+	batches chan chan []benchfmt.Record
+
+	batch []benchfmt.Record
+	err   error
+
+	// Closing stop stops the reading goroutine, which closes stopped once
+	// it's done with src.
+	stop, stopped chan struct{}
+}
+
+// A pipelineWork is a batch waiting to be prepared by a worker.
+type pipelineWork struct {
+	recs []benchfmt.Record
+	done chan []benchfmt.Record
+}
+
+func (in *ingester) pipeline(src recordSource) *preparingSource {
+	s := &preparingSource{src: src, stop: make(chan struct{}), stopped: make(chan struct{})}
+	n := runtime.GOMAXPROCS(0)
+	// Allow a batch in progress on each worker and one waiting for
+	// each, which bounds memory use.
+	s.batches = make(chan chan []benchfmt.Record, 2*n)
+	work := make(chan pipelineWork, n)
+	for range n {
+		go func() {
+			for w := range work {
+				for i, rec := range w.recs {
+					if res, ok := rec.(*benchfmt.Result); ok {
+						p := in.prepare(res)
+						w.recs[i] = &p
+					}
+				}
+				w.done <- w.recs
+			}
+		}()
+	}
+	go func() {
+		defer close(s.stopped)
+		defer close(s.batches)
+		defer close(work)
+		var recs []benchfmt.Record
+		flush := func() bool {
+			w := pipelineWork{recs, make(chan []benchfmt.Record, 1)}
+			select {
+			case s.batches <- w.done:
+			case <-s.stop:
+				return false
+			}
+			work <- w
+			recs = nil
+			return true
+		}
+		for src.Scan() {
+			rec := src.Result()
+			if res, ok := rec.(*benchfmt.Result); ok {
+				// The source may reuse its Result.
+				rec = res.Clone()
+			}
+			recs = append(recs, rec)
+			if len(recs) == pipelineBatch && !flush() {
+				return
+			}
+		}
+		if len(recs) > 0 {
+			flush()
+		}
+		// The consumer reads this once batches is closed.
+		s.err = src.Err()
+	}()
+	return s
+}
+
+func (s *preparingSource) Scan() bool {
+	if len(s.batch) > 1 {
+		s.batch = s.batch[1:]
+		return true
+	}
+	done, ok := <-s.batches
+	if !ok {
+		s.batch = nil
+		return false
+	}
+	s.batch = <-done
+	return true
+}
+
+func (s *preparingSource) Result() benchfmt.Record {
+	return s.batch[0]
+}
+
+// Err returns the error that stopped the source, once Scan has returned false.
+func (s *preparingSource) Err() error {
+	return s.err
+}
+
+// Close stops reading the source and closes it. Callers that stop calling Scan
+// before it returns false must call Close.
+func (s *preparingSource) Close() {
+	select {
+	case <-s.stop:
+		return
+	default:
+	}
+	close(s.stop)
+	<-s.stopped
+	s.src.Close()
+}
+
+// Units returns the unit metadata of the source, once Scan has returned false.
+func (s *preparingSource) Units() benchfmt.UnitMetadataMap {
+	return s.src.Units()
+}
+
+// addAll adds recs to each of pls. Projecting results is most of the cost of
+// adding them, so it adds to each plot on its own goroutine.
+func addAll(pls []*plot.Plot, recs []*benchfmt.Result) {
+	if len(pls) == 1 {
+		for _, rec := range recs {
+			pls[0].Add(rec)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	for _, pl := range pls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, rec := range recs {
+				pl.Add(rec)
+			}
+		}()
+	}
+	wg.Wait()
+}
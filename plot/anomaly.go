@@ -0,0 +1,123 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"math"
+	"slices"
+)
+
+// An Anomaly describes a point that's far from the points before it in its
+// series, as found when [Config.SetAnomalies] is set.
+type Anomaly struct {
+	// Point gives the value of each aesthetic that identifies this point,
+	// indexed by aesthetic name.
+	Point map[string]string `json:"point"`
+	// Unit is the unit of the measurements.
+	Unit string `json:"unit,omitempty"`
+	// Value is the center of the point, and Expected is the median of the
+	// points before it.
+	Value    float64 `json:"value"`
+	Expected float64 `json:"expected"`
+	// Sigma is the difference between Value and Expected, in multiples
+	// of the series' noise. It's negative if Value is below Expected.
+	Sigma float64 `json:"sigma"`
+}
+
+// anomalyWindow is the number of points before a point whose median is its
+// expected value, and anomalyHistory is the fewest that must come before a
+// point for it to be checked.
+const (
+	anomalyWindow  = 10
+	anomalyHistory = 3
+)
+
+// An anomaly is a point of a facet's summary that's far from the points
+// before it.
+type anomaly struct {
+	expected, sigma float64
+}
+
+// findAnomalies returns the points of summary, which is sorted by series and
+// then X, that are more than sigma times their series' noise from the median
+// of the points before them.
+//
+// The noise of a series is estimated from the differences between its
+// consecutive points, so a step in the series, such as from a regression,
+// barely changes it. Series with no measurable noise are never marked, since
+// any change would be infinitely many times their noise.
+func findAnomalies(summary []point, sigma float64) map[point]anomaly {
+	var out map[point]anomaly
+	sliceBy(summary, pointAesGetter(AesColor), func(_ value, pts []point) {
+		ys := make([]float64, len(pts))
+		for i, pt := range pts {
+			ys[i] = pt.Get(AesY).val
+		}
+		if len(ys) <= anomalyHistory {
+			return
+		}
+		diffs := make([]float64, len(ys)-1)
+		for i := range diffs {
+			diffs[i] = math.Abs(ys[i+1] - ys[i])
+		}
+		// The median absolute difference of two normal samples is
+		// about 0.95 times their standard deviation.
+		noise := median(diffs) / 0.9539
+		if !(noise > 0) || math.IsInf(noise, 0) {
+			return
+		}
+		for i := anomalyHistory; i < len(ys); i++ {
+			expected := median(ys[max(0, i-anomalyWindow):i])
+			dev := (ys[i] - expected) / noise
+			if math.Abs(dev) <= sigma {
+				continue
+			}
+			if out == nil {
+				out = make(map[point]anomaly)
+			}
+			out[pts[i]] = anomaly{expected, dev}
+		}
+	})
+	return out
+}
+
+// median returns the median of xs, without modifying xs.
+func median(xs []float64) float64 {
+	xs = slices.Clone(xs)
+	slices.Sort(xs)
+	n := len(xs)
+	if n%2 == 1 {
+		return xs[n/2]
+	}
+	return (xs[n/2-1] + xs[n/2]) / 2
+}
+
+// Anomalies returns the anomalies of p, as set by [Config.SetAnomalies], in the
+// order of the facets and then their points. Like [Plot.Shape], this reflects
+// any transforms that have been applied to p.
+func (p *Plot) Anomalies() ([]Anomaly, error) {
+	l, err := p.getLayout()
+	if err != nil {
+		return nil, err
+	}
+	var out []Anomaly
+	for col := range l.nCols {
+		for row := range l.nRows {
+			f := l.facets[rowCol{row, col}]
+			for _, pt := range f.summary {
+				a, ok := f.anomalies[pt]
+				if !ok {
+					continue
+				}
+				x := Anomaly{Point: p.pointLabels(pt), Value: pt.Get(AesY).val, Expected: a.expected, Sigma: a.sigma}
+				if units := p.pointsUnits([]point{pt}); len(units) == 1 {
+					x.Unit = units[0]
+				}
+				out = append(out, x)
+			}
+		}
+	}
+	return out, nil
+}
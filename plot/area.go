@@ -0,0 +1,84 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// An Area is where the axes of one facet were drawn in a rendered image, for
+// mapping positions in the image, such as clicks, back to the marks of the
+// [Layout].
+type Area struct {
+	// Row and Col identify the facet, like Facet.Row and Facet.Col.
+	Row, Col int
+	// Left, Right, Top, and Bottom give the edges of the axes as
+	// fractions of the width and height of the image, from its top left
+	// corner.
+	Left, Right, Top, Bottom float64
+	// XMin, XMax, YMin, and YMax give the values at the edges of the
+	// axes, on the same scale as Mark.X and Mark.Y.
+	XMin, XMax, YMin, YMax float64
+	// XLog and YLog give the log base of each axis, or 0 if it's linear.
+	// Values on a log axis are spaced by their logarithm.
+	XLog, YLog int
+}
+
+// areaPrefix starts the lines gnuplot prints to report the area of a facet.
+const areaPrefix = "benchplot-area"
+
+// RenderAreas is like [Plot.RenderContext], but also returns the Area of each
+// facet in the image. opts.Format must be [FormatPNG] or [FormatSVG].
+func (p *Plot) RenderAreas(ctx context.Context, opts RenderOptions, out io.Writer) ([]Area, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Format != FormatPNG && opts.Format != FormatSVG {
+		return nil, fmt.Errorf("cannot find the areas of %s format", opts.Format.Name())
+	}
+	var stderr bytes.Buffer
+	err := p.render(ctx, opts, true, out, &stderr)
+	areas, rest := p.parseAreas(stderr.Bytes())
+	os.Stderr.Write(rest)
+	if err != nil {
+		return nil, err
+	}
+	return areas, nil
+}
+
+// parseAreas parses the areas gnuplot reported in its error output, and
+// returns them and the rest of the output. gnuplot reports each area as a
+// line giving areaPrefix, the row and column, the terminal coordinates of
+// the edges of the axes and the size of the terminal, and the ranges of the
+// axes.
+func (p *Plot) parseAreas(stderr []byte) (areas []Area, rest []byte) {
+	sc := bufio.NewScanner(bytes.NewReader(stderr))
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, areaPrefix+" ") {
+			rest = append(append(rest, line...), '\n')
+			continue
+		}
+		var a Area
+		var xMin, xMax, yMin, yMax, xSize, ySize float64
+		_, err := fmt.Sscan(line[len(areaPrefix):], &a.Row, &a.Col, &xMin, &xMax, &yMin, &yMax, &xSize, &ySize, &a.XMin, &a.XMax, &a.YMin, &a.YMax)
+		if err != nil || xSize <= 0 || ySize <= 0 {
+			rest = append(append(rest, line...), '\n')
+			continue
+		}
+		// Terminal coordinates start at the bottom left.
+		a.Left, a.Right = xMin/xSize, xMax/xSize
+		a.Top, a.Bottom = 1-yMax/ySize, 1-yMin/ySize
+		a.XLog, a.YLog = p.logScale.Get(AesX), p.logScale.Get(AesY)
+		areas = append(areas, a)
+	}
+	return areas, rest
+}
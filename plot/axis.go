@@ -0,0 +1,147 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/perf/benchunit"
+)
+
+// An Axis is the continuous scale of the X or Y axis of a Facet. It gives the
+// domain of the scaled values and can choose and format tick marks the same
+// way for every renderer.
+type Axis struct {
+	Label string
+	// Log is the base of a log scale, or 0 for a linear scale.
+	Log int
+	// Ratio indicates the values are ratios, scaled to a percent
+	// change, such as -5 for a 5% decrease. Percent indicates the
+	// values are coefficients of variation scaled to a percent.
+	Ratio, Percent bool
+	// Min and Max give the range of the scaled values on this axis,
+	// including confidence intervals. A Ratio axis always includes 0.
+	Min, Max float64
+
+	// class is the class of the axis unit, for choosing SI or binary
+	// prefixes.
+	class benchunit.Class
+	// ordinal is the tick of each value of an ordinal axis in the
+	// domain, or nil if the axis is continuous.
+	ordinal []Tick
+}
+
+// A Tick is a tick mark on an Axis.
+type Tick struct {
+	Value float64 // Scaled value
+	Label string
+}
+
+// Ticks returns about n evenly spaced tick marks covering the domain of a,
+// at round values. On a log axis, the ticks are at powers of the base. On an
+// ordinal axis, as set by [Config.SetOrdinalX], the ticks are at most n of
+// its values, labeled with their labels.
+func (a Axis) Ticks(n int) []Tick {
+	if a.ordinal != nil {
+		return thinTicks(a.ordinal, n)
+	}
+	if n < 1 || math.IsNaN(a.Min) || math.IsNaN(a.Max) || math.IsInf(a.Min, 0) || math.IsInf(a.Max, 0) {
+		return nil
+	}
+	var vals []float64
+	var step float64
+	if a.Log > 1 && a.Min > 0 {
+		base := float64(a.Log)
+		lo := math.Floor(math.Log(a.Min)/math.Log(base) + 1e-9)
+		hi := math.Ceil(math.Log(a.Max)/math.Log(base) - 1e-9)
+		for e := lo; e <= hi; e++ {
+			vals = append(vals, math.Pow(base, e))
+		}
+	} else {
+		// Choose a round step in the axis's prefix, so binary
+		// units get ticks at multiples of Ki, Mi, and so on.
+		factor := a.scaler().Factor
+		step = niceStep((a.Max - a.Min) / factor / float64(n))
+		if step == 0 {
+			vals = []float64{a.Min}
+		} else {
+			lo, hi := math.Floor(a.Min/factor/step+1e-9), math.Ceil(a.Max/factor/step-1e-9)
+			for i := lo; i <= hi; i++ {
+				// Round off floating-point error, such as
+				// 3*0.2 = 0.6000000000000001.
+				v, _ := strconv.ParseFloat(strconv.FormatFloat(i*step*factor, 'g', 12, 64), 64)
+				vals = append(vals, v)
+			}
+			step *= factor
+		}
+	}
+	ticks := make([]Tick, len(vals))
+	for i, v := range vals {
+		if v == 0 {
+			v = 0 // Not -0
+		}
+		ticks[i] = Tick{v, a.format(v, step)}
+	}
+	return ticks
+}
+
+// niceStep returns the smallest step of the form 1, 2, or 5 times a power of
+// 10 that is at least x.
+func niceStep(x float64) float64 {
+	if x <= 0 || math.IsInf(x, 0) || math.IsNaN(x) {
+		return 0
+	}
+	pow := math.Pow(10, math.Floor(math.Log10(x)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*pow >= x*(1-1e-9) {
+			return m * pow
+		}
+	}
+	return 10 * pow
+}
+
+// Format formats scaled value v for display on a. Ratios and percents are
+// formatted as percentages, and other values with a unit prefix such as "k"
+// or "Ki".
+func (a Axis) Format(v float64) string {
+	return a.format(v, 0)
+}
+
+// scaler returns the unit prefix for the largest magnitude on a.
+func (a Axis) scaler() benchunit.Scaler {
+	if a.Ratio || a.Percent {
+		return benchunit.Scaler{Factor: 1}
+	}
+	return benchunit.CommonScale([]float64{max(math.Abs(a.Min), math.Abs(a.Max))}, a.class)
+}
+
+// format formats v like Format. If step is not 0, it's the spacing between
+// ticks, which determines the precision. Like gnuplot's tick labels, each
+// value gets its own unit prefix.
+func (a Axis) format(v, step float64) string {
+	s := benchunit.Scaler{Prec: 3, Factor: 1}
+	if !a.Ratio && !a.Percent {
+		s = benchunit.CommonScale([]float64{v}, a.class)
+	}
+	if step != 0 {
+		s.Prec = max(0, int(-math.Floor(math.Log10(step/s.Factor)+1e-9)))
+	}
+	str := strconv.FormatFloat(v/s.Factor, 'f', s.Prec, 64)
+	if strings.Contains(str, ".") {
+		str = strings.TrimRight(strings.TrimRight(str, "0"), ".")
+	}
+	switch {
+	case a.Ratio:
+		if v > 0 {
+			str = "+" + str
+		}
+		return str + "%"
+	case a.Percent:
+		return str + "%"
+	}
+	return str + s.Prefix
+}
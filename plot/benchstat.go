@@ -0,0 +1,444 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/perf/benchmath"
+	"golang.org/x/perf/benchunit"
+)
+
+// TableFormat is an output format for [Plot.WriteTable].
+type TableFormat int
+
+const (
+	TableText     TableFormat = iota // Aligned columns, like benchstat
+	TableMarkdown                    // A Markdown table
+
+	tableFormatMax
+)
+
+// Name returns a short name for format f, such as "markdown".
+func (f TableFormat) Name() string {
+	switch f {
+	case TableText:
+		return "text"
+	case TableMarkdown:
+		return "markdown"
+	}
+	return fmt.Sprintf("TableFormat(%d)", f)
+}
+
+var nameToTableFormat = sync.OnceValue(func() map[string]TableFormat {
+	m := make(map[string]TableFormat)
+	for i := TableFormat(0); i < tableFormatMax; i++ {
+		m[i.Name()] = i
+	}
+	return m
+})
+
+// TableFormatFromName is the inverse of [TableFormat.Name].
+func TableFormatFromName(name string) (TableFormat, bool) {
+	f, ok := nameToTableFormat()[name]
+	return f, ok
+}
+
+// A statTable is the comparison of one unit in a table written by WriteTable.
+type statTable struct {
+	unit  string
+	cols  []value      // Values of the color aesthetic; the first is the baseline
+	rows  []point      // Points without their color and DV
+	cells [][]statCell // cells[row][col]
+}
+
+// A statCell summarizes the measurements of one row and column of a
+// statTable.
+type statCell struct {
+	ok       bool
+	summary  benchmath.Summary
+	cmp      benchmath.Comparison // Against the baseline, if ok and baseOK
+	baseOK   bool
+	warnings []error
+}
+
+// A statLine is a formatted line of the body of a statTable. Each column has
+// a center, range, delta, and comparison. The baseline has no delta or
+// comparison.
+type statLine struct {
+	label string
+	cols  [][4]string
+}
+
+// WriteTable writes a comparison of p's series to w, in the layout of
+// benchstat. Each value of the color aesthetic is a column, compared against
+// the first, which is the baseline, as [Plot.TransformCompare] does. Each row
+// is a point, labeled with its value of every other aesthetic, and there's a
+// table for each unit.
+//
+// This needs the measurements summarized into each point, so it must be
+// called before any transforms, and p must not be streaming.
+func (p *Plot) WriteTable(w io.Writer, format TableFormat) error {
+	if p.dvAes == aesNone {
+		return fmt.Errorf("table requires a dimension showing .value")
+	}
+	p.flushStreaming()
+	pts := p.points.all()
+	if pointsKinds(pts, p.dvAes)&(kindSummary|kindRatio) != 0 || p.variability != varNone {
+		return fmt.Errorf("table requires the measurements of each point, before any transforms or streaming")
+	}
+	if len(pts) == 0 {
+		return fmt.Errorf("no data")
+	}
+
+	withoutColor := func(pt point) point {
+		pt.Set(AesColor, value{})
+		pt.Set(p.dvAes, value{})
+		return pt
+	}
+	slices.SortStableFunc(pts, func(a, b point) int {
+		for _, aes := range tableAes {
+			if aes == p.dvAes {
+				continue
+			}
+			av, bv := a.Get(aes), b.Get(aes)
+			if av.kinds&bv.kinds&kindContinuous != 0 {
+				if c := cmp.Compare(av.val, bv.val); c != 0 {
+					return c
+				}
+				continue
+			}
+			if c := av.compare(bv); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	_, rows := groupBy(pts, withoutColor)
+	_, cols := groupBy(pts, pointAesGetter(AesColor))
+	slices.SortFunc(cols, func(a, b value) int {
+		return a.compare(b)
+	})
+	samples, _ := groupBy(pts, func(pt point) point {
+		pt.Set(p.dvAes, value{})
+		return pt
+	})
+
+	// Split the rows into a table for each unit.
+	units, unitNames := groupBy(rows, func(pt point) string {
+		if p.unitField == nil {
+			return ""
+		}
+		return pt.Get(p.unitAes).key.Get(p.unitField)
+	})
+	var tables []*statTable
+	for _, unit := range unitNames {
+		t := &statTable{unit: unit, cols: cols, rows: units[unit]}
+		for _, row := range t.rows {
+			assume := p.assumption(row)
+			cells := make([]statCell, len(cols))
+			var base *benchmath.Sample
+			for i, col := range cols {
+				key := row
+				key.Set(AesColor, col)
+				group, ok := samples[key]
+				if !ok {
+					continue
+				}
+				sample := pointsToSample(group, p.dvAes)
+				c := &cells[i]
+				c.ok = true
+				c.summary = assume.Summary(sample, renderConfidence)
+				c.warnings = append(slices.Clone(sample.Warnings), c.summary.Warnings...)
+				if i == 0 {
+					base = sample
+				} else if base != nil {
+					c.cmp, c.baseOK = assume.Compare(base, sample), true
+					c.warnings = append(c.warnings, c.cmp.Warnings...)
+				}
+			}
+			t.cells = append(t.cells, cells)
+		}
+		tables = append(tables, t)
+	}
+
+	for i, t := range tables {
+		if i > 0 {
+			fmt.Fprintf(w, "\n")
+		}
+		if err := p.writeStatTable(w, t, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableAes is the aesthetics that identify the rows of a statTable, in the
+// order they're sorted, which is the order facets are laid out in.
+var tableAes = []Aes{AesCol, AesRow, AesX, AesY}
+
+// tableLabel returns the label of a row of a statTable, which is the value of
+// each field of each aesthetic in tableAes, other than the DV and the unit.
+func (p *Plot) tableLabel(row point) string {
+	var parts []string
+	for _, aes := range tableAes {
+		proj := p.aes.Get(aes)
+		if aes == p.dvAes || proj.iv == nil {
+			continue
+		}
+		key := row.Get(aes).key
+		for _, f := range proj.iv.Fields() {
+			if f != p.unitField && key.Get(f) != "" {
+				parts = append(parts, key.Get(f))
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return "all"
+	}
+	return strings.Join(parts, " ")
+}
+
+// writeStatTable writes t to w in format.
+func (p *Plot) writeStatTable(w io.Writer, t *statTable, format TableFormat) error {
+	class := benchunit.ClassOf(t.unit)
+	unit := t.unit
+	if unit == "" {
+		unit = ".value"
+	}
+
+	// Number each distinct warning, for footnotes.
+	var notes []string
+	noteRefs := func(errs []error) string {
+		var refs []string
+		for _, err := range errs {
+			i := slices.Index(notes, err.Error())
+			if i < 0 {
+				i = len(notes)
+				notes = append(notes, err.Error())
+			}
+			refs = append(refs, superscript(i+1))
+		}
+		return strings.Join(refs, " ")
+	}
+
+	// Format the body of the table.
+	var body []statLine
+	for r, row := range t.rows {
+		l := statLine{label: p.tableLabel(row), cols: make([][4]string, len(t.cols))}
+		var centers []float64
+		for _, c := range t.cells[r] {
+			if c.ok {
+				centers = append(centers, c.summary.Center)
+			}
+		}
+		scaler := benchunit.CommonScale(centers, class)
+		for i, c := range t.cells[r] {
+			if !c.ok {
+				continue
+			}
+			l.cols[i][0] = scaler.Format(c.summary.Center)
+			l.cols[i][1] = c.summary.PctRangeString()
+			if c.baseOK {
+				l.cols[i][2] = c.cmp.FormatDelta(t.cells[r][0].summary.Center, c.summary.Center)
+				l.cols[i][3] = "(" + c.cmp.String() + ")"
+			}
+			if refs := noteRefs(c.warnings); refs != "" {
+				if i == 0 {
+					l.cols[i][1] += " " + refs
+				} else {
+					l.cols[i][3] += " " + refs
+				}
+			}
+		}
+		body = append(body, l)
+	}
+	if len(t.rows) > 1 {
+		body = append(body, t.geomean(class))
+	}
+
+	switch format {
+	case TableMarkdown:
+		row := func(cells ...string) {
+			fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+		}
+		names, units, rule := []string{""}, []string{""}, []string{"---"}
+		for i, col := range t.cols {
+			names = append(names, mdEscape(col.StringValues()))
+			units = append(units, mdEscape(unit))
+			rule = append(rule, "---:")
+			if i > 0 {
+				names = append(names, "")
+				units = append(units, "vs base")
+				rule = append(rule, "---")
+			}
+		}
+		row(names...)
+		row(units...)
+		row(rule...)
+		for _, l := range body {
+			cells := []string{mdEscape(l.label)}
+			for i, c := range l.cols {
+				center := c[0]
+				if c[1] != "" {
+					center += " ± " + c[1]
+				}
+				cells = append(cells, center)
+				if i > 0 {
+					cells = append(cells, strings.TrimSpace(c[2]+" "+c[3]))
+				}
+			}
+			row(cells...)
+		}
+		for i, note := range notes {
+			fmt.Fprintf(w, "\n%s %s\n", superscript(i+1), mdEscape(note))
+		}
+		return nil
+	}
+
+	// Find the width of each column. The center and delta are right
+	// aligned, and the range and comparison left aligned.
+	width := func(s string) int { return utf8.RuneCountInString(s) }
+	labelWidth := 0
+	colWidths := make([][4]int, len(t.cols))
+	for _, l := range body {
+		labelWidth = max(labelWidth, width(l.label))
+		for i, c := range l.cols {
+			for j := range c {
+				colWidths[i][j] = max(colWidths[i][j], width(c[j]))
+			}
+		}
+	}
+	groupWidth := func(i int) int {
+		n := colWidths[i][0] + len(" ± ") + colWidths[i][1]
+		if i > 0 {
+			n += len("  ") + colWidths[i][2] + len(" ") + colWidths[i][3]
+		}
+		return n
+	}
+	// Widen each group to fit its header.
+	for i, col := range t.cols {
+		header := max(width(col.StringValues()), width(unit)+len("  vs base"))
+		if extra := header - groupWidth(i); extra > 0 {
+			colWidths[i][0] += extra
+		}
+	}
+	pad := func(s string, n int, right bool) string {
+		fill := strings.Repeat(" ", max(0, n-width(s)))
+		if right {
+			return fill + s
+		}
+		return s + fill
+	}
+	center := func(s string, n int) string {
+		left := max(0, n-width(s)) / 2
+		return pad(strings.Repeat(" ", left)+s, n, false)
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", labelWidth))
+	for i, col := range t.cols {
+		b.WriteString(" │ " + center(col.StringValues(), groupWidth(i)))
+	}
+	b.WriteString(" │\n")
+	b.WriteString(strings.Repeat(" ", labelWidth))
+	for i := range t.cols {
+		head := center(unit, colWidths[i][0]+len(" ± ")+colWidths[i][1])
+		if i > 0 {
+			head += pad("  vs base", groupWidth(i)-width(head), false)
+		}
+		b.WriteString(" │ " + head)
+	}
+	b.WriteString(" │\n")
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+	for _, l := range body {
+		b.Reset()
+		b.WriteString(pad(l.label, labelWidth, false))
+		for i, c := range l.cols {
+			b.WriteString("   " + pad(c[0], colWidths[i][0], true))
+			if c[1] != "" {
+				b.WriteString(" ± ")
+			} else {
+				b.WriteString("   ")
+			}
+			b.WriteString(pad(c[1], colWidths[i][1], false))
+			if i > 0 {
+				b.WriteString("  " + pad(c[2], colWidths[i][2], true) + " " + pad(c[3], colWidths[i][3], false))
+			}
+		}
+		// Drop the padding of the last column.
+		if _, err := fmt.Fprintf(w, "%s\n", strings.TrimRight(b.String(), " ")); err != nil {
+			return err
+		}
+	}
+	for i, note := range notes {
+		if _, err := fmt.Fprintf(w, "%s %s\n", superscript(i+1), note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// geomean returns the summary line of t, which gives the geometric mean of
+// each column and, for each column but the baseline, the geometric mean of
+// its ratios to the baseline. As in benchstat, these are only meaningful if
+// every value is positive.
+func (t *statTable) geomean(class benchunit.Class) statLine {
+	l := statLine{label: "geomean", cols: make([][4]string, len(t.cols))}
+	for i := range t.cols {
+		logSum, n, ok := 0.0, 0, true
+		ratioSum, nRatio := 0.0, 0
+		for r := range t.rows {
+			c := t.cells[r][i]
+			if !c.ok {
+				continue
+			}
+			if c.summary.Center <= 0 {
+				ok = false
+				break
+			}
+			logSum += math.Log(c.summary.Center)
+			n++
+			if base := t.cells[r][0]; i > 0 && base.ok && base.summary.Center > 0 {
+				ratioSum += math.Log(c.summary.Center / base.summary.Center)
+				nRatio++
+			}
+		}
+		if !ok || n == 0 {
+			l.cols[i][0] = "?"
+			continue
+		}
+		l.cols[i][0] = benchunit.Scale(math.Exp(logSum/float64(n)), class)
+		if i > 0 && nRatio > 0 {
+			l.cols[i][2] = fmt.Sprintf("%+.2f%%", (math.Exp(ratioSum/float64(nRatio))-1)*100)
+		}
+	}
+	return l
+}
+
+var superDigits = []rune("⁰¹²³⁴⁵⁶⁷⁸⁹")
+
+// superscript returns i in superscript digits, for a footnote reference.
+func superscript(i int) string {
+	var digits []rune
+	for _, d := range fmt.Sprint(i) {
+		digits = append(digits, superDigits[d-'0'])
+	}
+	return string(digits)
+}
+
+// mdEscape escapes the characters of s that would break a Markdown table.
+func mdEscape(s string) string {
+	return strings.NewReplacer(`|`, `\|`, `*`, `\*`, `_`, `\_`).Replace(s)
+}
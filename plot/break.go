@@ -0,0 +1,105 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"math"
+	"slices"
+)
+
+// breakGap is the smallest fraction of the range of an axis that a gap
+// between its values must cover for the axis to be broken at the gap.
+const breakGap = 0.75
+
+// An axisBreak is a break in an axis between two clusters of values that are
+// far apart. The axis skips from lo to hi, and the values above the break are
+// scaled by scale relative to those below, so each cluster gets half of the
+// axis however far apart they are.
+type axisBreak struct {
+	min, lo, hi, max float64 // The clusters are [min, lo] and [hi, max]
+	scale            float64
+}
+
+// findBreak returns the break of an axis showing vals, or nil if the values
+// don't fall in two clusters separated by at least breakGap of their range.
+// It modifies vals.
+func findBreak(vals []float64) *axisBreak {
+	vals = slices.DeleteFunc(vals, func(v float64) bool {
+		return math.IsInf(v, 0) || math.IsNaN(v)
+	})
+	if len(vals) < 2 {
+		return nil
+	}
+	slices.Sort(vals)
+
+	// Find the largest gap between values.
+	gapAt := 0
+	for i := range vals[1:] {
+		if vals[i+1]-vals[i] > vals[gapAt+1]-vals[gapAt] {
+			gapAt = i
+		}
+	}
+	first, last := vals[0], vals[len(vals)-1]
+	lo, hi := vals[gapAt], vals[gapAt+1]
+	if hi-lo <= breakGap*(last-first) {
+		return nil
+	}
+
+	// Leave a margin between each cluster and the break.
+	pad := func(lo, hi float64) float64 {
+		if lo == hi {
+			return 0.05 * (last - first)
+		}
+		return 0.05 * (hi - lo)
+	}
+	b := &axisBreak{min: first, lo: lo + pad(first, lo), hi: hi - pad(hi, last), max: last}
+	b.scale = (b.lo - b.min) / (b.max - b.hi)
+	return b
+}
+
+// scaled returns b in the coordinates given by scale, which must be
+// increasing and linear.
+func (b *axisBreak) scaled(scale func(float64) float64) *axisBreak {
+	return &axisBreak{scale(b.min), scale(b.lo), scale(b.hi), scale(b.max), b.scale}
+}
+
+// bounds returns the range of an axis broken by b, which leaves a margin
+// around the clusters.
+func (b *axisBreak) bounds() (lo, hi float64) {
+	return b.min - 0.05*(b.lo-b.min), b.max + 0.05*(b.max-b.hi)
+}
+
+// squash returns the position of v on an axis broken by b, where the break
+// takes no space and the values above it are scaled.
+func (b *axisBreak) squash(v float64) float64 {
+	if v <= b.lo {
+		return v
+	}
+	return b.lo + (v-b.hi)*b.scale
+}
+
+// ticks returns the positions of the tick marks of an axis broken by b. With
+// the default tick marks, which are evenly spaced over the whole axis, a
+// cluster with a much smaller range than the other would get few or none.
+func (b *axisBreak) ticks() []float64 {
+	return append(niceTicks(b.min, b.lo), niceTicks(b.hi, b.max)...)
+}
+
+// niceTicks returns about four evenly spaced, round positions in [lo, hi].
+func niceTicks(lo, hi float64) []float64 {
+	step := (hi - lo) / 4
+	mag := math.Pow(10, math.Floor(math.Log10(step)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*mag >= step {
+			step = m * mag
+			break
+		}
+	}
+	var ticks []float64
+	for i := math.Ceil(lo / step); i*step <= hi; i++ {
+		ticks = append(ticks, i*step)
+	}
+	return ticks
+}
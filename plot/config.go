@@ -0,0 +1,305 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"fmt"
+
+	"golang.org/x/perf/benchproc"
+)
+
+// A Config gives the mapping from benchmark results to aesthetics and the
+// options for a [Plot]. The zero value maps nothing; use [Config.SetIV] and
+// [Config.SetDV] to map the results onto each aesthetic.
+type Config struct {
+	aes aesMap[projection]
+
+	logScale     aesMap[int]
+	geom         Geom
+	xLabels      *benchproc.Projection
+	breakY       bool
+	dropEmpty    bool
+	directLabels bool
+	anomalySigma float64
+
+	diffBase, diffTarget string
+
+	vlines, hlines []RefLine
+	hbands         []RefBand
+	regions        []RefBand
+	notes          []Annotation
+	watermark      string
+
+	direction Direction
+	highlight *benchproc.Filter
+
+	noisiest int
+
+	streaming, streamMean bool
+
+	residue *benchproc.Projection
+
+	gnuplot string
+
+	keepScript string
+}
+
+// NewConfig returns a new Config with no mappings and the default options.
+func NewConfig() *Config {
+	return &Config{}
+}
+
+// SetIV maps independent variable iv to aesthetic aes.
+func (c *Config) SetIV(aes Aes, iv *benchproc.Projection) {
+	fields := iv.Fields()
+	var ivField *benchproc.Field
+	if len(fields) == 1 && !fields[0].IsTuple {
+		ivField = fields[0]
+	}
+	var unitField *benchproc.Field
+	for _, field := range fields {
+		if field.Name == ".unit" {
+			unitField = field
+		}
+	}
+	c.aes.Set(aes, projection{iv: iv, ivField: ivField, unitField: unitField})
+}
+
+// SetDV maps the dependent variable to aesthetic aes.
+func (c *Config) SetDV(aes Aes) {
+	c.aes.Set(aes, projection{dv: true})
+}
+
+// SetLogScale sets the aesthetic dimension aes to use a log scale in the given
+// base. Base 0 represents a linear scale.
+func (c *Config) SetLogScale(aes Aes, base int) {
+	c.logScale.Set(aes, base)
+}
+
+// SetGeom sets how the points of each series are drawn. The default,
+// [GeomLine], connects them with lines, for X values that are a sequence,
+// such as sizes or commits. [GeomDotInterval] draws each point as a dot with
+// an error bar for its confidence interval, for X values that are separate
+// categories, such as benchmark names. These needn't be numeric: non-numeric
+// X values are spaced evenly, in order, and labeled with their values. On such
+// an axis, the series at each X value are drawn side by side.
+func (c *Config) SetGeom(geom Geom) {
+	c.geom = geom
+}
+
+// SetOrdinalX sets the X axis to space its distinct values evenly, in order,
+// rather than in proportion to their differences, such as for a sequence of
+// commits whose dates are unevenly spaced. Each tick mark is labeled with the
+// value of labels in the results at that X value, such as a short commit hash.
+// If labels is nil, the X axis is continuous. An ordinal axis ignores any log
+// scale.
+func (c *Config) SetOrdinalX(labels *benchproc.Projection) {
+	c.xLabels = labels
+}
+
+// SetBreakY sets whether to break the Y axis of each row of facets whose
+// values fall in two clusters far apart, such as when a few series are orders
+// of magnitude larger than the rest, so the smaller values aren't flattened
+// against the bottom of the facets. Each cluster gets half of the axis. This
+// has no effect on a log scale Y axis, which already shows many magnitudes.
+func (c *Config) SetBreakY(breakY bool) {
+	c.breakY = breakY
+}
+
+// SetDropEmpty sets whether to drop the facets that have no points, rather
+// than leaving blank facets where a row and column have no points in common.
+// The remaining facets are wrapped into the squarest grid that holds them, and
+// each is titled with both its row and column.
+func (c *Config) SetDropEmpty(drop bool) {
+	c.dropEmpty = drop
+}
+
+// SetDirectLabels sets whether to label each series with its name at the right
+// end of its line, rather than in a key. Labels that would overlap are moved
+// apart. This is easier to read than a key when there are many series with
+// similar colors. Without a key, confidence intervals aren't labeled.
+func (c *Config) SetDirectLabels(direct bool) {
+	c.directLabels = direct
+}
+
+// SetAnomalies sets the plot to mark each point more than sigma times its
+// series' noise from the median of the points before it, such as a one-off
+// spike in a history of results. The noise of a series is estimated from the
+// differences between its consecutive points. Series with no measurable noise
+// are never marked. If sigma is 0, no points are marked. See
+// [Plot.Anomalies].
+func (c *Config) SetAnomalies(sigma float64) {
+	c.anomalySigma = sigma
+}
+
+// SetDiff sets the plot to draw a smaller panel beneath each facet, sharing its
+// X axis, that shows the percent change from the series labeled base to the
+// series labeled target at each X value they share. This is the usual way to
+// present two configurations' performance curves. If base is "", there's no
+// difference panel. A difference panel can't be used with
+// [Plot.TransformCompare].
+func (c *Config) SetDiff(base, target string) {
+	c.diffBase, c.diffTarget = base, target
+}
+
+// AddVLine adds a vertical line across every facet at X value line.At, such as
+// to mark when a change landed on a plot of history.
+func (c *Config) AddVLine(line RefLine) {
+	c.vlines = append(c.vlines, line)
+}
+
+// AddHLine adds a horizontal line across every facet at Y value line.At, such
+// as to show a performance target.
+func (c *Config) AddHLine(line RefLine) {
+	c.hlines = append(c.hlines, line)
+}
+
+// AddHBand adds a band across every facet between Y values band.Lo and
+// band.Hi, such as to show a range of acceptable performance.
+func (c *Config) AddHBand(band RefBand) {
+	c.hbands = append(c.hbands, band)
+}
+
+// AddRegion adds a shaded region across every facet between X values
+// region.Lo and region.Hi, such as to mark a range of commits with a known
+// problem. Like bands, regions are drawn behind the data.
+func (c *Config) AddRegion(region RefBand) {
+	c.regions = append(c.regions, region)
+}
+
+// AddAnnotation adds text at a point in data coordinates, as described by
+// [Annotation].
+func (c *Config) AddAnnotation(note Annotation) {
+	c.notes = append(c.notes, note)
+}
+
+// SetWatermark sets text to draw in small type in the bottom right corner of
+// the plot, such as a team name or the URL of a dashboard, for plots that are
+// shared. If text is "", there's no watermark.
+func (c *Config) SetWatermark(text string) {
+	c.watermark = text
+}
+
+// SetDirection sets which direction of change is highlighted when plotting
+// comparisons. The default, [DirectionBoth], highlights both regressions and
+// improvements.
+func (c *Config) SetDirection(dir Direction) {
+	c.direction = dir
+}
+
+// SetHighlight sets a filter selecting the series to highlight. The series of
+// any result that matches f are drawn with thicker lines, and the rest are
+// dimmed and drawn beneath them. If f is nil, no series are highlighted.
+func (c *Config) SetHighlight(f *benchproc.Filter) {
+	c.highlight = f
+}
+
+// SetNoisiest sets the number of series kept by [Plot.TransformNoisiest]. If n
+// is 0, all series are kept.
+func (c *Config) SetNoisiest(n int) {
+	c.noisiest = n
+}
+
+// SetStreaming sets whether the Plot summarizes each group of points as they
+// are added, rather than retaining every point. This bounds memory use to the
+// number of distinct points in the final plot, but transforms that need the
+// individual points, such as [Plot.TransformCoV], will fail. Groups of more
+// than 1024 values are summarized from a random sample of them. The sample is
+// drawn with a fixed seed, so the same inputs always give the same sample and
+// the same plot.
+func (c *Config) SetStreaming(streaming bool) {
+	c.streaming = streaming
+}
+
+// SetStreamingMean sets whether streaming summarizes each group by the mean
+// of all of its values and the confidence interval of that mean, which it
+// computes from running moments, rather than by the median of a sample of its
+// values. This ignores the "assume" unit metadata and assumes the values are
+// normally distributed, but the summary is exact however large the group
+// grows. It only matters if streaming is enabled by [Config.SetStreaming].
+func (c *Config) SetStreamingMean(mean bool) {
+	c.streamMean = mean
+}
+
+// SetGnuplot sets the gnuplot binary used to render the plot. If path is "",
+// the Plot finds gnuplot using [FindGnuplot].
+func (c *Config) SetGnuplot(path string) {
+	c.gnuplot = path
+}
+
+// SetKeepScript sets a file where [Plot.Render] saves the script it
+// generates, including the data, for debugging. If path is "", the script is
+// not saved.
+func (c *Config) SetKeepScript(path string) {
+	c.keepScript = path
+}
+
+// SetResidue sets the projection of the fields not mapped to any aesthetic. If
+// set, the Plot tracks the residue of its measurements so
+// [Plot.WriteDiagnostics] can report which residue fields varied.
+func (c *Config) SetResidue(residue *benchproc.Projection) {
+	c.residue = residue
+}
+
+// Geom selects how the points of each series are drawn.
+type Geom int
+
+const (
+	GeomLine        Geom = iota // Points connected by lines
+	GeomDotInterval             // Dots with error bars, unconnected
+)
+
+// Name returns a short name for geom g, such as "dot-interval".
+func (g Geom) Name() string {
+	switch g {
+	case GeomLine:
+		return "line"
+	case GeomDotInterval:
+		return "dot-interval"
+	}
+	return fmt.Sprintf("Geom(%d)", g)
+}
+
+// GeomFromName is the inverse of [Geom.Name].
+func GeomFromName(name string) (Geom, bool) {
+	for g := GeomLine; g <= GeomDotInterval; g++ {
+		if g.Name() == name {
+			return g, true
+		}
+	}
+	return 0, false
+}
+
+// Direction selects which direction of change is of interest in a comparison.
+type Direction int
+
+const (
+	DirectionBoth Direction = iota
+	DirectionRegressions
+	DirectionImprovements
+)
+
+// Name returns a short name for direction d, such as "regressions".
+func (d Direction) Name() string {
+	switch d {
+	case DirectionBoth:
+		return "both"
+	case DirectionRegressions:
+		return "regressions"
+	case DirectionImprovements:
+		return "improvements"
+	}
+	return fmt.Sprintf("Direction(%d)", d)
+}
+
+// DirectionFromName is the inverse of [Direction.Name].
+func DirectionFromName(name string) (Direction, bool) {
+	for d := DirectionBoth; d <= DirectionImprovements; d++ {
+		if d.Name() == name {
+			return d, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,216 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"golang.org/x/perf/benchproc"
+)
+
+// addResidue records that the measurement at pt came from a result with
+// residue key rk.
+func (p *Plot) addResidue(pt point, rk benchproc.Key) {
+	if p.dvAes != aesNone {
+		pt.Set(p.dvAes, value{})
+	}
+	if p.residues == nil {
+		p.residues = make(map[point]map[benchproc.Key]struct{})
+	}
+	set := p.residues[pt]
+	if set == nil {
+		set = make(map[benchproc.Key]struct{})
+		p.residues[pt] = set
+	}
+	set[rk] = struct{}{}
+}
+
+// WriteDiagnostics writes a description of how p's data is grouped to w. For
+// each facet and series, it reports the number of measurements and points,
+// the number of measurements summarized into each point, and, if the Config
+// has a residue, which residue fields varied among the measurements
+// summarized into a single point.
+//
+// This reflects any transforms that have been applied to p.
+func (p *Plot) WriteDiagnostics(w io.Writer) {
+	p.flushStreaming()
+	pts := p.points.all()
+	group := []Aes{AesRow, AesCol, AesColor}
+	slices.SortStableFunc(pts, func(a, b point) int {
+		for _, aes := range group {
+			if c := a.Get(aes).compare(b.Get(aes)); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+
+	facetOf := func(pt point) [2]value {
+		return [2]value{pt.Get(AesRow), pt.Get(AesCol)}
+	}
+	sliceBy(pts, facetOf, func(facet [2]value, pts []point) {
+		fmt.Fprintf(w, "facet row %s, col %s:\n", diagLabel(facet[0]), diagLabel(facet[1]))
+		sliceBy(pts, pointAesGetter(AesColor), func(color value, pts []point) {
+			groups, keys := groupBy(pts, func(pt point) point {
+				if p.dvAes != aesNone {
+					pt.Set(p.dvAes, value{})
+				}
+				return pt
+			})
+			lo, hi := len(pts), 0
+			var varied []string
+			for _, k := range keys {
+				lo, hi = min(lo, len(groups[k])), max(hi, len(groups[k]))
+				for _, f := range p.variedResidue(k) {
+					if !slices.Contains(varied, f) {
+						varied = append(varied, f)
+					}
+				}
+			}
+			samples := fmt.Sprint(lo)
+			if lo != hi {
+				samples = fmt.Sprintf("%d-%d", lo, hi)
+			}
+			fmt.Fprintf(w, "  color %s: %d measurements in %d points, %s per point", diagLabel(color), len(pts), len(keys), samples)
+			if len(varied) > 0 {
+				fmt.Fprintf(w, "; residue varied: %s", strings.Join(varied, ", "))
+			}
+			fmt.Fprintf(w, "\n")
+		})
+	})
+}
+
+// A Shape gives the size of a plot.
+type Shape struct {
+	Rows, Cols   int // Dimensions of the grid of facets
+	Facets       int // Facets that have data
+	Series       int // Series across all facets
+	Points       int // Points across all series
+	Measurements int // Measurements summarized into the points
+	Results      int // Results added to the plot
+}
+
+// Shape returns the size p would have if it were rendered. Like
+// WriteDiagnostics, this reflects any transforms that have been applied to p.
+func (p *Plot) Shape() Shape {
+	p.flushStreaming()
+	rows := make(map[value]struct{})
+	cols := make(map[value]struct{})
+	facets := make(map[[2]value]struct{})
+	series := make(map[[3]value]struct{})
+	points := make(map[point]struct{})
+	for i := range p.points.len() {
+		pt := p.points.get(i)
+		row, col, color := pt.Get(AesRow), pt.Get(AesCol), pt.Get(AesColor)
+		rows[row] = struct{}{}
+		cols[col] = struct{}{}
+		facets[[2]value{row, col}] = struct{}{}
+		series[[3]value{row, col, color}] = struct{}{}
+		if p.dvAes != aesNone {
+			pt.Set(p.dvAes, value{})
+		}
+		points[pt] = struct{}{}
+	}
+	measurements := p.points.len()
+	if p.streaming {
+		// Each point is already a summary.
+		measurements = p.nStreamed
+	}
+	return Shape{len(rows), len(cols), len(facets), len(series), len(points), measurements, p.nResults}
+}
+
+// pointLabels returns the value of each aesthetic of pt, other than the DV,
+// indexed by aesthetic name. It omits aesthetics with no fields.
+func (p *Plot) pointLabels(pt point) map[string]string {
+	labels := make(map[string]string)
+	for aes := range aesMax {
+		if proj := p.aes.Get(aes); aes == p.dvAes || proj.iv == nil || len(proj.iv.Fields()) == 0 {
+			continue
+		}
+		labels[aes.Name()] = pt.Get(aes).key.StringValues()
+	}
+	return labels
+}
+
+// A Warning describes a possible problem with the measurements summarized
+// into a single point.
+type Warning struct {
+	// Kind is "residue" if the measurements differ in fields not mapped
+	// to any aesthetic, which is only checked if the Config has a
+	// residue, or "sample" if the measurements are too few or too
+	// uniform to compute a confidence interval.
+	Kind string
+	// Point gives the value of each aesthetic that identifies the
+	// point, indexed by aesthetic name.
+	Point map[string]string
+	Msg   string
+}
+
+// Warnings returns the possible problems with the points of p. Like
+// WriteDiagnostics, this reflects any transforms that have been applied to p.
+func (p *Plot) Warnings() []Warning {
+	p.flushStreaming()
+	groups, keys := groupBy(p.points.all(), func(pt point) point {
+		if p.dvAes != aesNone {
+			pt.Set(p.dvAes, value{})
+		}
+		return pt
+	})
+	var warnings []Warning
+	for _, k := range keys {
+		if varied := p.variedResidue(k); len(varied) > 0 {
+			warnings = append(warnings, Warning{"residue", p.pointLabels(k), "measurements differ in " + strings.Join(varied, ", ")})
+		}
+		// Check samples that will be summarized when plotting. Points
+		// that are already summaries or ratios have been checked, or
+		// can't be.
+		pts := groups[k]
+		if p.dvAes == aesNone || pts[0].Get(p.dvAes).kinds&(kindSummary|kindRatio) != 0 || p.variability != varNone {
+			continue
+		}
+		summary := p.assumption(k).Summary(pointsToSample(pts, p.dvAes), 0.95)
+		for _, w := range summary.Warnings {
+			warnings = append(warnings, Warning{"sample", p.pointLabels(k), w.Error()})
+		}
+	}
+	return warnings
+}
+
+// variedResidue returns the names of the residue fields that took more than
+// one value among the measurements in the group of points identified by key.
+func (p *Plot) variedResidue(key point) []string {
+	set := p.residues[key]
+	if len(set) < 2 {
+		return nil
+	}
+	var varied []string
+	for _, f := range p.residue.FlattenedFields() {
+		if f.IsTuple {
+			continue
+		}
+		first, ok := "", false
+		for rk := range set {
+			v := rk.Get(f)
+			if !ok {
+				first, ok = v, true
+			} else if v != first {
+				varied = append(varied, f.Name)
+				break
+			}
+		}
+	}
+	return varied
+}
+
+// diagLabel returns a label for v in diagnostics.
+func diagLabel(v value) string {
+	if v.kinds&kindDiscrete != 0 && (v.key.IsZero() || v.key.String() == "") {
+		return "(all)"
+	}
+	return v.String()
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// diffFraction is the fraction of the height of each facet taken by its
+// difference panel, when there is one.
+const diffFraction = 0.3
+
+// A diffPoint is a point of a facet's difference panel: the ratio of the
+// center of the target series to the center of the base series at one X
+// value.
+type diffPoint struct {
+	x, ratio float64
+	target   point // The point of the target series
+}
+
+// findDiff returns the ratios of the target series of summary to its base
+// series, in order of X, where both have a point. base and target are labels
+// of the color aesthetic.
+func findDiff(summary []point, base, target string) []diffPoint {
+	bases := make(map[float64]float64)
+	for _, pt := range summary {
+		if pt.Get(AesColor).StringValues() == base {
+			bases[pt.Get(AesX).val] = pt.Get(AesY).val
+		}
+	}
+	var out []diffPoint
+	for _, pt := range summary {
+		if pt.Get(AesColor).StringValues() != target {
+			continue
+		}
+		x := pt.Get(AesX).val
+		if b, ok := bases[x]; ok && b != 0 {
+			out = append(out, diffPoint{x, pt.Get(AesY).val / b, pt})
+		}
+	}
+	return out
+}
+
+// checkDiff returns an error if the difference panel's base or target isn't
+// a series of l.
+func (p *Plot) checkDiff(l *layout) error {
+	for _, label := range []string{p.diffBase, p.diffTarget} {
+		if !slices.Contains(l.colorLabels, label) {
+			return fmt.Errorf("no series %q to take the difference of", label)
+		}
+	}
+	return nil
+}
+
+// A panelGrid is the placement of each facet and its difference panel on the
+// screen. Gnuplot's multiplot layouts make every plot the same size, so the
+// facets of a plot with difference panels are placed explicitly. The margins
+// and spacing are in pixels.
+type panelGrid struct {
+	width, height            float64 // Of the whole image
+	nRows, nCols             int
+	left, right, top, bottom float64
+	hSpace, vSpace           float64
+}
+
+// setPanel places the next plot in the facet at row and col of p.grid, or in
+// its difference panel if diff is set.
+func (p *gnuplotter) setPanel(row, col int, diff bool) {
+	g := p.grid
+	w := (g.width - g.left - g.right - float64(g.nCols-1)*g.hSpace) / float64(g.nCols)
+	h := (g.height - g.top - g.bottom - float64(g.nRows-1)*g.vSpace) / float64(g.nRows)
+	x0 := g.left + float64(col)*(w+g.hSpace)
+	top := g.top + float64(row)*(h+g.vSpace)
+	// The main panel sits directly on its difference panel, which labels
+	// their shared X axis.
+	split := top + h*(1-diffFraction)
+	y0, y1 := split, top
+	if diff {
+		y0, y1 = top+h, split
+	}
+	p.set("lmargin", fmt.Sprintf("set lmargin at screen %.4g", x0/g.width))
+	p.set("rmargin", fmt.Sprintf("set rmargin at screen %.4g", (x0+w)/g.width))
+	p.set("tmargin", fmt.Sprintf("set tmargin at screen %.4g", 1-y1/g.height))
+	p.set("bmargin", fmt.Sprintf("set bmargin at screen %.4g", 1-y0/g.height))
+}
+
+// xRange fixes the range of the X axis of f, whose X coordinates are given by
+// xScale, so a facet and its difference panel line up. Dots side by side need
+// room beside the first and last X values.
+func (p *gnuplotter) xRange(f facet, xScale func(float64) float64, dodging bool) {
+	xLo, xHi, _, _ := extent(f.pts, xScale, func(y float64) float64 { return y })
+	pad := 0.0
+	if dodging {
+		pad = 0.5
+	}
+	p.set("xrange", fmt.Sprintf("set xrange [%g:%g]", xLo-pad, xHi+pad))
+}
+
+// diffPlot emits the difference panel of facet f, which shows the change from
+// the base series to the target series as a percent delta at each X value the
+// two share.
+func (p *gnuplotter) diffPlot(f facet) {
+	if len(f.diff) == 0 {
+		// There's nothing to draw, and gnuplot can't autoscale
+		// nothing.
+		return
+	}
+	p.clearRefMarks()
+	p.clearSeriesLabels(0)
+	p.unset("nonlinear y", "unset nonlinear y")
+	p.unset("arrow 1", "unset arrow 1")
+	p.unset("ytics", "set ytics autofreq")
+	p.set("format y", "set format y '%+h%%'")
+	p.set("yrange", "set yrange [*<0:0<*]")
+	p.set("xzeroaxis", "set xzeroaxis dt 2")
+
+	xScale, xLabel, _ := p.axisScale(f.pts, AesX)
+	p.set("format x", "set format x '%.0s%c'")
+	if f.xOrdinal != nil {
+		xScale = ordinalScale(f.xOrdinal)
+		p.ordinalTics(f, xScale, true)
+	}
+	p.xRange(f, xScale, p.geom == GeomDotInterval && f.xOrdinal != nil)
+	p.set("xlabel", fmt.Sprintf("set xlabel %s%s", gpString(xLabel), p.textColor()))
+	p.set("ylabel", fmt.Sprintf("set ylabel %s%s", gpString("delta vs "+p.diffBase), p.textColor()))
+
+	// The deltas may be negative, so they can't be on a log scale.
+	logY := p.logScale.Get(AesY)
+	if logY != 0 {
+		fmt.Fprintf(p.code, "unset logscale y\n")
+	}
+	target := f.diff[0].target
+	fmt.Fprintf(p.code, "plot '-' using 1:2 with %s title '' %s\n", p.seriesWith(), p.lineStyle(target.Get(AesColor), p.colorScale(target)+1))
+	for _, d := range f.diff {
+		x, y := xScale(d.x), (d.ratio-1)*100
+		if !math.IsInf(x, 0) && !math.IsNaN(x) {
+			fmt.Fprintf(p.code, "%g %g\n", x, y)
+		}
+	}
+	fmt.Fprintf(p.code, "e\n")
+	if logY != 0 {
+		fmt.Fprintf(p.code, "set logscale y %d\n", logY)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package plot plots Go benchmark results.
+//
+// A [Config] maps fields of [benchfmt.Result] records, selected by
+// [benchproc.Projection]s, onto the aesthetics of a plot: the X and Y axes,
+// the color of each series, and the rows and columns of a grid of facets. One
+// aesthetic shows the measured values, and one shows their units. For
+// example, to plot each benchmark's measurements against its "size" name key,
+// with one row per unit and one series per input file:
+//
+//	var parser benchproc.ProjectionParser
+//	x, _ := parser.Parse("/size", nil)
+//	color, _ := parser.Parse(".file", nil)
+//	unit, _, _ := parser.ParseWithUnit("", nil)
+//
+//	c := plot.NewConfig()
+//	c.SetIV(plot.AesX, x)
+//	c.SetDV(plot.AesY)
+//	c.SetIV(plot.AesColor, color)
+//	c.SetIV(plot.AesRow, unit)
+//
+// [New] parses the projections and creates the Plot in one call, with the
+// same defaults as the benchplot command:
+//
+//	pl, err := plot.New(plot.X("/size"), plot.Color(".file"))
+//
+// A [Plot] collects the measurements of each result added to it, grouped by
+// their aesthetic values. Once all of the results have been added and the
+// unit metadata set, transforms such as [Plot.TransformCompare] may rewrite
+// the points, and [Plot.Render] renders the plot:
+//
+//	pl, err := plot.NewPlot(c)
+//	...
+//	for reader.Scan() {
+//		if res, ok := reader.Result().(*benchfmt.Result); ok {
+//			pl.Add(res)
+//		}
+//	}
+//	pl.SetUnits(reader.Units())
+//	err = pl.Render(plot.RenderOptions{Format: plot.FormatPNG}, f)
+//
+// [Plot.RenderBytes] and [Plot.RenderImage] return the rendered plot in
+// memory instead. Rendering requires gnuplot, found as described by
+// [FindGnuplot]. The Context variants of these methods, such as
+// [Plot.RenderContext], kill gnuplot if their context is canceled or times
+// out. In Go notebooks, [Plot.Display] shows the plot inline.
+//
+// Other renderers can draw the plot from its [Layout], which gives the
+// points of each facet and series as they would be drawn, by implementing
+// [Renderer]. Each facet's [Axis] gives the domain of its values and chooses
+// and formats tick marks. [Plot.RenderAreas] reports where gnuplot drew each
+// facet, for finding the marks at positions in the image.
+//
+// Transforms are also available by name from [ParseTransform], which is how
+// the benchplot command's -transform flag finds them. Other packages may
+// register their own with [RegisterTransform], using [Plot.Points] and
+// [Plot.SetPoints] to rewrite the points.
+//
+// Values computed some other way can be added with [Plot.AddPoint], which
+// takes the value of each aesthetic directly.
+package plot
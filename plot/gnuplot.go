@@ -0,0 +1,987 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/perf/benchmath"
+)
+
+type gnuplotter struct {
+	*Plot
+	opts RenderOptions
+	// code is where the script is written. It's buffered, so write
+	// errors are only reported by Flush.
+	code *bufio.Writer
+
+	confidence float64
+	colorScale func(point) int
+
+	// areas indicates that the script should print the area of each
+	// facet.
+	areas bool
+
+	// sharedKey indicates that one key labels the series of all facets,
+	// rather than each facet having its own.
+	sharedKey bool
+	// anyRange is set once a facet has drawn a confidence interval.
+	anyRange bool
+	// anyAnomaly is set once a facet has marked an anomaly.
+	anyAnomaly bool
+	// nSeriesLabels is the number of direct labels of series set by the
+	// last facet.
+	nSeriesLabels int
+	// grid is the placement of the facets and their difference panels,
+	// or nil if they're placed by a multiplot layout.
+	grid *panelGrid
+
+	// settings is the command that last set each gnuplot setting, by
+	// name, so facets only emit the settings that differ from the
+	// facet before.
+	settings map[string]string
+}
+
+// Render renders p as described by opts and writes the result to out. If
+// opts.Format is [FormatScript], it writes the gnuplot script without running
+// gnuplot. Otherwise, it runs gnuplot and writes the image. If gnuplot isn't
+// installed, it returns an [UnavailableError], as described by [FindGnuplot].
+func (p *Plot) Render(opts RenderOptions, out io.Writer) error {
+	return p.RenderContext(context.Background(), opts, out)
+}
+
+// RenderContext is like [Plot.Render], but kills gnuplot and returns ctx's
+// error if ctx is done before gnuplot finishes.
+func (p *Plot) RenderContext(ctx context.Context, opts RenderOptions, out io.Writer) error {
+	return p.render(ctx, opts, false, out, os.Stderr)
+}
+
+// render renders p like RenderContext, writing gnuplot's error output to
+// stderr. If areas is set, gnuplot also reports the area of each facet to
+// stderr, as described by parseAreas.
+func (p *Plot) render(ctx context.Context, opts RenderOptions, areas bool, out, stderr io.Writer) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	// Report any problem with the data before starting gnuplot.
+	if _, err := p.getLayout(); err != nil {
+		return err
+	}
+
+	// The script is written as it's generated, rather than built in
+	// memory, since the data of a large plot can be much larger than
+	// the image.
+	var script io.Writer
+	var cmd *exec.Cmd
+	var stdin io.WriteCloser
+	switch opts.Format {
+	case FormatScript:
+		script = out
+	default:
+		bin, err := FindGnuplot(p.gnuplot)
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cmd = exec.CommandContext(ctx, bin)
+		stdin, err = cmd.StdinPipe()
+		if err != nil {
+			return fmt.Errorf("creating pipe to gnuplot: %w", err)
+		}
+		cmd.Stdout = out
+		cmd.Stderr = stderr
+		// If gnuplot is killed, don't wait for anything it may
+		// have started to close its output.
+		cmd.WaitDelay = time.Second
+		if err := cmd.Start(); err != nil {
+			return startError(p.gnuplot, err)
+		}
+		defer cmd.Wait()
+		defer cmd.Process.Kill()
+		script = stdin
+	}
+	if p.keepScript != "" {
+		f, err := os.Create(p.keepScript)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		script = io.MultiWriter(script, f)
+	}
+
+	pl := gnuplotter{Plot: p, opts: opts, code: bufio.NewWriter(script), areas: areas}
+	if err := pl.plot(); err != nil {
+		return err
+	}
+	// TODO: Only do this if we're launching a windowed gnuplot
+	if false {
+		fmt.Fprintf(pl.code, "pause mouse close\n")
+	}
+	if err := pl.code.Flush(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if cmd == nil {
+			return err
+		}
+		return fmt.Errorf("writing to gnuplot: %w", err)
+	}
+
+	if cmd != nil {
+		stdin.Close()
+		if err := cmd.Wait(); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("gnuplot failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// plot writes the gnuplot script for p.opts to p.code. For [FormatScript], the
+// script uses gnuplot's default terminal.
+func (p *gnuplotter) plot() error {
+	l, err := p.getLayout()
+	if err != nil {
+		return err
+	}
+	p.confidence = renderConfidence
+	nRows, nCols := l.nRows, l.nCols
+	// Each facet and its difference panel are two plots.
+	multiplot := nRows > 1 || nCols > 1 || p.diffBase != ""
+	p.colorScale = l.colorScale
+
+	width, height, fontScale := p.opts.facetSize()
+	if p.diffBase != "" {
+		// Make room for the difference panels, mostly without
+		// shrinking the facets.
+		height += height / 3
+	}
+	var termOpts string
+	if fontScale != 1 {
+		termOpts += fmt.Sprintf(" fontscale %g", fontScale)
+	}
+	if p.opts.Theme == ThemeDark {
+		termOpts += ` background rgb "black"`
+	}
+	switch p.opts.Format {
+	case FormatScript:
+		// Just code, or use gnuplot's default interactive terminal.
+	case FormatPNG:
+		fmt.Fprintf(p.code, "set terminal pngcairo size %d,%d%s\n", nCols*width, nRows*height, termOpts)
+	case FormatSVG:
+		fmt.Fprintf(p.code, "set terminal svg size %d,%d%s\n", nCols*width, nRows*height, termOpts)
+	}
+	if p.opts.Theme == ThemeDark {
+		fmt.Fprintf(p.code, "set border linecolor rgb \"white\"\nset tics textcolor rgb \"white\"\nset key textcolor rgb \"white\"\n")
+	}
+
+	// Leave room under the facets for the caption and watermark.
+	bottom := 4
+	if p.caption != "" || p.watermark != "" {
+		bottom++
+		if !multiplot {
+			fmt.Fprintf(p.code, "set bmargin char %d\n", bottom)
+		}
+	}
+
+	// Direct labels replace the key, and need room to the right of each
+	// facet.
+	labelWidth := 0
+	if p.directLabels {
+		for _, label := range l.colorLabels {
+			labelWidth = max(labelWidth, utf8.RuneCountInString(label)+2)
+		}
+		fmt.Fprintf(p.code, "unset key\n")
+		if !multiplot {
+			fmt.Fprintf(p.code, "set rmargin char %d\n", labelWidth)
+		}
+	}
+
+	if multiplot {
+		// Configure multiplot, leaving room to the right of the facets
+		// for the shared key.
+		p.sharedKey = !p.directLabels
+		rightMargin := labelWidth
+		if p.sharedKey {
+			keyWidth := utf8.RuneCountInString(p.confidenceTitle())
+			for _, label := range l.colorLabels {
+				keyWidth = max(keyWidth, utf8.RuneCountInString(label))
+			}
+			rightMargin = keyWidth + 10
+		}
+		// Nested facets also need room for the labels of their outer
+		// levels.
+		left := 12 + levelSpace*float64(depth(l.rowLevels)-1)
+		top := 2 + levelSpace*float64(depth(l.colLevels)-1)
+		if p.diffBase != "" {
+			// Place the plots in pixels, taking characters to be
+			// about half as wide as they are tall.
+			charHeight := labelHeight * fontScale
+			charWidth := charHeight / 2
+			p.grid = &panelGrid{
+				width: float64(nCols * width), height: float64(nRows * height),
+				nRows: nRows, nCols: nCols,
+				left: left * charWidth, right: float64(rightMargin) * charWidth,
+				top: top * charHeight, bottom: float64(bottom) * charHeight,
+				hSpace: float64(10+labelWidth) * charWidth, vSpace: 4 * charHeight,
+			}
+			fmt.Fprintf(p.code, "set multiplot\n")
+		} else {
+			fmt.Fprintf(p.code, "set multiplot layout %d,%d columnsfirst margins char %g,char %d,char %d,char %g spacing char %d, char 4\n", nRows, nCols,
+				left, rightMargin, bottom, top, 10+labelWidth)
+		}
+	}
+
+	// Set log scales
+	setLogScale := func(aes Aes, name string) {
+		if aes == AesX && p.xLabels != nil {
+			// Ranks are linear.
+			return
+		}
+		if base := p.logScale.Get(aes); base != 0 {
+			fmt.Fprintf(p.code, "set logscale %s %d\n", name, base)
+		}
+	}
+	setLogScale(AesX, "x")
+	setLogScale(AesY, "y")
+
+	// The caption and watermark are drawn with the first facet. They're
+	// at a fixed place on the screen, so drawing them with every facet
+	// would only overdraw them.
+	if p.caption != "" {
+		p.set("label 3", fmt.Sprintf("set label 3 %s at screen 0, screen 0 left offset char 1, char 1 font \",8\"%s", gpString(p.caption), p.textColor()))
+	}
+	if p.watermark != "" {
+		p.set("label 4", fmt.Sprintf("set label 4 %s at screen 1, screen 0 right offset char -1, char 1 font \",8\" textcolor rgb \"gray50\"", gpString(p.watermark)))
+	}
+
+	// Emit plots
+	for col := range nCols {
+		for row := range nRows {
+			f, ok := l.facets[rowCol{row, col}]
+			pts := f.pts
+			if l.wrapped {
+				if !ok {
+					// The rest of the grid is empty.
+					break
+				}
+				// Label each facet with both of its values.
+				var labels []string
+				for _, label := range []string{f.colLabel, f.rowLabel} {
+					if label != "" {
+						labels = append(labels, label)
+					}
+				}
+				p.set("title", fmt.Sprintf("set title %s%s", gpString(strings.Join(labels, ", ")), p.textColor()))
+			}
+			if multiplot && !l.wrapped && col == 0 {
+				// Label this row with its innermost level, and the
+				// groups of rows it's in the middle of with their
+				// outer levels.
+				label := l.rowLabels[row]
+				if l.rowLevels != nil {
+					label = l.rowLevels[row][len(l.rowLevels[row])-1]
+				}
+				rowDepth := depth(l.rowLevels)
+				p.set("label 1", fmt.Sprintf("set label 1 %s at char %g, graph 0.5 center rotate by 90%s", gpString(label), 2+levelSpace*float64(rowDepth-1), p.textColor()))
+				for _, h := range groupHeaders(l.rowLevels, row) {
+					tag := rowHeaderTag + h.level
+					// If the header is between rows, it's half the
+					// spacing below this one.
+					at := "graph 0.5"
+					if h.between {
+						at = "graph 0 offset 0, char -2"
+					}
+					p.set(fmt.Sprintf("label %d", tag), fmt.Sprintf("set label %d %s at char %g, %s center rotate by 90 boxed%s", tag, gpString(h.label), 2+levelSpace*float64(h.level), at, p.textColor()))
+				}
+			}
+			if multiplot && !l.wrapped && row == 0 {
+				// Label this column likewise.
+				label := l.colLabels[col]
+				if l.colLevels != nil {
+					label = l.colLevels[col][len(l.colLevels[col])-1]
+				}
+				colDepth := depth(l.colLevels)
+				p.set("title", fmt.Sprintf("set title %s%s", gpString(label), p.textColor()))
+				for _, h := range groupHeaders(l.colLevels, col) {
+					tag := colHeaderTag + h.level
+					// Headers go above the title, outermost first.
+					// If the header is between columns, it's half
+					// the spacing right of this one.
+					at := fmt.Sprintf("graph 0.5, graph 1 center offset 0, char %g", 2.5+levelSpace*float64(colDepth-2-h.level))
+					if h.between {
+						at = fmt.Sprintf("graph 1, graph 1 center offset char 5, char %g", 2.5+levelSpace*float64(colDepth-2-h.level))
+					}
+					p.set(fmt.Sprintf("label %d", tag), fmt.Sprintf("set label %d %s at %s boxed%s", tag, gpString(h.label), at, p.textColor()))
+				}
+			}
+			if p.grid != nil {
+				p.setPanel(row, col, false)
+			}
+			p.onePlot(f)
+			if p.areas && len(pts) > 0 {
+				fmt.Fprintf(p.code, "print sprintf(\"%s %d %d %%d %%d %%d %%d %%d %%d %%.10g %%.10g %%.10g %%.10g\", GPVAL_TERM_XMIN, GPVAL_TERM_XMAX, GPVAL_TERM_YMIN, GPVAL_TERM_YMAX, GPVAL_TERM_XSIZE, GPVAL_TERM_YSIZE, GPVAL_X_MIN, GPVAL_X_MAX, GPVAL_Y_MIN, GPVAL_Y_MAX)\n", areaPrefix, row, col)
+			}
+			p.unset("label 1", "unset label 1")
+			p.unset("label 3", "unset label 3")
+			p.unset("label 4", "unset label 4")
+			p.unset("title", "unset title")
+			for level := range depth(l.rowLevels) - 1 {
+				p.unset(fmt.Sprintf("label %d", rowHeaderTag+level), fmt.Sprintf("unset label %d", rowHeaderTag+level))
+			}
+			for level := range depth(l.colLevels) - 1 {
+				p.unset(fmt.Sprintf("label %d", colHeaderTag+level), fmt.Sprintf("unset label %d", colHeaderTag+level))
+			}
+			if p.grid != nil && len(pts) > 0 {
+				p.setPanel(row, col, true)
+				p.diffPlot(f)
+			}
+		}
+	}
+
+	if multiplot {
+		if p.sharedKey {
+			p.keyPlot(l)
+		}
+		fmt.Fprintf(p.code, "unset multiplot\n")
+	}
+
+	return nil
+}
+
+func pointAesGetter(aes Aes) func(pt point) value {
+	return func(pt point) value {
+		return pt.Get(aes)
+	}
+}
+
+func (p *gnuplotter) onePlot(f facet) {
+	pts := f.pts
+	if len(pts) == 0 {
+		p.emptyPlot()
+		return
+	}
+	p.unset("border", "set border")
+	if f.xOrdinal == nil {
+		p.unset("xtics", "set xtics")
+	}
+	// Dots on an ordinal axis are drawn side by side at each X value.
+	dodging := p.geom == GeomDotInterval && f.xOrdinal != nil
+	// The X axis is shared with the difference panel beneath, which labels
+	// it.
+	fixX := dodging || p.diffBase != ""
+
+	// Let gnuplot print scientific values on tick marks. This is much nicer
+	// than putting it on the unit.
+	setFormat := func(axis string, aes Aes) (scale func(float64) float64, label string, kind axisKind) {
+		scale, label, kind = p.axisScale(pts, aes)
+		// The zero axis command uses the opposite axis.
+		za := "x"
+		if aes == AesX {
+			za = "y"
+		}
+		// A broken axis, one with direct labels, or one shared with a
+		// difference panel or with dots side by side sets its own range.
+		autoRange := (aes == AesX && !fixX) || (aes == AesY && f.yBreak == nil && !p.directLabels)
+		switch kind {
+		case axisRatio:
+			// Format ratios as a percent delta.
+			p.set("format "+axis, fmt.Sprintf("set format %s '%%+h%%%%'", axis))
+
+			// Always include 0.
+			if autoRange {
+				p.set(axis+"range", fmt.Sprintf("set %srange [*<0:0<*]", axis))
+			}
+
+			// Draw a line at 0.
+			p.set(za+"zeroaxis", fmt.Sprintf("set %szeroaxis dt 2", za))
+			return
+		case axisPercent:
+			// Format coefficients of variation as a percent.
+			p.set("format "+axis, fmt.Sprintf("set format %s '%%h%%%%'", axis))
+		default:
+			// TODO: If the unit class is Binary, use %b%B.
+			p.set("format "+axis, fmt.Sprintf("set format %s '%%.0s%%c'", axis))
+		}
+		if autoRange {
+			p.unset(axis+"range", fmt.Sprintf("set %srange [*:*]", axis))
+		}
+		p.unset(za+"zeroaxis", fmt.Sprintf("unset %szeroaxis", za))
+		return
+	}
+	xScale, xLabel, _ := setFormat("x", AesX)
+	yScale, yLabel, yKind := setFormat("y", AesY)
+	if p.diffBase != "" {
+		p.set("format x", `set format x ""`)
+		xLabel = ""
+	}
+	if f.xOrdinal != nil {
+		xScale = ordinalScale(f.xOrdinal)
+		p.ordinalTics(f, xScale, p.diffBase == "")
+	}
+	if fixX {
+		p.xRange(f, xScale, dodging)
+	}
+
+	// Set axis labels
+	p.set("xlabel", fmt.Sprintf("set xlabel %s%s", gpString(xLabel), p.textColor()))
+	p.set("ylabel", fmt.Sprintf("set ylabel %s%s", gpString(yLabel), p.textColor()))
+
+	// Break the Y axis, by mapping it to an axis that skips the break.
+	// The range is fixed so the break is at the same height in every
+	// facet of the row.
+	if b := f.yBreak; b != nil {
+		b = b.scaled(yScale)
+		lo, hi := b.bounds()
+		p.set("yrange", fmt.Sprintf("set yrange [%g:%g]", lo, hi))
+		p.set("nonlinear y", fmt.Sprintf("set nonlinear y via (y <= %g ? y : y < %g ? NaN : %g + (y - %g) * %g) inverse (y <= %g ? y : %g + (y - %g) / %g)",
+			b.lo, b.hi, b.lo, b.hi, b.scale, b.lo, b.hi, b.lo, b.scale))
+		var ticks []string
+		for _, tick := range b.ticks() {
+			// Round off error in the tick positions.
+			ticks = append(ticks, fmt.Sprintf("%.12g", tick))
+		}
+		p.set("ytics", fmt.Sprintf("set ytics (%s)", strings.Join(ticks, ", ")))
+		p.set("arrow 1", fmt.Sprintf("set arrow 1 from graph 0, first %g to graph 1, first %g nohead dt 3", b.lo, b.lo))
+	} else {
+		p.unset("nonlinear y", "unset nonlinear y")
+		p.unset("ytics", "set ytics autofreq")
+		p.unset("arrow 1", "unset arrow 1")
+	}
+
+	// With a shared key, the series of each facet are labeled by keyPlot.
+	title := func(s string) string {
+		if p.sharedKey {
+			return "''"
+		}
+		return gpString(s)
+	}
+
+	pts = f.summary
+	p.refMarks(f, pts, xScale, yScale)
+	if p.directLabels {
+		p.labelSeries(f, pts, xScale, yScale, yKind == axisRatio)
+	}
+	if p.highlight != nil {
+		// Draw the dimmed series under the highlighted ones.
+		pts = slices.Clone(pts)
+		slices.SortStableFunc(pts, func(a, b point) int {
+			da, db := p.dimmed(a.Get(AesColor)), p.dimmed(b.Get(AesColor))
+			switch {
+			case da == db:
+				return 0
+			case da:
+				return -1
+			}
+			return 1
+		})
+	}
+
+	// xOf returns the X coordinate of pt. When dodging, each series is
+	// moved beside the others at the same X value.
+	xOf := func(pt point) float64 {
+		return xScale(pt.Get(AesX).val)
+	}
+	if dodging {
+		var colors []value
+		sliceBy(f.summary, pointAesGetter(AesColor), func(color value, _ []point) {
+			colors = append(colors, color)
+		})
+		step := min(0.2, 0.8/float64(len(colors)))
+		offsets := make(map[value]float64, len(colors))
+		for i, color := range colors {
+			offsets[color] = (float64(i) - float64(len(colors)-1)/2) * step
+		}
+		xOf = func(pt point) float64 {
+			return xScale(pt.Get(AesX).val) + offsets[pt.Get(AesColor)]
+		}
+	}
+
+	// Drop points that wouldn't be visible in the image. An interactive
+	// terminal can zoom in, so the script format keeps every point.
+	// The grid assumes linear axes, so a broken axis keeps every point.
+	var grid *pixelGrid
+	if p.opts.Format != FormatScript && f.yBreak == nil {
+		width, height, _ := p.opts.facetSize()
+		grid = newPixelGrid(pts, xScale, yScale, width, height, p.logScale.Get(AesX) != 0, p.logScale.Get(AesY) != 0)
+	}
+
+	// Set up for plotting ratios. Lines shade the area between them and
+	// 0, which means nothing between the dots of separate X values.
+	kinds := pointsKinds(pts, AesY)
+	var ratioPos, ratioNeg string
+	if p.dvAes == AesY && kinds&kindRatio != 0 && p.geom == GeomLine {
+		// Check that all units have the same "better" direction.
+		better := 0
+		for i, unitName := range p.pointsUnits(pts) {
+			better1 := p.units.GetBetter(unitName)
+			if i == 0 {
+				better = better1
+			} else if better != better1 {
+				better = 0
+				break
+			}
+		}
+
+		// Shade only the direction of change we're interested in.
+		improve, regress := "green", "red"
+		switch p.direction {
+		case DirectionRegressions:
+			improve = ""
+		case DirectionImprovements:
+			regress = ""
+		}
+
+		if better > 0 {
+			ratioPos, ratioNeg = regress, improve
+		} else if better < 0 {
+			ratioPos, ratioNeg = improve, regress
+		}
+	}
+
+	// Build plot command and the functions that emit the point data that
+	// follows it. We build this up in several layers.
+	const (
+		layerPos = iota
+		layerNeg
+		layerRange
+		layerCenter
+		maxLayers
+	)
+	var plotArgs []string
+	var data []func()
+	anyRange := false
+	for layer := range maxLayers {
+		sliceBy(pts, pointAesGetter(AesColor),
+			func(color value, pts []point) {
+				colorIdx := p.colorScale(pts[0]) + 1
+
+				if layer == layerRange {
+					haveRange := false
+					for _, pt := range pts {
+						if hasRange(pt.Get(AesY).summary) {
+							haveRange, anyRange, p.anyRange = true, true, true
+							break
+						}
+					}
+					if !haveRange {
+						return
+					}
+
+					// Emit range
+					var plotArg string
+					if p.geom == GeomDotInterval {
+						plotArg = fmt.Sprintf("'-' using 1:2:2:3 with yerrorbars title '' %s pointsize 0", p.lineStyle(color, colorIdx))
+					} else {
+						fill := fmt.Sprintf("linetype %d", colorIdx)
+						if p.dimmed(color) {
+							fill = dimColor
+						}
+						plotArg = fmt.Sprintf("'-' using 1:2:3 with filledcurves title '' fc %s fs transparent solid 0.25", fill)
+					}
+					plotArgs = append(plotArgs, plotArg)
+
+					data = append(data, func() {
+						keep := grid.thinner()
+						for _, pt := range pts {
+							y := pt.Get(AesY).summary
+							if !hasRange(y) {
+								continue
+							}
+							x, lo, hi := xOf(pt), yScale(y.Lo), yScale(y.Hi)
+							if keep(x, lo, hi) {
+								fmt.Fprintf(p.code, "%g %g %g\n", x, lo, hi)
+							}
+						}
+						fmt.Fprintf(p.code, "e\n")
+					})
+					return
+				}
+
+				// The other layers all use the same data and just different
+				// plot options.
+				plotArg := "'-' using 1:2"
+				switch layer {
+				case layerPos:
+					if ratioPos == "" {
+						return
+					}
+					plotArg += " with filledcurves below title '' fs transparent solid 0.1 fc '" + ratioPos + "' lw 0"
+				case layerNeg:
+					if ratioNeg == "" {
+						return
+					}
+					plotArg += " with filledcurves above title '' fs transparent solid 0.1 fc '" + ratioNeg + "' lw 0"
+				case layerCenter:
+					plotArg += fmt.Sprintf(" with %s title %s %s", p.seriesWith(), title(color.StringValues()), p.lineStyle(color, colorIdx))
+				}
+
+				// Emit center curve.
+				plotArgs = append(plotArgs, plotArg)
+				data = append(data, func() {
+					keep := grid.thinner()
+					for _, pt := range pts {
+						x, y := xOf(pt), yScale(pt.Get(AesY).val)
+						if keep(x, y, y) {
+							fmt.Fprintf(p.code, "%g %g\n", x, y)
+						}
+					}
+					fmt.Fprintf(p.code, "e\n")
+				})
+			})
+	}
+
+	if anyRange && !p.sharedKey && !p.directLabels {
+		// Add a legend entry for the range.
+		plotArgs = append(plotArgs, p.confidenceKey())
+	}
+
+	if len(f.anomalies) > 0 {
+		// Mark anomalies over every series. These are never thinned,
+		// since they're what the reader is looking for.
+		p.anyAnomaly = true
+		anomalyTitle := gpString(anomalyTitle)
+		if p.sharedKey || p.directLabels {
+			anomalyTitle = "''"
+		}
+		plotArgs = append(plotArgs, fmt.Sprintf("'-' using 1:2 with points title %s %s", anomalyTitle, anomalyStyle))
+		data = append(data, func() {
+			for _, pt := range pts {
+				if _, ok := f.anomalies[pt]; ok {
+					fmt.Fprintf(p.code, "%g %g\n", xOf(pt), yScale(pt.Get(AesY).val))
+				}
+			}
+			fmt.Fprintf(p.code, "e\n")
+		})
+	}
+
+	fmt.Fprintf(p.code, "plot %s\n", strings.Join(plotArgs, ", "))
+
+	for _, emit := range data {
+		emit()
+	}
+}
+
+// ordinalTics emits the tick marks of the ordinal X axis of f, with X
+// coordinates given by xScale. It labels as many of the X values in the facet
+// as fit across it, unless labeled is false.
+func (p *gnuplotter) ordinalTics(f facet, xScale func(float64) float64, labeled bool) {
+	xLo, xHi, _, _ := extent(f.pts, xScale, func(y float64) float64 { return y })
+	ticks := f.ordinalTicks(xLo, xHi)
+	width, _, fontScale := p.opts.facetSize()
+	labelWidth := 1
+	for _, tick := range ticks {
+		labelWidth = max(labelWidth, utf8.RuneCountInString(tick.Label)+2)
+	}
+	// Characters are about half as wide as they are tall, and the facet
+	// is the width of its image, less about ten characters for its Y tick
+	// marks and label.
+	charWidth := labelHeight * fontScale / 2
+	var tics []string
+	for _, tick := range thinTicks(ticks, int((float64(width)-10*charWidth)/(float64(labelWidth)*charWidth))) {
+		if !labeled {
+			tick.Label = ""
+		}
+		tics = append(tics, fmt.Sprintf("%s %g", gpString(tick.Label), tick.Value))
+	}
+	p.set("xtics", fmt.Sprintf("set xtics (%s)", strings.Join(tics, ", ")))
+}
+
+// set emits cmd to set the gnuplot setting name, unless cmd already set it.
+func (p *gnuplotter) set(name, cmd string) {
+	if old, ok := p.settings[name]; ok && old == cmd {
+		return
+	}
+	if p.settings == nil {
+		p.settings = make(map[string]string)
+	}
+	p.settings[name] = cmd
+	fmt.Fprintf(p.code, "%s\n", cmd)
+}
+
+// unset emits cmd to restore the gnuplot setting name to its default, if set
+// changed it.
+func (p *gnuplotter) unset(name, cmd string) {
+	if _, ok := p.settings[name]; !ok {
+		return
+	}
+	delete(p.settings, name)
+	fmt.Fprintf(p.code, "%s\n", cmd)
+}
+
+// levelSpace is the space, in characters, between the labels of each level
+// of nested facets.
+const levelSpace = 1.5
+
+// The label tags of the headers of each level of nested rows and columns.
+const (
+	rowHeaderTag = 10
+	colHeaderTag = 20
+)
+
+// depth returns the number of levels of nested facets given by levels, as
+// computed by ordLevels.
+func depth(levels [][]string) int {
+	if levels == nil {
+		return 1
+	}
+	return len(levels[0])
+}
+
+// A groupHeader labels a group of consecutive rows or columns of nested
+// facets that share their values of the outer levels up to level.
+type groupHeader struct {
+	level int
+	label string
+	// between indicates that the group has an even number of facets,
+	// so the header is centered after this facet rather than on it.
+	between bool
+}
+
+// groupHeaders returns the headers to draw with facet i of a dimension whose
+// values have the given levels. Each header is drawn with the middle facet of
+// its group.
+func groupHeaders(levels [][]string, i int) []groupHeader {
+	if levels == nil {
+		return nil
+	}
+	var headers []groupHeader
+	for level := range len(levels[i]) - 1 {
+		same := func(j int) bool {
+			return slices.Equal(levels[j][:level+1], levels[i][:level+1])
+		}
+		start, end := i, i+1
+		for start > 0 && same(start-1) {
+			start--
+		}
+		for end < len(levels) && same(end) {
+			end++
+		}
+		if i == start+(end-start-1)/2 {
+			headers = append(headers, groupHeader{level, levels[i][level], (end-start)%2 == 0})
+		}
+	}
+	return headers
+}
+
+// keyPlot emits a plot whose only mark is a key of every series in l, to the
+// right of the facets. This takes the place of the keys of the facets, so
+// each series is labeled once however many facets it appears in.
+func (p *gnuplotter) keyPlot(l *layout) {
+	p.clearRefMarks()
+	p.clearSeriesLabels(0)
+	p.unset("nonlinear y", "unset nonlinear y")
+	p.unset("arrow 1", "unset arrow 1")
+	p.set("border", "unset border")
+	p.set("xtics", "unset xtics")
+	p.set("ytics", "unset ytics")
+	p.unset("xzeroaxis", "unset xzeroaxis")
+	p.unset("yzeroaxis", "unset yzeroaxis")
+	p.set("xlabel", `set xlabel ""`)
+	p.set("ylabel", `set ylabel ""`)
+	fmt.Fprintf(p.code, "set key at screen 1, screen 0.5 right center\n")
+	var plotArgs []string
+	for i, label := range l.colorLabels {
+		plotArgs = append(plotArgs, fmt.Sprintf("1/0 with %s title %s %s", p.seriesWith(), gpString(label), p.lineStyle(l.colorValues[i], i+1)))
+	}
+	if p.anyRange {
+		plotArgs = append(plotArgs, p.confidenceKey())
+	}
+	if p.anyAnomaly {
+		plotArgs = append(plotArgs, fmt.Sprintf("1/0 with points title %s %s", gpString(anomalyTitle), anomalyStyle))
+	}
+	fmt.Fprintf(p.code, "plot [1:2] [1:2] %s\n", strings.Join(plotArgs, ", "))
+}
+
+// seriesWith returns the gnuplot style that draws the points of a series.
+func (p *gnuplotter) seriesWith() string {
+	if p.geom == GeomDotInterval {
+		return "points pointtype 7"
+	}
+	return "lp"
+}
+
+// dimColor is the color of the series that aren't highlighted.
+const dimColor = `rgb "gray75"`
+
+// lineStyle returns the gnuplot style of the line of the series of color,
+// which has linetype colorIdx. Highlighted series are thicker, and the others
+// are dimmed.
+func (p *gnuplotter) lineStyle(color value, colorIdx int) string {
+	switch {
+	case p.highlight == nil:
+		return fmt.Sprintf("linetype %d", colorIdx)
+	case p.dimmed(color):
+		return fmt.Sprintf("linetype %d linecolor %s", colorIdx, dimColor)
+	}
+	return fmt.Sprintf("linetype %d linewidth 3", colorIdx)
+}
+
+// anomalyTitle is the key label of anomalies, and anomalyStyle is the gnuplot
+// style of their marks.
+const (
+	anomalyTitle = "anomaly"
+	anomalyStyle = `pointtype 6 pointsize 2 linewidth 2 linecolor rgb "red"`
+)
+
+// confidenceTitle returns the key label of confidence intervals.
+func (p *gnuplotter) confidenceTitle() string {
+	return fmt.Sprintf("%v%% confidence", p.confidence*100)
+}
+
+// confidenceKey returns the plot element that adds confidence intervals to the
+// key.
+func (p *gnuplotter) confidenceKey() string {
+	if p.geom == GeomDotInterval {
+		// An error bar can't be drawn from a function, so this
+		// draws one from undefined data.
+		return fmt.Sprintf("'+' using (NaN):(NaN):(NaN) with yerrorbars title %s linetype 0 pointsize 0", gpString(p.confidenceTitle()))
+	}
+	return fmt.Sprintf("1/0 with filledcurves title %s fc linetype 0 fs transparent solid 0.25", gpString(p.confidenceTitle()))
+}
+
+// emptyPlot emits a facet with no points, as a blank panel that says so. Its
+// row and column labels are still drawn.
+func (p *gnuplotter) emptyPlot() {
+	p.clearRefMarks()
+	p.clearSeriesLabels(0)
+	p.unset("nonlinear y", "unset nonlinear y")
+	p.unset("arrow 1", "unset arrow 1")
+	p.set("border", "unset border")
+	p.set("xtics", "unset xtics")
+	p.set("ytics", "unset ytics")
+	p.unset("xzeroaxis", "unset xzeroaxis")
+	p.unset("yzeroaxis", "unset yzeroaxis")
+	p.set("xlabel", `set xlabel ""`)
+	p.set("ylabel", `set ylabel ""`)
+	fmt.Fprintf(p.code, "set label 2 \"no data\" at graph 0.5, graph 0.5 center%s\n", p.textColor())
+	// The ranges are positive, in case an axis has a log scale.
+	fmt.Fprintf(p.code, "plot [1:2] [1:2] 1/0 title ''\n")
+	fmt.Fprintf(p.code, "unset label 2\n")
+}
+
+// extent returns the range of the coordinates of pts, including their
+// confidence intervals, given by xScale and yScale. If pts is empty, the
+// ranges are empty, with lo > hi.
+func extent(pts []point, xScale, yScale func(float64) float64) (xLo, xHi, yLo, yHi float64) {
+	xLo, xHi = math.Inf(1), math.Inf(-1)
+	yLo, yHi = math.Inf(1), math.Inf(-1)
+	for _, pt := range pts {
+		x := xScale(pt.Get(AesX).val)
+		xLo, xHi = min(xLo, x), max(xHi, x)
+		y := pt.Get(AesY)
+		ys := []float64{y.val}
+		if y.summary != nil && hasRange(y.summary) {
+			ys = append(ys, y.summary.Lo, y.summary.Hi)
+		}
+		for _, y := range ys {
+			y = yScale(y)
+			yLo, yHi = min(yLo, y), max(yHi, y)
+		}
+	}
+	return
+}
+
+// hasRange reports whether summary has a meaningful confidence interval. The
+// interval is infinite if there are too few samples, and empty for exact
+// units.
+func hasRange(summary *benchmath.Summary) bool {
+	return !math.IsInf(summary.Lo, 0) && summary.Lo != summary.Hi
+}
+
+// textColor returns the gnuplot option to color text for p's theme, or "" for
+// the default color.
+func (p *gnuplotter) textColor() string {
+	if p.opts.Theme == ThemeDark {
+		return ` textcolor rgb "white"`
+	}
+	return ""
+}
+
+// gpString returns s escaped for Gnuplot
+func gpString(s string) string {
+	// I can't find any documentation on Gnuplot's escape syntax, but as far as
+	// I can tell, it's compatible with Go's escaping rules.
+	return strconv.Quote(s)
+}
+
+// A GnuplotWindow is a gnuplot process that displays plots in an interactive
+// window. Each call to Show replaces the displayed plot.
+type GnuplotWindow struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+}
+
+// NewGnuplotWindow starts a gnuplot process for displaying plots. gnuplot is
+// the gnuplot binary to run, or "" to find it using [FindGnuplot].
+func NewGnuplotWindow(gnuplot string) (*GnuplotWindow, error) {
+	bin, err := FindGnuplot(gnuplot)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(bin)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("creating pipe to gnuplot: %w", err)
+	}
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, startError(gnuplot, err)
+	}
+	return &GnuplotWindow{cmd, stdin}, nil
+}
+
+// Show displays p in the window, replacing any previous plot.
+func (w *GnuplotWindow) Show(p *Plot) error {
+	// Report any problem with the data before replacing the plot.
+	if _, err := p.getLayout(); err != nil {
+		return err
+	}
+	// Use the script format so gnuplot uses its interactive terminal.
+	pl := gnuplotter{Plot: p, opts: RenderOptions{Format: FormatScript}, code: bufio.NewWriter(w.stdin)}
+	// Clear settings left over from the previous plot.
+	pl.code.WriteString("reset\n")
+	if err := pl.plot(); err != nil {
+		return err
+	}
+	if err := pl.code.Flush(); err != nil {
+		return fmt.Errorf("writing to gnuplot: %w", err)
+	}
+	return nil
+}
+
+// Wait waits for the user to close the window and for gnuplot to exit.
+func (w *GnuplotWindow) Wait() error {
+	fmt.Fprintf(w.stdin, "pause mouse close\n")
+	w.stdin.Close()
+	if err := w.cmd.Wait(); err != nil {
+		return fmt.Errorf("gnuplot failed: %w", err)
+	}
+	return nil
+}
+
+// Close closes the window and stops gnuplot.
+func (w *GnuplotWindow) Close() error {
+	w.stdin.Close()
+	w.cmd.Process.Kill()
+	w.cmd.Wait()
+	return nil
+}
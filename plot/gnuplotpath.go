@@ -0,0 +1,93 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// gnuplotDirs lists directories gnuplot is commonly installed in that may not
+// be on PATH, for example when running from a desktop launcher.
+var gnuplotDirs = map[string][]string{
+	"darwin":  {"/opt/homebrew/bin", "/usr/local/bin", "/opt/local/bin"},
+	"linux":   {"/usr/bin", "/usr/local/bin", "/snap/bin"},
+	"windows": {`C:\Program Files\gnuplot\bin`, `C:\Program Files (x86)\gnuplot\bin`},
+}
+
+// An UnavailableError reports that gnuplot could not be found or started. It's
+// only returned if gnuplot wasn't given explicitly, so callers can fall back
+// to writing the script when gnuplot isn't installed, but not when it's
+// misconfigured.
+type UnavailableError struct {
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// FindGnuplot returns the gnuplot binary to run. If path is not "", it
+// returns path, resolved using PATH if it has no directory. Otherwise, it uses
+// the GNUPLOT environment variable if set, and otherwise searches PATH and
+// then a few common install locations. If the search doesn't find gnuplot, it
+// returns an [UnavailableError] that explains how to install it. If path or
+// GNUPLOT doesn't name a binary, that's an ordinary error.
+func FindGnuplot(path string) (string, error) {
+	if path == "" {
+		path = os.Getenv("GNUPLOT")
+	}
+	if path != "" {
+		bin, err := exec.LookPath(path)
+		if err != nil {
+			return "", fmt.Errorf("gnuplot %s: %w", path, err)
+		}
+		return bin, nil
+	}
+
+	if bin, err := exec.LookPath("gnuplot"); err == nil {
+		return bin, nil
+	}
+	name := "gnuplot"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	for _, dir := range gnuplotDirs[runtime.GOOS] {
+		// Use LookPath to check that the file is executable.
+		if bin, err := exec.LookPath(dir + string(os.PathSeparator) + name); err == nil {
+			return bin, nil
+		}
+	}
+
+	var hint string
+	switch runtime.GOOS {
+	case "darwin":
+		hint = "brew install gnuplot"
+	case "linux":
+		hint = "apt install gnuplot or dnf install gnuplot"
+	case "freebsd":
+		hint = "pkg install gnuplot"
+	default:
+		hint = "download it from http://www.gnuplot.info/"
+	}
+	return "", &UnavailableError{fmt.Errorf("gnuplot not found in PATH; install it (%s), or set GNUPLOT to its path", hint)}
+}
+
+// startError returns the error for failing to start the gnuplot binary found
+// by FindGnuplot(path). Like FindGnuplot, it's an [UnavailableError] only if
+// gnuplot wasn't given explicitly.
+func startError(path string, err error) error {
+	err = fmt.Errorf("starting gnuplot: %w", err)
+	if path != "" || os.Getenv("GNUPLOT") != "" {
+		return err
+	}
+	return &UnavailableError{err}
+}
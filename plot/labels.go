@@ -0,0 +1,132 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// seriesLabelTag is the tag of the gnuplot label of the first series of a
+// facet with direct labels. The other series follow it.
+const seriesLabelTag = 1000
+
+// labelHeight is about the height of a line of text, in pixels, at the default
+// font size.
+const labelHeight = 16
+
+// A seriesLabel is the direct label of a series.
+type seriesLabel struct {
+	text  string
+	x     float64 // In the coordinates of the X axis
+	y     float64 // As a fraction of the height of the facet
+	color string  // As a gnuplot color specification
+}
+
+// labelSeries emits a label with the name of each series in pts at the right
+// end of its line, moving labels apart so they don't overlap. The labels are
+// placed by how high they are in the facet, so unless the Y axis is broken,
+// which fixes its range, this fixes the range of the Y axis to fit pts. If
+// ratio is set, the range includes 0, like the autoscaled range of a ratio
+// axis.
+func (p *gnuplotter) labelSeries(f facet, pts []point, xScale, yScale func(float64) float64, ratio bool) {
+	// frac maps a Y coordinate to how high it is in the facet, from 0 at
+	// the bottom to 1 at the top.
+	var frac func(y float64) float64
+	if b := f.yBreak; b != nil {
+		b = b.scaled(yScale)
+		lo, hi := b.bounds()
+		frac = func(y float64) float64 {
+			return (b.squash(y) - b.squash(lo)) / (b.squash(hi) - b.squash(lo))
+		}
+	} else {
+		_, _, lo, hi := extent(pts, xScale, yScale)
+		if ratio {
+			lo, hi = min(lo, 0), max(hi, 0)
+		}
+		if !(lo <= hi) || math.IsInf(lo, 0) || math.IsInf(hi, 0) {
+			// There's nothing to label, so let gnuplot pick the
+			// range.
+			p.unset("yrange", "set yrange [*:*]")
+			p.clearSeriesLabels(0)
+			return
+		}
+		coord, uncoord := func(y float64) float64 { return y }, func(y float64) float64 { return y }
+		if p.logScale.Get(AesY) != 0 {
+			coord, uncoord = math.Log, math.Exp
+		}
+		// Leave a margin around the points, much like gnuplot's
+		// autoscaling.
+		lo, hi = coord(lo), coord(hi)
+		pad := 0.05 * (hi - lo)
+		if pad == 0 {
+			pad = 0.05 * math.Abs(lo)
+		}
+		if pad == 0 {
+			pad = 1
+		}
+		lo, hi = lo-pad, hi+pad
+		p.set("yrange", fmt.Sprintf("set yrange [%g:%g]", uncoord(lo), uncoord(hi)))
+		frac = func(y float64) float64 {
+			return (coord(y) - lo) / (hi - lo)
+		}
+	}
+
+	var labels []seriesLabel
+	sliceBy(pts, pointAesGetter(AesColor), func(color value, pts []point) {
+		spec := fmt.Sprintf("linetype %d", p.colorScale(pts[0])+1)
+		if p.dimmed(color) {
+			spec = dimColor
+		}
+		// Label the last point that's drawn.
+		for i := len(pts) - 1; i >= 0; i-- {
+			x, y := xScale(pts[i].Get(AesX).val), frac(yScale(pts[i].Get(AesY).val))
+			if math.IsInf(x, 0) || math.IsNaN(x) || math.IsInf(y, 0) || math.IsNaN(y) {
+				continue
+			}
+			labels = append(labels, seriesLabel{color.StringValues(), x, y, spec})
+			break
+		}
+	})
+
+	// Labels closer than gap overlap. The facet is the height of its
+	// image, less about six lines for its title, tick marks, X label, and
+	// margins.
+	_, height, fontScale := p.opts.facetSize()
+	gap := labelHeight * fontScale / (float64(height) - 6*labelHeight*fontScale)
+
+	// Push each label up clear of the label below it, then push the labels
+	// that went over the top of the facet back down.
+	slices.SortStableFunc(labels, func(a, b seriesLabel) int {
+		return cmp.Compare(a.y, b.y)
+	})
+	for i := 1; i < len(labels); i++ {
+		labels[i].y = max(labels[i].y, labels[i-1].y+gap)
+	}
+	for i := len(labels) - 1; i >= 0; i-- {
+		top := 1.0
+		if i < len(labels)-1 {
+			top = labels[i+1].y - gap
+		}
+		labels[i].y = min(labels[i].y, top)
+	}
+
+	for i, label := range labels {
+		tag := seriesLabelTag + i
+		p.set(fmt.Sprintf("label %d", tag), fmt.Sprintf("set label %d %s at first %g, graph %g left offset char 1 textcolor %s", tag, gpString(label.text), label.x, label.y, label.color))
+	}
+	p.clearSeriesLabels(len(labels))
+}
+
+// clearSeriesLabels removes the direct labels of series from the nth on.
+func (p *gnuplotter) clearSeriesLabels(n int) {
+	for i := n; i < p.nSeriesLabels; i++ {
+		name := fmt.Sprintf("label %d", seriesLabelTag+i)
+		p.unset(name, "unset "+name)
+	}
+	p.nSeriesLabels = n
+}
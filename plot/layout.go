@@ -0,0 +1,229 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// renderConfidence is the confidence level of the intervals shown when
+// rendering.
+const renderConfidence = 0.95
+
+// A layout is the arrangement of a Plot's points into facets for rendering.
+// Computing it sorts, groups, and summarizes all of the points, so the Plot
+// caches it until its points change. A layout is never modified once
+// computed.
+type layout struct {
+	nRows, nCols int
+	// rowLabels and colLabels are the label of each row and column,
+	// including those with no points.
+	rowLabels, colLabels []string
+	// rowLevels and colLevels are the value of each field of each row
+	// and column, outermost first, or nil if the row or column
+	// aesthetic has only one field.
+	rowLevels, colLevels [][]string
+	// wrapped indicates that the facets with no points were dropped and
+	// the rest wrapped into a smaller grid, so rows and columns of the
+	// grid no longer correspond to rowLabels and colLabels.
+	wrapped     bool
+	colorScale  func(point) int
+	colorLabels []string
+	colorValues []value // The value of each color, in order
+	nColors     int
+	facets      map[rowCol]facet
+}
+
+type rowCol struct{ row, col int }
+
+// A facet is the points of one facet of a plot. pts are in the order they
+// must be emitted, and summary is pts with each group of values of the
+// dependent variable summarized. rowLabel and colLabel are the values of the
+// row and column aesthetics. yBreak is the break in the Y axis, which is
+// shared by the row of facets, or nil. xOrdinal is the distinct X values of
+// every facet, in order, if the X axis is ordinal, or nil, and xTickLabels is
+// the label of each of them. anomalies is the anomalous points of summary, if
+// anomalies are marked, and diff is the points of its difference panel, if it
+// has one.
+type facet struct {
+	pts, summary       []point
+	rowLabel, colLabel string
+	yBreak             *axisBreak
+	xOrdinal           []float64
+	xTickLabels        map[float64]string
+	anomalies          map[point]anomaly
+	diff               []diffPoint
+}
+
+// getLayout returns the layout of p's points, computing it if necessary.
+func (p *Plot) getLayout() (*layout, error) {
+	p.flushStreaming()
+	if p.layout != nil {
+		return p.layout, nil
+	}
+	t := &p.points
+
+	if t.len() == 0 {
+		return nil, fmt.Errorf("no data")
+	}
+
+	// Non-numeric X values are categories, which dots can be drawn at
+	// but lines can't connect.
+	categorical := t.kinds(AesX)&kindContinuous == 0
+	if categorical && p.geom != GeomDotInterval {
+		// TODO: Bar chart
+		return nil, fmt.Errorf("non-numeric X data not supported by the %s geometry", p.geom.Name())
+	}
+	if t.kinds(AesY)&kindContinuous == 0 {
+		// TODO: Horizontal bar chart?
+		return nil, fmt.Errorf("non-numeric Y data not supported")
+	}
+	l := new(layout)
+	rowVals, colVals := t.distinct(AesRow), t.distinct(AesCol)
+	rowScale, nRows := ordScale(rowVals, AesRow)
+	colScale, nCols := ordScale(colVals, AesCol)
+	l.nRows, l.nCols = nRows, nCols
+	l.rowLabels, l.colLabels = ordLabels(rowVals), ordLabels(colVals)
+	l.rowLevels, l.colLevels = ordLevels(rowVals, p.aes.Get(AesRow)), ordLevels(colVals, p.aes.Get(AesCol))
+	colorVals := t.distinct(AesColor)
+	l.colorScale, l.nColors = ordScale(colorVals, AesColor)
+	l.colorLabels = ordLabels(colorVals)
+	l.colorValues = sortedValues(colorVals)
+	if p.diffBase != "" {
+		if err := p.checkDiff(l); err != nil {
+			return nil, err
+		}
+	}
+
+	// xOf returns the X coordinate of the point at index i of t. Each
+	// category is placed at its rank.
+	xOf := func(i int) float64 { return t.value(AesX, i).val }
+	xTickLabels := p.xTickLabels
+	if categorical {
+		rank := make(map[value]float64)
+		xTickLabels = make(map[float64]string)
+		for i, v := range sortedValues(t.distinct(AesX)) {
+			rank[v] = float64(i)
+			xTickLabels[float64(i)] = v.StringValues()
+		}
+		xOf = func(i int) float64 { return rank[t.value(AesX, i)] }
+	}
+
+	// Sort the points in the order the data must be emitted. Sorting
+	// their indexes in the table compares only ranks of the facet and
+	// color values.
+	cmpCol, cmpRow, cmpColor := t.comparer(AesCol), t.comparer(AesRow), t.comparer(AesColor)
+	order := make([]int, t.len())
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		if c := cmpCol(a, b); c != 0 {
+			return c
+		}
+		if c := cmpRow(a, b); c != 0 {
+			return c
+		}
+		if c := cmpColor(a, b); c != 0 {
+			return c
+		}
+		// For a line plot, X must be sorted numerically.
+		return cmp.Compare(xOf(a), xOf(b))
+	})
+	pts := make([]point, len(order))
+	for i, idx := range order {
+		pts[i] = t.get(idx)
+		if categorical {
+			x := pts[i].Get(AesX)
+			x.kinds |= kindContinuous
+			x.val = xOf(idx)
+			pts[i].Set(AesX, x)
+		}
+	}
+
+	groups, _ := groupBy(pts, func(pt point) rowCol {
+		return rowCol{rowScale(pt), colScale(pt)}
+	})
+	l.facets = make(map[rowCol]facet, len(groups))
+	for rc, pts := range groups {
+		// TODO: Should this be done up front? Then continuousScale would
+		// have to understand summaries, but that's fine.
+		//
+		// TODO: Do something with the warnings. Allow configuring
+		// confidence.
+		summary, _ := transformSummarize(pts, AesY, renderConfidence, p.assumption)
+		f := facet{pts: pts, summary: summary, rowLabel: l.rowLabels[rc.row], colLabel: l.colLabels[rc.col]}
+		if p.anomalySigma > 0 {
+			f.anomalies = findAnomalies(summary, p.anomalySigma)
+		}
+		if p.diffBase != "" {
+			f.diff = findDiff(summary, p.diffBase, p.diffTarget)
+		}
+		l.facets[rc] = f
+	}
+
+	if p.breakY && p.logScale.Get(AesY) == 0 {
+		// Break each row at the same place, so its facets can be
+		// compared.
+		vals := make([][]float64, nRows)
+		for rc, f := range l.facets {
+			for _, pt := range f.summary {
+				y := pt.Get(AesY)
+				vals[rc.row] = append(vals[rc.row], y.val)
+				if y.summary != nil && hasRange(y.summary) {
+					vals[rc.row] = append(vals[rc.row], y.summary.Lo, y.summary.Hi)
+				}
+			}
+		}
+		for rc, f := range l.facets {
+			f.yBreak = findBreak(vals[rc.row])
+			l.facets[rc] = f
+		}
+	}
+
+	if p.xLabels != nil || categorical {
+		// Rank the X values of every facet together, so the
+		// facets' X axes line up.
+		var xs []float64
+		for i := range t.len() {
+			xs = append(xs, xOf(i))
+		}
+		slices.Sort(xs)
+		xs = slices.Compact(xs)
+		for rc, f := range l.facets {
+			f.xOrdinal, f.xTickLabels = xs, xTickLabels
+			l.facets[rc] = f
+		}
+	}
+
+	if p.dropEmpty && len(l.facets) < nRows*nCols {
+		l.wrap()
+	}
+
+	p.layout = l
+	return l, nil
+}
+
+// wrap drops the empty facets of l and wraps the rest, in column-major order,
+// into the squarest grid that holds them.
+func (l *layout) wrap() {
+	n := len(l.facets)
+	nCols := int(math.Ceil(math.Sqrt(float64(n))))
+	nRows := (n + nCols - 1) / nCols
+	facets := make(map[rowCol]facet, n)
+	i := 0
+	for col := range l.nCols {
+		for row := range l.nRows {
+			if f, ok := l.facets[rowCol{row, col}]; ok {
+				facets[rowCol{i % nRows, i / nRows}] = f
+				i++
+			}
+		}
+	}
+	l.nRows, l.nCols, l.facets, l.wrapped = nRows, nCols, facets, true
+}
@@ -0,0 +1,162 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import "fmt"
+
+// A RefLine is a line drawn across every facet of a plot at a fixed position
+// on one axis, such as to mark the commit where a feature landed.
+type RefLine struct {
+	// At is the position of the line, in the units of the values on its
+	// axis before they are scaled for display.
+	At float64
+	// Label, if not "", is drawn next to the line.
+	Label string
+}
+
+// A RefBand is a band drawn across every facet of a plot between two values
+// on one axis, such as to show a range of acceptable performance, or a range
+// of commits with a known problem.
+type RefBand struct {
+	// Lo and Hi are the bounds of the band, like RefLine.At.
+	Lo, Hi float64
+	// Label, if not "", is drawn in the band.
+	Label string
+}
+
+// An Annotation is text placed at a point in data coordinates, such as to
+// call out where a change landed.
+type Annotation struct {
+	// X and Y are the position of the annotation, like RefLine.At.
+	X, Y  float64
+	Label string
+	// Arrow indicates that the text is set off from the position, with
+	// an arrow pointing from it to the position.
+	Arrow bool
+	// Row and Col, if not "", restrict the annotation to the facets with
+	// those row and column labels. Otherwise, it's drawn in every facet
+	// whose points span its position.
+	Row, Col string
+}
+
+// Tags of the gnuplot arrows and labels that draw reference marks. Each kind
+// of mark has its own range of tags, one for each mark of that kind.
+const (
+	vlineTag  = 100
+	hlineTag  = 200
+	hbandTag  = 300
+	noteTag   = 400
+	regionTag = 500
+)
+
+// refMarks emits the reference marks of p for a facet showing pts, whose
+// X and Y coordinates are given by xScale and yScale. A line is only drawn if
+// it's within the range of the points, since gnuplot doesn't clip lines to the
+// facet.
+func (p *gnuplotter) refMarks(f facet, pts []point, xScale, yScale func(float64) float64) {
+	xLo, xHi, yLo, yHi := extent(pts, xScale, yScale)
+
+	// Bands, regions, and horizontal lines are drawn behind the data.
+	for i, band := range p.hbands {
+		tag := hbandTag + i
+		lo, hi := max(yScale(band.Lo), yLo), min(yScale(band.Hi), yHi)
+		if !(lo <= hi) {
+			p.unsetRefMark(tag)
+			continue
+		}
+		// The rectangle is clipped to the facet, so it can extend
+		// past the points to the edges of the facet.
+		p.set(fmt.Sprintf("object %d", tag), fmt.Sprintf("set object %d rect from graph 0, first %g to graph 1, first %g back fc rgb \"green\" fs transparent solid 0.15 noborder", tag, yScale(band.Lo), yScale(band.Hi)))
+		if band.Label != "" {
+			// Label the part of the band in the facet.
+			p.set(fmt.Sprintf("label %d", tag), fmt.Sprintf("set label %d %s at graph 0, first %g left offset char 1, 0%s", tag, gpString(band.Label), (lo+hi)/2, p.textColor()))
+		}
+	}
+	for i, region := range p.regions {
+		tag := regionTag + i
+		lo, hi := max(xScale(region.Lo), xLo), min(xScale(region.Hi), xHi)
+		if !(lo <= hi) {
+			p.unsetRefMark(tag)
+			continue
+		}
+		p.set(fmt.Sprintf("object %d", tag), fmt.Sprintf("set object %d rect from first %g, graph 0 to first %g, graph 1 back fc rgb \"gray50\" fs transparent solid 0.15 noborder", tag, xScale(region.Lo), xScale(region.Hi)))
+		if region.Label != "" {
+			p.set(fmt.Sprintf("label %d", tag), fmt.Sprintf("set label %d %s at first %g, graph 1 center offset 0, char -1%s", tag, gpString(region.Label), (lo+hi)/2, p.textColor()))
+		}
+	}
+	for i, line := range p.hlines {
+		tag := hlineTag + i
+		y := yScale(line.At)
+		if !(yLo <= y && y <= yHi) {
+			p.unsetRefMark(tag)
+			continue
+		}
+		p.set(fmt.Sprintf("arrow %d", tag), fmt.Sprintf("set arrow %d from graph 0, first %g to graph 1, first %g nohead back dt 2 lc rgb \"gray50\"", tag, y, y))
+		if line.Label != "" {
+			p.set(fmt.Sprintf("label %d", tag), fmt.Sprintf("set label %d %s at graph 1, first %g right offset char -1, char 0.7%s", tag, gpString(line.Label), y, p.textColor()))
+		}
+	}
+
+	for i, line := range p.vlines {
+		tag := vlineTag + i
+		x := xScale(line.At)
+		if !(xLo <= x && x <= xHi) {
+			p.unsetRefMark(tag)
+			continue
+		}
+		p.set(fmt.Sprintf("arrow %d", tag), fmt.Sprintf("set arrow %d from first %g, graph 0 to first %g, graph 1 nohead dt 2 lc rgb \"gray50\"", tag, x, x))
+		if line.Label != "" {
+			p.set(fmt.Sprintf("label %d", tag), fmt.Sprintf("set label %d %s at first %g, graph 1 right rotate by 90 offset char -1, char -0.5%s", tag, gpString(line.Label), x, p.textColor()))
+		}
+	}
+
+	for i, note := range p.notes {
+		tag := noteTag + i
+		x, y := xScale(note.X), yScale(note.Y)
+		if (note.Row != "" && note.Row != f.rowLabel) || (note.Col != "" && note.Col != f.colLabel) ||
+			!(xLo <= x && x <= xHi && yLo <= y && y <= yHi) {
+			p.unsetRefMark(tag)
+			continue
+		}
+		if !note.Arrow {
+			p.unset(fmt.Sprintf("arrow %d", tag), fmt.Sprintf("unset arrow %d", tag))
+			p.set(fmt.Sprintf("label %d", tag), fmt.Sprintf("set label %d %s at first %g, first %g center front%s", tag, gpString(note.Label), x, y, p.textColor()))
+			continue
+		}
+		// The arrow's head is at the position, and its tail is just
+		// short of the text.
+		p.set(fmt.Sprintf("arrow %d", tag), fmt.Sprintf("set arrow %d from first %g, first %g rto character 2.5, character 1.5 backhead front", tag, x, y))
+		p.set(fmt.Sprintf("label %d", tag), fmt.Sprintf("set label %d %s at first %g, first %g left offset character 3, character 2 front%s", tag, gpString(note.Label), x, y, p.textColor()))
+	}
+}
+
+// clearRefMarks removes all of the reference marks of p, for a facet that
+// doesn't draw any.
+func (p *gnuplotter) clearRefMarks() {
+	for i := range p.vlines {
+		p.unsetRefMark(vlineTag + i)
+	}
+	for i := range p.hlines {
+		p.unsetRefMark(hlineTag + i)
+	}
+	for i := range p.hbands {
+		p.unsetRefMark(hbandTag + i)
+	}
+	for i := range p.regions {
+		p.unsetRefMark(regionTag + i)
+	}
+	for i := range p.notes {
+		p.unsetRefMark(noteTag + i)
+	}
+}
+
+// unsetRefMark removes the arrow, object, and label of the reference mark with
+// tag.
+func (p *gnuplotter) unsetRefMark(tag int) {
+	for _, kind := range []string{"arrow", "object", "label"} {
+		name := fmt.Sprintf("%s %d", kind, tag)
+		p.unset(name, "unset "+name)
+	}
+}
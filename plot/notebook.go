@@ -0,0 +1,52 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"html"
+)
+
+// A Display shows a Plot in Go notebook kernels. It implements the display
+// methods that gophernotes recognizes in the result of a cell, so a cell
+// ending in pl.Display(opts) shows the plot. In gonb, pass the result of one
+// of its methods to gonbui, as in gonbui.DisplayHTML(pl.Display(opts).HTML()).
+//
+// Because notebook display can't report errors, HTML shows the error in place
+// of the plot, and PNG returns nil.
+type Display struct {
+	Plot *Plot
+	// Options controls rendering. Its Format is ignored, since each
+	// method renders its own format.
+	Options RenderOptions
+}
+
+// Display returns a Display of p rendered as described by opts.
+func (p *Plot) Display(opts RenderOptions) Display {
+	return Display{p, opts}
+}
+
+// HTML returns the plot as an HTML fragment holding an SVG image.
+func (d Display) HTML() string {
+	svg, err := d.render(FormatSVG)
+	if err != nil {
+		return "<pre>" + html.EscapeString(err.Error()) + "</pre>"
+	}
+	return "<div>" + string(svg) + "</div>"
+}
+
+// PNG returns the plot as a PNG image, or nil if it can't be rendered.
+func (d Display) PNG() []byte {
+	png, err := d.render(FormatPNG)
+	if err != nil {
+		return nil
+	}
+	return png
+}
+
+func (d Display) render(format Format) ([]byte, error) {
+	opts := d.Options
+	opts.Format = format
+	return d.Plot.RenderBytes(opts)
+}
@@ -0,0 +1,341 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"fmt"
+	"math"
+
+	"golang.org/x/perf/benchproc"
+)
+
+// An Option configures a Plot created by [New].
+type Option func(*builder) error
+
+// A builder accumulates the options passed to [New].
+type builder struct {
+	config  *Config
+	proj    aesMap[string] // Projection syntax of each aesthetic
+	ignore  string
+	xLabels string // Projection syntax of the ordinal X labels
+	filter  *benchproc.Filter
+}
+
+// New returns a new Plot configured by opts. It's a shorthand for building a
+// [Config] and calling [NewPlot] that parses the projections itself. By
+// default, New maps the same fields as the benchplot command: .fullname to X,
+// .value to Y, the residue to color, and .unit to facet rows.
+//
+// For example, to plot each unit against the "size" name key on a log scale,
+// with one series per input file:
+//
+//	pl, err := plot.New(plot.X("/size"), plot.Color(".file"), plot.LogScale(plot.AesX, 2))
+func New(opts ...Option) (*Plot, error) {
+	b := &builder{config: NewConfig()}
+	b.proj.Set(AesX, ".fullname")
+	b.proj.Set(AesY, ".value")
+	b.proj.Set(AesColor, ".residue")
+	b.proj.Set(AesRow, ".unit")
+	for _, opt := range opts {
+		if err := opt(b); err != nil {
+			return nil, err
+		}
+	}
+
+	// Parse projections. The residue must be parsed last.
+	var parser benchproc.ProjectionParser
+	var residueAes []Aes
+	for aes := range aesMax {
+		switch proj := b.proj.Get(aes); proj {
+		case ".unit":
+			iv, _, err := parser.ParseWithUnit("", b.filter)
+			if err != nil {
+				return nil, err
+			}
+			b.config.SetIV(aes, iv)
+		case ".value":
+			b.config.SetDV(aes)
+		case ".residue":
+			residueAes = append(residueAes, aes)
+		default:
+			iv, err := parser.Parse(proj, b.filter)
+			if err != nil {
+				return nil, fmt.Errorf("parsing %s projection: %w", aes.Name(), err)
+			}
+			b.config.SetIV(aes, iv)
+		}
+	}
+	if _, err := parser.Parse(b.ignore, b.filter); err != nil {
+		return nil, fmt.Errorf("parsing ignored keys: %w", err)
+	}
+	if b.xLabels != "" {
+		labels, err := parser.Parse(b.xLabels, b.filter)
+		if err != nil {
+			return nil, fmt.Errorf("parsing ordinal X labels: %w", err)
+		}
+		b.config.SetOrdinalX(labels)
+	}
+	residue := parser.Residue()
+	for _, aes := range residueAes {
+		b.config.SetIV(aes, residue)
+	}
+	if len(residueAes) == 0 {
+		b.config.SetResidue(residue)
+	}
+	return NewPlot(b.config)
+}
+
+// Map maps the fields selected by proj to aesthetic aes. proj uses the
+// benchproc projection syntax, or may be ".value" for the measured values,
+// ".unit" for their units, or ".residue" for all fields not mapped to any
+// other aesthetic. An empty proj maps nothing to aes.
+func Map(aes Aes, proj string) Option {
+	return func(b *builder) error {
+		if aes < 0 || aes >= aesMax {
+			return fmt.Errorf("unknown aesthetic %s", aes.Name())
+		}
+		b.proj.Set(aes, proj)
+		return nil
+	}
+}
+
+// X maps the fields selected by proj to the X axis, as described by [Map].
+func X(proj string) Option { return Map(AesX, proj) }
+
+// Y maps the fields selected by proj to the Y axis, as described by [Map].
+func Y(proj string) Option { return Map(AesY, proj) }
+
+// Color maps the fields selected by proj to color, as described by [Map].
+func Color(proj string) Option { return Map(AesColor, proj) }
+
+// Row maps the fields selected by proj to facet rows, as described by [Map].
+func Row(proj string) Option { return Map(AesRow, proj) }
+
+// Col maps the fields selected by proj to facet columns, as described by
+// [Map].
+func Col(proj string) Option { return Map(AesCol, proj) }
+
+// Ignore excludes the fields selected by proj from the residue, so variations
+// in them are neither plotted nor reported.
+func Ignore(proj string) Option {
+	return func(b *builder) error {
+		b.ignore = proj
+		return nil
+	}
+}
+
+// Filter sets the filter used to parse the projections, which determines the
+// order of their values. Only results that match f should be added to the
+// plot.
+func Filter(f *benchproc.Filter) Option {
+	return func(b *builder) error {
+		b.filter = f
+		return nil
+	}
+}
+
+// LogScale sets aesthetic aes to use a log scale in the given base, like
+// [Config.SetLogScale].
+func LogScale(aes Aes, base int) Option {
+	return func(b *builder) error {
+		if aes < 0 || aes >= aesMax {
+			return fmt.Errorf("unknown aesthetic %s", aes.Name())
+		}
+		if base < 0 || base == 1 {
+			return fmt.Errorf("bad log scale base %d", base)
+		}
+		b.config.SetLogScale(aes, base)
+		return nil
+	}
+}
+
+// Geometry sets how the points of each series are drawn, like
+// [Config.SetGeom].
+func Geometry(geom Geom) Option {
+	return func(b *builder) error {
+		if _, ok := GeomFromName(geom.Name()); !ok {
+			return fmt.Errorf("unknown geometry %s", geom.Name())
+		}
+		b.config.SetGeom(geom)
+		return nil
+	}
+}
+
+// OrdinalX spaces the X values evenly, labeling each with the fields selected
+// by proj, like [Config.SetOrdinalX]. Like [Ignore], this excludes those fields
+// from the residue.
+func OrdinalX(proj string) Option {
+	return func(b *builder) error {
+		b.xLabels = proj
+		return nil
+	}
+}
+
+// BreakY breaks the Y axis between clusters of values far apart, like
+// [Config.SetBreakY].
+func BreakY() Option {
+	return func(b *builder) error {
+		b.config.SetBreakY(true)
+		return nil
+	}
+}
+
+// DirectLabels labels each series at the end of its line rather than in a
+// key, like [Config.SetDirectLabels].
+func DirectLabels() Option {
+	return func(b *builder) error {
+		b.config.SetDirectLabels(true)
+		return nil
+	}
+}
+
+// Anomalies marks the points more than sigma times their series' noise from
+// the points before them, like [Config.SetAnomalies].
+func Anomalies(sigma float64) Option {
+	return func(b *builder) error {
+		if !(sigma >= 0) || math.IsInf(sigma, 0) {
+			return fmt.Errorf("bad anomaly threshold %g", sigma)
+		}
+		b.config.SetAnomalies(sigma)
+		return nil
+	}
+}
+
+// Diff draws the change from the base series to the target series beneath
+// each facet, like [Config.SetDiff].
+func Diff(base, target string) Option {
+	return func(b *builder) error {
+		if base == "" || target == "" {
+			return fmt.Errorf("missing series to take the difference of")
+		}
+		b.config.SetDiff(base, target)
+		return nil
+	}
+}
+
+// DropEmpty drops the facets that have no points, like
+// [Config.SetDropEmpty].
+func DropEmpty() Option {
+	return func(b *builder) error {
+		b.config.SetDropEmpty(true)
+		return nil
+	}
+}
+
+// VLine adds a vertical line at X value x, labeled with label if it's not "",
+// like [Config.AddVLine].
+func VLine(x float64, label string) Option {
+	return func(b *builder) error {
+		b.config.AddVLine(RefLine{At: x, Label: label})
+		return nil
+	}
+}
+
+// HLine adds a horizontal line at Y value y, labeled with label if it's not
+// "", like [Config.AddHLine].
+func HLine(y float64, label string) Option {
+	return func(b *builder) error {
+		b.config.AddHLine(RefLine{At: y, Label: label})
+		return nil
+	}
+}
+
+// HBand adds a band between Y values lo and hi, labeled with label if it's not
+// "", like [Config.AddHBand].
+func HBand(lo, hi float64, label string) Option {
+	return func(b *builder) error {
+		if !(lo <= hi) {
+			return fmt.Errorf("bad band %g to %g", lo, hi)
+		}
+		b.config.AddHBand(RefBand{Lo: lo, Hi: hi, Label: label})
+		return nil
+	}
+}
+
+// Region adds a shaded region between X values lo and hi, labeled with label
+// if it's not "", like [Config.AddRegion].
+func Region(lo, hi float64, label string) Option {
+	return func(b *builder) error {
+		if !(lo <= hi) {
+			return fmt.Errorf("bad region %g to %g", lo, hi)
+		}
+		b.config.AddRegion(RefBand{Lo: lo, Hi: hi, Label: label})
+		return nil
+	}
+}
+
+// Annotate adds text at a point in data coordinates, like
+// [Config.AddAnnotation].
+func Annotate(note Annotation) Option {
+	return func(b *builder) error {
+		b.config.AddAnnotation(note)
+		return nil
+	}
+}
+
+// Watermark draws text in the bottom right corner of the plot, like
+// [Config.SetWatermark].
+func Watermark(text string) Option {
+	return func(b *builder) error {
+		b.config.SetWatermark(text)
+		return nil
+	}
+}
+
+// Highlight sets which direction of change is highlighted in comparisons, like
+// [Config.SetDirection].
+func Highlight(dir Direction) Option {
+	return func(b *builder) error {
+		b.config.SetDirection(dir)
+		return nil
+	}
+}
+
+// HighlightSeries sets a filter selecting the series to highlight, like
+// [Config.SetHighlight].
+func HighlightSeries(f *benchproc.Filter) Option {
+	return func(b *builder) error {
+		b.config.SetHighlight(f)
+		return nil
+	}
+}
+
+// Noisiest sets the number of series kept by [Plot.TransformNoisiest], like
+// [Config.SetNoisiest].
+func Noisiest(n int) Option {
+	return func(b *builder) error {
+		if n < 0 {
+			return fmt.Errorf("bad number of noisiest series %d", n)
+		}
+		b.config.SetNoisiest(n)
+		return nil
+	}
+}
+
+// Streaming summarizes points as they are added, like [Config.SetStreaming].
+func Streaming() Option {
+	return func(b *builder) error {
+		b.config.SetStreaming(true)
+		return nil
+	}
+}
+
+// StreamingMean summarizes points by their means as they are added, like
+// [Config.SetStreaming] and [Config.SetStreamingMean].
+func StreamingMean() Option {
+	return func(b *builder) error {
+		b.config.SetStreaming(true)
+		b.config.SetStreamingMean(true)
+		return nil
+	}
+}
+
+// Gnuplot sets the gnuplot binary to render with, like [Config.SetGnuplot].
+func Gnuplot(path string) Option {
+	return func(b *builder) error {
+		b.config.SetGnuplot(path)
+		return nil
+	}
+}
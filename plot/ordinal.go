@@ -0,0 +1,57 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"math"
+	"slices"
+)
+
+// ordinalScale returns the scale of an ordinal axis, which spaces its
+// distinct values, vals, evenly in increasing order. It maps each value to its
+// rank, and values between them, such as the position of a reference mark,
+// proportionally between the ranks of their neighbors. Values outside vals
+// are NaN.
+func ordinalScale(vals []float64) func(float64) float64 {
+	return func(v float64) float64 {
+		i, found := slices.BinarySearch(vals, v)
+		switch {
+		case found:
+			return float64(i)
+		case i == 0 || i == len(vals):
+			return math.NaN()
+		}
+		lo, hi := vals[i-1], vals[i]
+		return float64(i-1) + (v-lo)/(hi-lo)
+	}
+}
+
+// ordinalTicks returns a tick at the rank of each X value of f's ordinal axis
+// that's in [lo, hi], labeled with the label of the results at that value.
+func (f facet) ordinalTicks(lo, hi float64) []Tick {
+	var ticks []Tick
+	for i, v := range f.xOrdinal {
+		if lo <= float64(i) && float64(i) <= hi {
+			ticks = append(ticks, Tick{float64(i), f.xTickLabels[v]})
+		}
+	}
+	return ticks
+}
+
+// thinTicks returns at most n of ticks, evenly spaced, including the first.
+func thinTicks(ticks []Tick, n int) []Tick {
+	if n < 1 {
+		return nil
+	}
+	step := (len(ticks) + n - 1) / n
+	if step <= 1 {
+		return ticks
+	}
+	var out []Tick
+	for i := 0; i < len(ticks); i += step {
+		out = append(out, ticks[i])
+	}
+	return out
+}
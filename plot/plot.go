@@ -0,0 +1,678 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/perf/benchfmt"
+	"golang.org/x/perf/benchmath"
+	"golang.org/x/perf/benchproc"
+)
+
+// TODO: Do something with the residue. Probably complain if two points we're
+// averaging have different residues, much like benchstat does.
+
+type Plot struct {
+	// aes is the projection for each aesthetic dimension.
+	aes aesMap[projection]
+
+	// unitAes is the aesthetic the unit (.unit) is bound to, if any.
+	unitAes Aes
+	// unitField is the field of unitAes's projection the unit is bound to.
+	unitField *benchproc.Field
+	// dvAes is the aesthetic the dependent variable (.value) is bound to, if any.
+	dvAes Aes
+
+	// logScale is the log base for each aesthetic, or 0 for linear.
+	logScale aesMap[int]
+	// geom is how the points of each series are drawn.
+	geom Geom
+	// xLabels is the projection of the labels of an ordinal X axis, or
+	// nil if it's continuous. xTickLabels is the label of each X value,
+	// from the first result Add saw with that value.
+	xLabels     *benchproc.Projection
+	xTickLabels map[float64]string
+	// breakY indicates that Y axes may be broken, as described by
+	// Config.SetBreakY.
+	breakY bool
+	// dropEmpty indicates that facets with no points are dropped.
+	dropEmpty bool
+	// directLabels indicates that series are labeled at the ends of their
+	// lines instead of in a key.
+	directLabels bool
+	// anomalySigma is the threshold for marking anomalies, in multiples
+	// of a series' noise, or 0 if they aren't marked.
+	anomalySigma float64
+	// diffBase and diffTarget are the color labels of the series compared
+	// by each facet's difference panel, or "" if there isn't one.
+	diffBase, diffTarget string
+
+	// vlines, hlines, hbands, and regions are the reference marks drawn
+	// across every facet.
+	vlines, hlines []RefLine
+	hbands         []RefBand
+	regions        []RefBand
+	// notes are the text annotations of the facets.
+	notes []Annotation
+
+	// direction is the direction of change highlighted in comparisons.
+	direction Direction
+
+	// highlight selects the highlighted series, or is nil if all series
+	// are drawn alike. highlighted is the set of color keys of the results
+	// it has matched.
+	highlight   *benchproc.Filter
+	highlighted map[benchproc.Key]struct{}
+
+	// variability is the statistic the DV has been transformed to, if any.
+	variability variability
+
+	// noisiest is the number of series to keep in TransformNoisiest.
+	noisiest int
+	// noise is the series ranking computed by TransformNoisiest.
+	noise []Noise
+
+	// changes is the comparison of each point computed by
+	// TransformCompare.
+	changes []Change
+
+	units benchfmt.UnitMetadataMap
+
+	// nResults counts the results added by Add.
+	nResults int
+
+	// caption is drawn under the plot, and watermark in its bottom
+	// right corner. Either may be "".
+	caption, watermark string
+
+	// points is the points of p. Transforms replace it with a new
+	// table.
+	points pointTable
+
+	// layout is the arrangement of points for rendering, or nil if
+	// it hasn't been computed since the points last changed.
+	layout *layout
+
+	// streaming indicates that points should be summarized as they
+	// are added. stream is the running summary of each group of
+	// points, and streamOrder lists the groups in the order they
+	// were first added. nStreamed counts the measurements added.
+	// streamMean indicates that groups are summarized by their mean.
+	streaming   bool
+	streamMean  bool
+	stream      map[point]*streamGroup
+	streamOrder []point
+	streamSrc   *rand.PCG
+	streamRand  *rand.Rand
+	nStreamed   int
+
+	// residue is the projection of fields not mapped to any
+	// aesthetic, if tracked. residues is the set of residue keys of
+	// the measurements in each group of points, indexed by the point
+	// with its DV cleared.
+	residue  *benchproc.Projection
+	residues map[point]map[benchproc.Key]struct{}
+
+	// gnuplot is the gnuplot binary, or "" to find it.
+	gnuplot string
+
+	// keepScript is a file to save the gnuplot script to, or "".
+	keepScript string
+
+	// addVals and addDVs are buffers for Add, for the projected values
+	// of each aesthetic and the measurement of each unit.
+	addVals [aesMax][]value
+	addDVs  []value
+}
+
+// A projection describes how to map from a [benchfmt.Result] to a value. The
+// zero value maps all Results to the zero value.
+type projection struct {
+	iv        *benchproc.Projection
+	ivField   *benchproc.Field // set if iv has exactly one field
+	unitField *benchproc.Field // set if iv has a .unit field
+
+	dv bool
+}
+
+type value struct {
+	kinds valueKinds
+	key   benchproc.Key // if kinds & kindDiscrete
+	val   float64       // if kinds & kindContinuous
+
+	summary *benchmath.Summary // if kinds & kindSummary
+	denom   benchproc.Key      // if kindRatio AND kindDiscrete
+}
+
+type valueKinds uint8
+
+const (
+	kindDiscrete valueKinds = 1 << iota
+	kindContinuous
+	kindSummary // Implies kindContinuous
+	kindRatio   // Implies kindContinuous OR kindDiscrete
+
+	kindMax
+
+	kindAll = kindMax - 1
+)
+
+type point struct {
+	aesMap[value]
+}
+
+// NewPlot returns a new, empty Plot using the aesthetic mappings and options in
+// c. Later changes to c do not affect the Plot. It returns an error if c maps
+// .unit or .value to more than one aesthetic, or maps only one of them.
+func NewPlot(c *Config) (*Plot, error) {
+	// Find unit and DV.
+	unitAes, dvAes := aesNone, aesNone
+	var unitField *benchproc.Field
+	for aes := range aesMax {
+		proj := c.aes.Get(aes)
+		if proj.unitField != nil {
+			if unitAes != aesNone {
+				return nil, fmt.Errorf("at most one dimension may show .unit")
+			}
+			unitAes, unitField = aes, proj.unitField
+		}
+		if proj.dv {
+			if dvAes != aesNone {
+				return nil, fmt.Errorf("at most one dimension may show .value")
+			}
+			dvAes = aes
+		}
+	}
+	// If we have a unit field, then we also need a DV.
+	if unitAes != aesNone && dvAes == aesNone {
+		return nil, fmt.Errorf(".unit is mapped to the %s dimension, but no dimension shows .value", unitAes.Name())
+	}
+	if unitAes == aesNone && dvAes != aesNone {
+		return nil, fmt.Errorf(".value is mapped to the %s dimension, but no dimension shows .unit", dvAes.Name())
+	}
+
+	return &Plot{
+		aes:          c.aes.Copy(),
+		unitAes:      unitAes,
+		unitField:    unitField,
+		dvAes:        dvAes,
+		logScale:     c.logScale,
+		geom:         c.geom,
+		xLabels:      c.xLabels,
+		breakY:       c.breakY,
+		dropEmpty:    c.dropEmpty,
+		directLabels: c.directLabels,
+		anomalySigma: c.anomalySigma,
+		diffBase:     c.diffBase,
+		diffTarget:   c.diffTarget,
+		vlines:       slices.Clone(c.vlines),
+		hlines:       slices.Clone(c.hlines),
+		hbands:       slices.Clone(c.hbands),
+		regions:      slices.Clone(c.regions),
+		notes:        slices.Clone(c.notes),
+		watermark:    c.watermark,
+		direction:    c.direction,
+		highlight:    c.highlight,
+		noisiest:     c.noisiest,
+		streaming:    c.streaming,
+		streamMean:   c.streamMean,
+		residue:      c.residue,
+		gnuplot:      c.gnuplot,
+		keepScript:   c.keepScript,
+	}, nil
+}
+
+func (p projection) project(r *benchfmt.Result) []value {
+	return p.appendProject(nil, r)
+}
+
+// appendProject appends the values p maps r to to values and returns the
+// extended slice.
+func (p projection) appendProject(values []value, r *benchfmt.Result) []value {
+	if p.dv {
+		panic("cannot project DV")
+	}
+	if p.iv == nil {
+		return append(values, value{kinds: kindDiscrete})
+	}
+
+	start := len(values)
+	if p.unitField != nil {
+		for _, key := range p.iv.ProjectValues(r) {
+			values = append(values, value{kinds: kindDiscrete, key: key})
+		}
+	} else {
+		key := p.iv.Project(r)
+		values = append(values, value{kinds: kindDiscrete, key: key})
+	}
+
+	// Try to parse continuous values, too.
+	if p.ivField != nil {
+		for i := start; i < len(values); i++ {
+			s := values[i].key.Get(p.ivField)
+			val, err := strconv.ParseFloat(s, 64)
+			if err == nil {
+				values[i].kinds |= kindContinuous
+				values[i].val = val
+			}
+		}
+	}
+
+	return values
+}
+
+func (p projection) String() string {
+	if p.dv {
+		return ".value"
+	}
+	if p.iv != nil {
+		var out strings.Builder
+		for i, field := range p.iv.FlattenedFields() {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			out.WriteString(field.String())
+		}
+		return out.String()
+	}
+	return "<nil>"
+}
+
+func (v value) String() string {
+	if v.kinds&kindDiscrete != 0 {
+		if v.kinds&kindRatio != 0 {
+			return v.key.String() + " vs " + v.denom.String()
+		}
+		return v.key.String()
+	}
+	return fmt.Sprint(v.val)
+}
+
+func (v value) StringValues() string {
+	if v.kinds&kindDiscrete != 0 {
+		if v.kinds&kindRatio != 0 {
+			return v.key.StringValues() + " vs " + v.denom.StringValues()
+		}
+		return v.key.StringValues()
+	}
+	return fmt.Sprint(v.val)
+}
+
+func (v value) compare(v2 value) int {
+	if v.kinds&v2.kinds&kindDiscrete != 0 {
+		if c := compareKeys(v.key, v2.key); c != 0 {
+			return c
+		}
+		if v.kinds&kindRatio != 0 {
+			return compareKeys(v.denom, v2.denom)
+		}
+		return 0
+	}
+	if v.kinds&v2.kinds&kindContinuous != 0 {
+		return cmp.Compare(v.val, v2.val)
+	}
+	// Otherwise, put them in kind order.
+	for kind := range kindMax {
+		if v.kinds&kind != 0 && v2.kinds&kind == 0 {
+			return -1
+		}
+		if v.kinds&kind == 0 && v2.kinds&kind != 0 {
+			return 1
+		}
+	}
+	// Something went very wrong.
+	panic(fmt.Errorf("incomparable kinds %#x, %#x", v.kinds, v2.kinds))
+}
+
+// Clone returns a copy of p. The copy and p can be added to, transformed, and
+// rendered independently. This is useful for keeping a Plot that
+// accumulates results as they arrive, and rendering a transformed clone of it
+// after each update.
+func (p *Plot) Clone() *Plot {
+	q := *p
+	q.points = p.points.clone()
+	q.noise = slices.Clone(p.noise)
+	q.changes = slices.Clone(p.changes)
+	q.highlighted = maps.Clone(p.highlighted)
+	q.xTickLabels = maps.Clone(p.xTickLabels)
+	if p.stream != nil {
+		q.stream = make(map[point]*streamGroup, len(p.stream))
+		for pt, g := range p.stream {
+			g2 := *g
+			g2.sample = slices.Clone(g.sample)
+			q.stream[pt] = &g2
+		}
+		q.streamOrder = slices.Clone(p.streamOrder)
+	}
+	if p.streamSrc != nil {
+		src := *p.streamSrc
+		q.streamSrc = &src
+		q.streamRand = rand.New(q.streamSrc)
+	}
+	if p.residues != nil {
+		q.residues = make(map[point]map[benchproc.Key]struct{}, len(p.residues))
+		for pt, keys := range p.residues {
+			q.residues[pt] = maps.Clone(keys)
+		}
+	}
+	// Don't share Add's buffers.
+	q.addVals, q.addDVs = [aesMax][]value{}, nil
+	// The layout is never modified, so it can be shared.
+	return &q
+}
+
+// label returns the axis label for aesthetic aes of pt.
+func (p *Plot) label(pt point, aes Aes) string {
+	proj := p.aes.Get(aes)
+	if proj.dv {
+		return pt.Get(p.unitAes).key.Get(p.unitField)
+	}
+	return proj.String()
+}
+
+// dimmed reports whether the series of color is drawn dimmed, because p
+// highlights some series and not this one.
+func (p *Plot) dimmed(color value) bool {
+	if p.highlight == nil {
+		return false
+	}
+	_, ok := p.highlighted[color.key]
+	return !ok
+}
+
+// Add adds the measurements in rec to p. p retains only what it projects
+// from rec, so the caller may reuse rec.
+func (p *Plot) Add(rec *benchfmt.Result) {
+	p.nResults++
+	var residueKey benchproc.Key
+	if p.residue != nil {
+		residueKey = p.residue.Project(rec)
+	}
+
+	// Project rec for each aesthetic. Add is called for every result, so
+	// this reuses the buffers from the last call rather than allocating.
+	// Each projection usually has just one value.
+	vals, dvs := &p.addVals, p.addDVs[:0]
+	for aes := range aesMax {
+		vals[aes] = vals[aes][:0]
+		proj := p.aes.Get(aes)
+		if proj.dv {
+			// We fill this in from the .unit projection.
+			continue
+		}
+		vals[aes] = proj.appendProject(vals[aes], rec)
+		if proj.unitField == nil {
+			continue
+		}
+		// Find the measurement of each unit, dropping the units
+		// rec doesn't have.
+		units := vals[aes][:0]
+		for _, val := range vals[aes] {
+			if v, ok := rec.Value(val.key.Get(proj.unitField)); ok {
+				units = append(units, val)
+				dvs = append(dvs, value{kinds: kindContinuous, val: v})
+			}
+		}
+		vals[aes] = units
+		if len(units) == 0 {
+			// rec has none of the units.
+			return
+		}
+	}
+	p.addDVs = dvs
+
+	if p.xLabels != nil {
+		var label string
+		for _, x := range vals[AesX] {
+			if _, ok := p.xTickLabels[x.val]; ok || x.kinds&kindContinuous == 0 {
+				continue
+			}
+			if label == "" {
+				label = p.xLabels.Project(rec).StringValues()
+			}
+			if p.xTickLabels == nil {
+				p.xTickLabels = make(map[float64]string)
+			}
+			p.xTickLabels[x.val] = label
+		}
+	}
+
+	if p.highlight != nil {
+		if m, err := p.highlight.Match(rec); err == nil && m.Any() {
+			if p.highlighted == nil {
+				p.highlighted = make(map[benchproc.Key]struct{})
+			}
+			for _, val := range vals[AesColor] {
+				p.highlighted[val.key] = struct{}{}
+			}
+		}
+	}
+
+	// Add a point for each combination of values, varying the last
+	// aesthetic fastest.
+	var idx [aesMax]int
+	for {
+		var pt point
+		for aes := range aesMax {
+			if len(vals[aes]) > 0 {
+				pt.Set(aes, vals[aes][idx[aes]])
+			}
+		}
+		if p.unitAes != aesNone {
+			pt.Set(p.dvAes, dvs[idx[p.unitAes]])
+		}
+		if p.residue != nil {
+			p.addResidue(pt, residueKey)
+		}
+		if p.streaming && p.dvAes != aesNone {
+			p.addStreaming(pt)
+		} else {
+			p.points.add(pt)
+			p.layout = nil
+		}
+
+		aes := aesMax - 1
+		for ; aes >= 0; aes-- {
+			if idx[aes]++; idx[aes] < len(vals[aes]) {
+				break
+			}
+			idx[aes] = 0
+		}
+		if aes < 0 {
+			break
+		}
+	}
+}
+
+// AddPoint adds a single measurement to p without a benchfmt.Result, for
+// plotting values that don't come from benchmark results, such as derived
+// metrics. vals gives the value of each aesthetic mapped to a projection,
+// other than the one that shows .unit, and val is the measurement in unit.
+// Aesthetics missing from vals get the same value as a result without the
+// projected field.
+//
+// Each aesthetic in vals must be mapped to a projection of a single field
+// other than .config. AddPoint returns an error for an aesthetic that isn't
+// mapped this way.
+func (p *Plot) AddPoint(vals map[Aes]string, unit string, val float64) error {
+	// Build a result that projects to vals.
+	rec := &benchfmt.Result{}
+	var base, full string
+	var parts []string
+	for aes := range aesMax {
+		v, ok := vals[aes]
+		if !ok {
+			continue
+		}
+		proj := p.aes.Get(aes)
+		switch {
+		case proj.dv:
+			return fmt.Errorf("%s shows .value; pass the value separately", aes.Name())
+		case proj.unitField != nil:
+			return fmt.Errorf("%s shows .unit; pass the unit separately", aes.Name())
+		case proj.iv == nil:
+			return fmt.Errorf("%s is not mapped", aes.Name())
+		case proj.ivField == nil:
+			return fmt.Errorf("%s projects %s, which is not a single field", aes.Name(), proj)
+		}
+		switch name := proj.ivField.Name; {
+		case name == ".name":
+			base = v
+		case name == ".fullname":
+			full = v
+		case name == ".config":
+			return fmt.Errorf("%s projects .config, which is not a single field", aes.Name())
+		case name == "/gomaxprocs":
+			parts = append(parts, "-"+v)
+		case strings.HasPrefix(name, "/"):
+			parts = append(parts, name+"="+v)
+		default:
+			rec.SetConfig(name, v)
+		}
+	}
+	if full != "" {
+		if base != "" || len(parts) > 0 {
+			return fmt.Errorf("cannot set both .fullname and .name or name keys")
+		}
+		rec.Name = benchfmt.Name(full)
+	} else {
+		rec.Name = benchfmt.Name(base + strings.Join(parts, ""))
+	}
+	rec.Values = []benchfmt.Value{{Value: val, Unit: unit}}
+	p.Add(rec)
+	return nil
+}
+
+// SetUnits sets the unit metadata of the measurements in p, which determines
+// how each unit is summarized and compared and which direction is better.
+// This should be called after adding all of the measurements and before
+// applying any transforms.
+func (p *Plot) SetUnits(units benchfmt.UnitMetadataMap) {
+	p.units = units
+	p.layout = nil
+}
+
+// SetCaption sets text to draw in small type under the plot, such as to record
+// where its data came from. If caption is "", there's no caption.
+func (p *Plot) SetCaption(caption string) {
+	p.caption = caption
+}
+
+func compareKeys(a, b benchproc.Key) int {
+	// TODO: Key should have a Compare method
+	if a == b {
+		return 0
+	}
+	if a.Less(b) {
+		return -1
+	}
+	return 1
+}
+
+func sortedKeys(set map[benchproc.Key]struct{}) []benchproc.Key {
+	sl := make([]benchproc.Key, 0, len(set))
+	for k := range set {
+		sl = append(sl, k)
+	}
+	benchproc.SortKeys(sl)
+	return sl
+}
+
+func sortedValues(set map[value]struct{}) []value {
+	sl := make([]value, 0, len(set))
+	for v := range set {
+		sl = append(sl, v)
+	}
+
+	slices.SortFunc(sl, func(a, b value) int {
+		return a.compare(b)
+	})
+
+	return sl
+}
+
+// sliceBy divides slice s into subslices by equal values of grouper.
+func sliceBy[T any, U comparable](s []T, grouper func(T) U, doGroup func(U, []T)) {
+	if len(s) == 0 {
+		return
+	}
+
+	start := 0
+	startVal := grouper(s[0])
+	for i := 1; i < len(s); i++ {
+		val := grouper(s[i])
+		if val != startVal {
+			doGroup(startVal, s[start:i])
+			start, startVal = i, val
+		}
+	}
+	doGroup(startVal, s[start:])
+}
+
+// groupBy groups the elements of s according to the value of grouper, and
+// returns the groups and their keys in order of first appearance. Rather than
+// copying the elements of each group, it sorts s once in place by group,
+// keeping the order of the elements of each group, so every group is a
+// subslice of s. If the groups are already contiguous, s is unchanged.
+func groupBy[T any, U comparable](s []T, grouper func(T) U) (map[U][]T, []U) {
+	out := make(map[U][]T)
+	if len(s) == 0 {
+		return out, nil
+	}
+
+	// Number the groups in order of first appearance, and count them.
+	ids := make(map[U]int)
+	var keys []U
+	var ends []int              // Size of each group, then the end of its range
+	dest := make([]int, len(s)) // Group of each element, for now
+	for i, x := range s {
+		k := grouper(x)
+		id, ok := ids[k]
+		if !ok {
+			id = len(keys)
+			ids[k] = id
+			keys = append(keys, k)
+			ends = append(ends, 0)
+		}
+		dest[i] = id
+		ends[id]++
+	}
+
+	// Find the range of s that each group will occupy, and the index in s
+	// each element moves to.
+	starts := make([]int, len(keys))
+	for id := 1; id < len(keys); id++ {
+		starts[id] = starts[id-1] + ends[id-1]
+	}
+	for id := range ends {
+		ends[id] = starts[id]
+	}
+	for i, id := range dest {
+		dest[i] = ends[id]
+		ends[id]++
+	}
+
+	// Apply the permutation by following its cycles, which moves each
+	// element directly to its place.
+	for i := range s {
+		for dest[i] != i {
+			j := dest[i]
+			s[i], s[j] = s[j], s[i]
+			dest[i], dest[j] = dest[j], dest[i]
+		}
+	}
+
+	for id, k := range keys {
+		out[k] = s[starts[id]:ends[id]:ends[id]]
+	}
+	return out, keys
+}
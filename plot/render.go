@@ -0,0 +1,190 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"sync"
+)
+
+// Format is an output format for rendering a plot.
+type Format int
+
+const (
+	FormatPNG    Format = iota
+	FormatSVG           // Scalable vector graphics
+	FormatScript        // The gnuplot script, without running gnuplot
+
+	formatMax
+)
+
+// Name returns a short name for format f, such as "png".
+func (f Format) Name() string {
+	switch f {
+	case FormatPNG:
+		return "png"
+	case FormatSVG:
+		return "svg"
+	case FormatScript:
+		return "gnuplot"
+	}
+	return fmt.Sprintf("Format(%d)", f)
+}
+
+var nameToFormat = sync.OnceValue(func() map[string]Format {
+	m := make(map[string]Format)
+	for i := Format(0); i < formatMax; i++ {
+		m[i.Name()] = i
+	}
+	return m
+})
+
+// FormatFromName is the inverse of [Format.Name].
+func FormatFromName(name string) (Format, bool) {
+	f, ok := nameToFormat()[name]
+	return f, ok
+}
+
+// Theme is a color scheme for rendering a plot.
+type Theme int
+
+const (
+	ThemeLight Theme = iota // Dark text on a white background
+	ThemeDark               // Light text on a black background
+
+	themeMax
+)
+
+// Name returns a short name for theme t, such as "dark".
+func (t Theme) Name() string {
+	switch t {
+	case ThemeLight:
+		return "light"
+	case ThemeDark:
+		return "dark"
+	}
+	return fmt.Sprintf("Theme(%d)", t)
+}
+
+var nameToTheme = sync.OnceValue(func() map[string]Theme {
+	m := make(map[string]Theme)
+	for i := Theme(0); i < themeMax; i++ {
+		m[i.Name()] = i
+	}
+	return m
+})
+
+// ThemeFromName is the inverse of [Theme.Name].
+func ThemeFromName(name string) (Theme, bool) {
+	t, ok := nameToTheme()[name]
+	return t, ok
+}
+
+// Default facet size and resolution.
+const (
+	defaultWidth  = 640
+	defaultHeight = 480
+	defaultDPI    = 96
+)
+
+// RenderOptions control how a plot is rendered. The zero value renders a PNG
+// with 640 by 480 pixel facets in the light theme.
+type RenderOptions struct {
+	Format Format
+
+	// Width and Height give the size of each facet in pixels at 96 DPI.
+	// If either is 0, it defaults to 640 by 480.
+	Width, Height int
+
+	// DPI scales PNG images, including their text, to the given
+	// resolution. If 0, it defaults to 96. It does not affect other
+	// formats.
+	DPI int
+
+	Theme Theme
+}
+
+// Validate returns an error if o is not a valid set of options. All of the
+// render methods validate their options before doing any work.
+func (o RenderOptions) Validate() error {
+	if o.Format < 0 || o.Format >= formatMax {
+		return fmt.Errorf("unknown format %s", o.Format.Name())
+	}
+	if o.Theme < 0 || o.Theme >= themeMax {
+		return fmt.Errorf("unknown theme %s", o.Theme.Name())
+	}
+	if o.Width < 0 || o.Height < 0 {
+		return fmt.Errorf("size %dx%d must not be negative", o.Width, o.Height)
+	}
+	if o.DPI < 0 {
+		return fmt.Errorf("DPI %d must not be negative", o.DPI)
+	}
+	return nil
+}
+
+// facetSize returns the size of each facet in output pixels and the font
+// scale for that size.
+func (o RenderOptions) facetSize() (width, height int, fontScale float64) {
+	width, height = o.Width, o.Height
+	if width == 0 {
+		width = defaultWidth
+	}
+	if height == 0 {
+		height = defaultHeight
+	}
+	fontScale = 1
+	if o.Format == FormatPNG && o.DPI != 0 && o.DPI != defaultDPI {
+		fontScale = float64(o.DPI) / defaultDPI
+		width = int(float64(width) * fontScale)
+		height = int(float64(height) * fontScale)
+	}
+	return
+}
+
+// RenderBytes renders p as described by opts and returns the encoded image.
+// Unless opts.Format is [FormatScript], this requires gnuplot.
+func (p *Plot) RenderBytes(opts RenderOptions) ([]byte, error) {
+	return p.RenderBytesContext(context.Background(), opts)
+}
+
+// RenderBytesContext is like [Plot.RenderBytes], but stops rendering if ctx is
+// done, like [Plot.RenderContext].
+func (p *Plot) RenderBytesContext(ctx context.Context, opts RenderOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := p.RenderContext(ctx, opts, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderImage renders p as described by opts and returns the decoded image.
+// opts.Format must be [FormatPNG]. This requires gnuplot.
+func (p *Plot) RenderImage(opts RenderOptions) (image.Image, error) {
+	return p.RenderImageContext(context.Background(), opts)
+}
+
+// RenderImageContext is like [Plot.RenderImage], but stops rendering if ctx is
+// done, like [Plot.RenderContext].
+func (p *Plot) RenderImageContext(ctx context.Context, opts RenderOptions) (image.Image, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Format != FormatPNG {
+		return nil, fmt.Errorf("cannot decode %s format as an image", opts.Format.Name())
+	}
+	data, err := p.RenderBytesContext(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding gnuplot output: %w", err)
+	}
+	return img, nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"io"
+	"math"
+	"slices"
+
+	"golang.org/x/perf/benchunit"
+)
+
+// A Renderer draws a plot from its [Layout], for drawing plots without
+// gnuplot or adding marks that gnuplot rendering doesn't support.
+type Renderer interface {
+	Render(l *Layout, out io.Writer) error
+}
+
+// RenderWith renders p using r, writing the result to out.
+func (p *Plot) RenderWith(r Renderer, out io.Writer) error {
+	l, err := p.Layout()
+	if err != nil {
+		return err
+	}
+	return r.Render(l, out)
+}
+
+// A Layout is the arrangement of a Plot's points into facets and series,
+// with their values scaled and summarized the same way as when rendering
+// with gnuplot.
+type Layout struct {
+	// Rows and Cols give the dimensions of the grid of facets.
+	Rows, Cols int
+	// RowLabels, ColLabels, and ColorLabels give the ordinal scales of
+	// the row, column, and color aesthetics: the label of each index,
+	// such as Facet.Row or Series.Color, in order.
+	RowLabels, ColLabels, ColorLabels []string
+	// Wrapped indicates that facets with no data were dropped and the
+	// rest wrapped into a smaller grid, so Facet.Row and Facet.Col are
+	// only positions in the grid, not indexes into RowLabels and
+	// ColLabels.
+	Wrapped bool
+	// Colors is the number of distinct colors across all facets.
+	Colors int
+	// Confidence is the confidence level of each Mark's interval,
+	// such as 0.95.
+	Confidence float64
+	// Geom is how the marks of each series are drawn.
+	Geom Geom
+	// Facets lists the facets that have data, in column-major order.
+	Facets []Facet
+}
+
+// A Facet is one plot in the grid of a Layout.
+type Facet struct {
+	// Row and Col give the position of this facet in the grid, and
+	// RowLabel and ColLabel give the values of the row and column
+	// aesthetics.
+	Row, Col           int
+	RowLabel, ColLabel string
+	X, Y               Axis
+	// Series are in order of Color.
+	Series []Series
+	// Diff is the difference panel beneath the facet, as set by
+	// [Config.SetDiff], in order of X. The Y of each mark is the ratio of
+	// the target series' center to the base series', and its Point is the
+	// target's point.
+	Diff []Mark
+}
+
+// A Series is the marks of one color in a Facet.
+type Series struct {
+	Label string
+	// Color is the index of this series' color, in [0, Layout.Colors).
+	// A series with the same Label has the same Color in every facet.
+	Color int
+	// Dimmed indicates that the plot highlights some series and this
+	// isn't one of them.
+	Dimmed bool
+	// Marks are in order of X.
+	Marks []Mark
+}
+
+// A Mark is one summarized point of a Series.
+type Mark struct {
+	// X and Y are the scaled values of the point. Y is the center of
+	// the summarized measurements.
+	X, Y float64
+	// Lo and Hi give the scaled confidence interval of Y, or are NaN if
+	// there are too few measurements or the unit is exact.
+	Lo, Hi float64
+	// Samples are the scaled measurements summarized into Y, in the
+	// order they were added, or nil if they were summarized before they
+	// were laid out, such as by a comparison or when streaming.
+	Samples []float64
+	// Point is the summarized point, for its labels and unit.
+	Point Point
+	// Anomaly indicates that the point is far from the points before it
+	// in its series, as found by [Config.SetAnomalies].
+	Anomaly bool
+}
+
+// Layout returns the layout of p. Like [Plot.Shape], this reflects any
+// transforms that have been applied to p.
+func (p *Plot) Layout() (*Layout, error) {
+	l, err := p.getLayout()
+	if err != nil {
+		return nil, err
+	}
+	out := &Layout{
+		Rows:        l.nRows,
+		Cols:        l.nCols,
+		RowLabels:   slices.Clone(l.rowLabels),
+		ColLabels:   slices.Clone(l.colLabels),
+		ColorLabels: slices.Clone(l.colorLabels),
+		Wrapped:     l.wrapped,
+		Colors:      l.nColors,
+		Confidence:  renderConfidence,
+		Geom:        p.geom,
+	}
+	for col := range l.nCols {
+		for row := range l.nRows {
+			f := l.facets[rowCol{row, col}]
+			if len(f.pts) == 0 {
+				continue
+			}
+			out.Facets = append(out.Facets, p.layoutFacet(l, row, col, f))
+		}
+	}
+	return out, nil
+}
+
+func (p *Plot) layoutFacet(l *layout, row, col int, f facet) Facet {
+	out := Facet{
+		Row:      row,
+		Col:      col,
+		RowLabel: f.rowLabel,
+		ColLabel: f.colLabel,
+	}
+	axis := func(aes Aes) (func(float64) float64, Axis) {
+		scale, label, kind := p.axisScale(f.pts, aes)
+		a := Axis{
+			Label:   label,
+			Log:     p.logScale.Get(aes),
+			Ratio:   kind == axisRatio,
+			Percent: kind == axisPercent,
+			Min:     math.Inf(1),
+			Max:     math.Inf(-1),
+			class:   benchunit.Decimal,
+		}
+		if units := p.pointsUnits(f.pts); aes == p.dvAes && len(units) == 1 {
+			a.class = benchunit.ClassOf(units[0])
+		}
+		if a.Ratio {
+			a.Min, a.Max = 0, 0
+		}
+		if aes == AesX && f.xOrdinal != nil {
+			scale, a.Log = ordinalScale(f.xOrdinal), 0
+			xLo, xHi, _, _ := extent(f.pts, scale, func(y float64) float64 { return y })
+			a.ordinal = f.ordinalTicks(xLo, xHi)
+		}
+		return scale, a
+	}
+	extend := func(a *Axis, v float64) {
+		if !math.IsNaN(v) {
+			a.Min, a.Max = min(a.Min, v), max(a.Max, v)
+		}
+	}
+	var xScale, yScale func(float64) float64
+	xScale, out.X = axis(AesX)
+	yScale, out.Y = axis(AesY)
+
+	// Find the measurements summarized into each point.
+	withoutY := func(pt point) point {
+		pt.Set(AesY, value{})
+		return pt
+	}
+	var samples map[point][]point
+	if pointsKinds(f.pts, AesY)&kindSummary == 0 {
+		samples, _ = groupBy(f.pts, withoutY)
+	}
+
+	sliceBy(f.summary, pointAesGetter(AesColor), func(color value, pts []point) {
+		s := Series{Label: color.StringValues(), Color: l.colorScale(pts[0]), Dimmed: p.dimmed(color)}
+		for _, pt := range pts {
+			m := Mark{
+				X:     xScale(pt.Get(AesX).val),
+				Y:     yScale(pt.Get(AesY).val),
+				Lo:    math.NaN(),
+				Hi:    math.NaN(),
+				Point: Point{pt, p},
+			}
+			_, m.Anomaly = f.anomalies[pt]
+			if y := pt.Get(AesY).summary; hasRange(y) {
+				m.Lo, m.Hi = yScale(y.Lo), yScale(y.Hi)
+			}
+			for _, s := range samples[withoutY(pt)] {
+				m.Samples = append(m.Samples, yScale(s.Get(AesY).val))
+			}
+			extend(&out.X, m.X)
+			for _, y := range []float64{m.Y, m.Lo, m.Hi} {
+				extend(&out.Y, y)
+			}
+			s.Marks = append(s.Marks, m)
+		}
+		out.Series = append(out.Series, s)
+	})
+	for _, d := range f.diff {
+		out.Diff = append(out.Diff, Mark{X: xScale(d.x), Y: d.ratio, Lo: math.NaN(), Hi: math.NaN(), Point: Point{d.target, p}})
+	}
+	return out
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"strings"
 
+	"golang.org/x/perf/benchmath"
 	"golang.org/x/perf/benchunit"
 )
 
@@ -27,6 +28,16 @@ func valuesKinds(values []value) valueKinds {
 	return kinds
 }
 
+// assumption returns the distributional assumption for the unit of pt, as
+// given by the "assume" unit metadata. If there is no unit field, it assumes
+// nothing.
+func (p *Plot) assumption(pt point) benchmath.Assumption {
+	if p.unitField == nil {
+		return benchmath.AssumeNothing
+	}
+	return p.units.GetAssumption(pt.Get(p.unitAes).key.Get(p.unitField))
+}
+
 // pointsUnits returns the distinct units in pts. It returns nil if pts is empty
 // or there is no unit field.
 func (p *Plot) pointsUnits(pts []point) []string {
@@ -61,13 +72,9 @@ func (p *Plot) pointsUnits(pts []point) []string {
 	return unitNames
 }
 
-// ordScale returns an ordinal scale from aes to [0, bound).
-func ordScale(pts []point, aes Aes) (scale func(point) int, bound int) {
-	// Collect all unique values.
-	vals := make(map[value]struct{})
-	for _, pt := range pts {
-		vals[pt.Get(aes)] = struct{}{}
-	}
+// ordScale returns an ordinal scale from aes to [0, bound), given the set of
+// all values of aes.
+func ordScale(vals map[value]struct{}, aes Aes) (scale func(point) int, bound int) {
 	// Create a mapping index.
 	ord := make(map[value]int)
 	for i, k := range sortedValues(vals) {
@@ -81,6 +88,44 @@ func ordScale(pts []point, aes Aes) (scale func(point) int, bound int) {
 	}, len(ord)
 }
 
+// ordLabels returns the label of each value of the ordinal scale of aes, in
+// the order of the indexes assigned by ordScale, given the set of all values
+// of aes.
+func ordLabels(vals map[value]struct{}) []string {
+	sorted := sortedValues(vals)
+	labels := make([]string, len(sorted))
+	for i, v := range sorted {
+		labels[i] = v.StringValues()
+	}
+	return labels
+}
+
+// ordLevels returns the value of each field of proj of each value of the
+// ordinal scale of aes, in the order of ordLabels, outermost field first,
+// given the set of all values of aes. It returns nil unless proj has more than
+// one field.
+func ordLevels(vals map[value]struct{}, proj projection) [][]string {
+	if proj.iv == nil {
+		return nil
+	}
+	fields := proj.iv.FlattenedFields()
+	if len(fields) < 2 {
+		return nil
+	}
+	sorted := sortedValues(vals)
+	levels := make([][]string, len(sorted))
+	for i, v := range sorted {
+		if v.kinds&kindRatio != 0 {
+			// The label of a ratio names two keys.
+			return nil
+		}
+		for _, field := range fields {
+			levels[i] = append(levels[i], v.key.Get(field))
+		}
+	}
+	return levels
+}
+
 func (p *Plot) continuousScale(pts []point, aes Aes, rescale bool) (scale func(float64) float64, lo, hi float64, label string, err error) {
 	if pointsKinds(pts, aes)&kindContinuous == 0 {
 		err = fmt.Errorf("%s data must be numeric", aes.Name())
@@ -146,9 +191,43 @@ func (p *Plot) continuousScale(pts []point, aes Aes, rescale bool) (scale func(f
 	// though then we'd probably need to compute out own tick marks.
 	labels := make([]string, 0, 1)
 	for _, n := range unitNames {
+		if p.units.GetAssumption(n) == benchmath.AssumeExact {
+			labels = append(labels, prefix+n+" (exact)")
+			continue
+		}
 		labels = append(labels, prefix+n)
 	}
 	label = strings.Join(labels, ", ")
+	if p.variability != varNone {
+		label = p.variability.String() + " " + label
+	}
 
 	return
 }
+
+// An axisKind is how the values on an axis are shown.
+type axisKind int
+
+const (
+	axisPlain   axisKind = iota
+	axisRatio            // Ratios, shown as a percent delta
+	axisPercent          // Coefficients of variation, shown as a percent
+)
+
+// axisScale returns the scale and label for showing aesthetic aes of pts on
+// an axis, and how its values should be formatted. The scale does no
+// rescaling of units, since renderers can do the scientific scaling when
+// formatting.
+func (p *Plot) axisScale(pts []point, aes Aes) (scale func(float64) float64, label string, kind axisKind) {
+	kinds := pointsKinds(pts, aes)
+	scale, _, _, label, _ = p.continuousScale(pts, aes, false)
+	if kinds&kindRatio != 0 {
+		scale = func(x float64) float64 { return (x - 1) * 100 }
+		return scale, "delta " + label, axisRatio
+	}
+	if aes == p.dvAes && p.variability == varCoV {
+		scale = func(x float64) float64 { return x * 100 }
+		return scale, label, axisPercent
+	}
+	return scale, label, axisPlain
+}
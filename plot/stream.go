@@ -0,0 +1,115 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"math"
+	"math/rand/v2"
+
+	"golang.org/x/perf/benchmath"
+)
+
+// streamSampleSize is the maximum number of values retained for each group in
+// streaming mode. Summaries are computed from a uniform random sample of this
+// size, so the confidence intervals of groups larger than this are somewhat
+// wider than they would otherwise be. The sample is deterministic, since the
+// random source has a fixed seed.
+const streamSampleSize = 1024
+
+// A streamGroup is the running summary of a group of points that differ only
+// in the DV.
+type streamGroup struct {
+	n        int       // Number of values added
+	mean, m2 float64   // Running mean and sum of squared deviations
+	sample   []float64 // Reservoir sample of the group's values
+}
+
+// add adds val to group g, using rng to maintain its reservoir sample.
+func (g *streamGroup) add(val float64, rng *rand.Rand) {
+	g.n++
+	// Welford's algorithm, which doesn't lose precision to cancellation
+	// like summing squares does.
+	d := val - g.mean
+	g.mean += d / float64(g.n)
+	g.m2 += d * (val - g.mean)
+	if len(g.sample) < streamSampleSize {
+		g.sample = append(g.sample, val)
+	} else if i := rng.IntN(g.n); i < streamSampleSize {
+		g.sample[i] = val
+	}
+}
+
+// meanSummary returns the mean of all of the values of g and its confidence
+// interval, assuming the values are normally distributed.
+func (g *streamGroup) meanSummary(confidence float64) benchmath.Summary {
+	if g.n <= len(g.sample) {
+		// The sample is every value, so let benchmath do it.
+		sample := benchmath.NewSample(g.sample, &benchmath.DefaultThresholds)
+		return benchmath.AssumeNormal.Summary(sample, confidence)
+	}
+	n := float64(g.n)
+	se := math.Sqrt(g.m2/(n-1)) / math.Sqrt(n)
+	t := tQuantile((1+confidence)/2, n-1)
+	return benchmath.Summary{Center: g.mean, Lo: g.mean - t*se, Hi: g.mean + t*se, Confidence: confidence}
+}
+
+// tQuantile returns the p quantile of Student's t-distribution with df
+// degrees of freedom. It uses the Cornish-Fisher expansion around the normal
+// distribution, which is only accurate for large df. meanSummary only uses it
+// for groups of more than streamSampleSize values, for which the error is
+// below 1e-9.
+func tQuantile(p, df float64) float64 {
+	z := math.Sqrt2 * math.Erfinv(2*p-1)
+	z2 := z * z
+	g1 := (z2 + 1) * z / 4
+	g2 := ((5*z2+16)*z2 + 3) * z / 96
+	g3 := (((3*z2+19)*z2+17)*z2 - 15) * z / 384
+	return z + g1/df + g2/(df*df) + g3/(df*df*df)
+}
+
+// addStreaming adds pt to the running summary of its group.
+func (p *Plot) addStreaming(pt point) {
+	val := pt.Get(p.dvAes).val
+	pt.Set(p.dvAes, value{})
+	g := p.stream[pt]
+	if g == nil {
+		if p.stream == nil {
+			p.stream = make(map[point]*streamGroup)
+			// Use a fixed seed so plots are reproducible.
+			p.streamSrc = rand.NewPCG(1, 2)
+			p.streamRand = rand.New(p.streamSrc)
+		}
+		g = new(streamGroup)
+		p.stream[pt] = g
+		p.streamOrder = append(p.streamOrder, pt)
+	}
+	g.add(val, p.streamRand)
+	p.nStreamed++
+}
+
+// flushStreaming turns the running summaries of all groups added in streaming
+// mode into summary points. The summary's center is the median of the group's
+// sample, like the summaries computed when plotting, or the mean of the
+// group if p.streamMean is set. Every operation that consumes p.points must
+// call this first.
+func (p *Plot) flushStreaming() {
+	if len(p.streamOrder) == 0 {
+		return
+	}
+	for _, pt := range p.streamOrder {
+		g := p.stream[pt]
+		var summary benchmath.Summary
+		if p.streamMean {
+			summary = g.meanSummary(0.95)
+		} else {
+			sample := benchmath.NewSample(g.sample, &benchmath.DefaultThresholds)
+			summary = p.assumption(pt).Summary(sample, 0.95)
+		}
+		pt.Set(p.dvAes, value{kinds: kindContinuous | kindSummary, val: summary.Center, summary: &summary})
+		p.points.add(pt)
+	}
+	p.stream, p.streamOrder = nil, nil
+	p.layout = nil
+}
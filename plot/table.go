@@ -0,0 +1,185 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"cmp"
+	"slices"
+)
+
+// A pointTable is a sequence of points stored by aesthetic. Most points share
+// their value of each aesthetic other than the measurement with many other
+// points, so each column stores the index of each point's value in a
+// dictionary of the column's distinct values. Values that are just numbers,
+// like measurements, are stored directly instead. This takes a fraction of
+// the memory of a []point, and comparing the values of a column only
+// compares their ranks in the dictionary.
+//
+// The zero value is an empty table.
+type pointTable struct {
+	n    int
+	cols [aesMax]column
+}
+
+// A column is the values of one aesthetic of a pointTable. Every value in
+// dict is the value of some point.
+type column struct {
+	// idx gives the index in dict of each point's value, or numIdx if
+	// the value is in nums.
+	idx []uint32
+	// nums gives the value of each point whose value is only a
+	// number. It's nil if there are none.
+	nums []float64
+
+	dict  []value
+	index map[value]uint32
+}
+
+// numIdx is the index of values stored in column.nums.
+const numIdx = ^uint32(0)
+
+func newPointTable(pts []point) pointTable {
+	var t pointTable
+	for _, pt := range pts {
+		t.add(pt)
+	}
+	return t
+}
+
+func (t *pointTable) len() int {
+	return t.n
+}
+
+// add appends pt to t.
+func (t *pointTable) add(pt point) {
+	for aes := range aesMax {
+		c := &t.cols[aes]
+		v := pt.Get(aes)
+		if v.kinds == kindContinuous {
+			if c.nums == nil {
+				c.nums = make([]float64, t.n, cap(c.idx))
+			}
+			c.nums = append(c.nums, v.val)
+			c.idx = append(c.idx, numIdx)
+			continue
+		}
+		if c.nums != nil {
+			c.nums = append(c.nums, 0)
+		}
+		i, ok := c.index[v]
+		if !ok {
+			if c.index == nil {
+				c.index = make(map[value]uint32)
+			}
+			i = uint32(len(c.dict))
+			c.dict = append(c.dict, v)
+			c.index[v] = i
+		}
+		c.idx = append(c.idx, i)
+	}
+	t.n++
+}
+
+// value returns the value of aes of the i'th point.
+func (t *pointTable) value(aes Aes, i int) value {
+	c := &t.cols[aes]
+	if idx := c.idx[i]; idx != numIdx {
+		return c.dict[idx]
+	}
+	return value{kinds: kindContinuous, val: c.nums[i]}
+}
+
+// get returns the i'th point.
+func (t *pointTable) get(i int) point {
+	var pt point
+	for aes := range aesMax {
+		pt.Set(aes, t.value(aes, i))
+	}
+	return pt
+}
+
+// all returns all of the points in t. The caller may modify the result.
+func (t *pointTable) all() []point {
+	pts := make([]point, t.n)
+	for i := range pts {
+		pts[i] = t.get(i)
+	}
+	return pts
+}
+
+// clone returns a copy of t that doesn't share storage with t.
+func (t *pointTable) clone() pointTable {
+	out := pointTable{n: t.n}
+	for aes := range aesMax {
+		c := &t.cols[aes]
+		out.cols[aes] = column{
+			idx:   slices.Clone(c.idx),
+			nums:  slices.Clone(c.nums),
+			dict:  slices.Clone(c.dict),
+			index: make(map[value]uint32, len(c.index)),
+		}
+		for k, v := range c.index {
+			out.cols[aes].index[k] = v
+		}
+	}
+	return out
+}
+
+// distinct returns the set of values of aes.
+func (t *pointTable) distinct(aes Aes) map[value]struct{} {
+	c := &t.cols[aes]
+	vals := make(map[value]struct{}, len(c.dict))
+	for _, v := range c.dict {
+		vals[v] = struct{}{}
+	}
+	if c.nums != nil {
+		for i, idx := range c.idx {
+			if idx == numIdx {
+				vals[value{kinds: kindContinuous, val: c.nums[i]}] = struct{}{}
+			}
+		}
+	}
+	return vals
+}
+
+// kinds returns the kinds that all of the values of aes have.
+func (t *pointTable) kinds(aes Aes) valueKinds {
+	c := &t.cols[aes]
+	kinds := valuesKinds(c.dict)
+	if c.nums != nil && slices.Contains(c.idx, numIdx) {
+		kinds &= kindContinuous
+	}
+	return kinds
+}
+
+// comparer returns a function that compares the values of aes of the i'th
+// and j'th points, like [value.compare].
+func (t *pointTable) comparer(aes Aes) func(i, j int) int {
+	c := &t.cols[aes]
+	// Rank the dictionary, so most comparisons are of integers.
+	order := make([]uint32, len(c.dict))
+	for i := range order {
+		order[i] = uint32(i)
+	}
+	slices.SortFunc(order, func(a, b uint32) int {
+		return c.dict[a].compare(c.dict[b])
+	})
+	rank := make([]uint32, len(c.dict))
+	for r, i := range order {
+		if r > 0 && c.dict[order[r-1]].compare(c.dict[i]) == 0 {
+			// Values that compare equal get the same rank.
+			rank[i] = rank[order[r-1]]
+		} else {
+			rank[i] = uint32(r)
+		}
+	}
+	return func(i, j int) int {
+		a, b := c.idx[i], c.idx[j]
+		if a != numIdx && b != numIdx {
+			return cmp.Compare(rank[a], rank[b])
+		}
+		return t.value(aes, i).compare(t.value(aes, j))
+	}
+}
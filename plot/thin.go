@@ -0,0 +1,94 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import "math"
+
+// A pixelGrid maps the data coordinates of a facet to the pixels they will be
+// drawn on, so a point that lands on the same pixel as the point before it in
+// its series can be dropped without visibly changing the plot. This keeps
+// dense series fast to render and their images small.
+//
+// The grid is based on the range of the data, which is never more than the
+// range of the axes, so its pixels are never larger than the real pixels.
+type pixelGrid struct {
+	x, y pixelAxis
+}
+
+// A pixelAxis maps data coordinates on one axis to pixels.
+type pixelAxis struct {
+	log   bool
+	lo    float64 // Coordinate of the low edge, after any log
+	scale float64 // Pixels per unit, or 0 if the range is empty
+}
+
+// newPixelGrid returns the grid of a facet of width by height pixels showing
+// pts, with the X and Y coordinates given by xScale and yScale. If logX or
+// logY is set, that axis has a log scale.
+func newPixelGrid(pts []point, xScale, yScale func(float64) float64, width, height int, logX, logY bool) *pixelGrid {
+	var xs, ys []float64
+	for _, pt := range pts {
+		xs = append(xs, xScale(pt.Get(AesX).val))
+		y := pt.Get(AesY)
+		ys = append(ys, yScale(y.val))
+		if y.summary != nil && hasRange(y.summary) {
+			ys = append(ys, yScale(y.summary.Lo), yScale(y.summary.Hi))
+		}
+	}
+	return &pixelGrid{newPixelAxis(xs, width, logX), newPixelAxis(ys, height, logY)}
+}
+
+func newPixelAxis(vals []float64, pixels int, log bool) pixelAxis {
+	a := pixelAxis{log: log}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range vals {
+		v = a.coord(v)
+		if !math.IsInf(v, 0) && !math.IsNaN(v) {
+			lo, hi = min(lo, v), max(hi, v)
+		}
+	}
+	if lo < hi {
+		a.lo, a.scale = lo, float64(pixels)/(hi-lo)
+	}
+	return a
+}
+
+// coord returns the coordinate of v on a, before scaling to pixels.
+func (a pixelAxis) coord(v float64) float64 {
+	if a.log {
+		return math.Log(v)
+	}
+	return v
+}
+
+// pixel returns the pixel v lands on. Values that can't be drawn, such as
+// NaN, land on NaN, which is never equal to another pixel.
+func (a pixelAxis) pixel(v float64) float64 {
+	v = a.coord(v)
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return math.NaN()
+	}
+	return math.Floor((v - a.lo) * a.scale)
+}
+
+// thinner returns a function that reports whether to keep each point of a
+// series, given in order. A point spans from y1 to y2 at x, which are equal
+// unless the point is a range. It keeps a point unless it lands on the same
+// pixels as the last point it kept. If g is nil, it keeps every point.
+func (g *pixelGrid) thinner() func(x, y1, y2 float64) bool {
+	if g == nil {
+		return func(x, y1, y2 float64) bool { return true }
+	}
+	var last [3]float64
+	have := false
+	return func(x, y1, y2 float64) bool {
+		px := [3]float64{g.x.pixel(x), g.y.pixel(y1), g.y.pixel(y2)}
+		if have && px == last {
+			return false
+		}
+		last, have = px, true
+		return true
+	}
+}
@@ -0,0 +1,405 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/aclements/go-moremath/stats"
+	"golang.org/x/perf/benchmath"
+)
+
+func pointsToSample(pts []point, aes Aes) *benchmath.Sample {
+	ys := make([]float64, 0, 16)
+	for _, pt := range pts {
+		val := pt.Get(aes)
+		if val.kinds&kindContinuous == 0 {
+			panic("non-continuous " + aes.Name())
+		}
+		ys = append(ys, val.val)
+	}
+	return benchmath.NewSample(ys, &benchmath.DefaultThresholds)
+}
+
+// transformSummarize groups points that differ only in aes and produces a
+// single point for each group where aes is set to a summary of the group. Each
+// group is summarized using the distributional assumption returned by assume
+// for that group.
+//
+// aes must have kind kindContinuous.
+func transformSummarize(pts []point, aes Aes, confidence float64, assume func(point) benchmath.Assumption) ([]point, error) {
+	kinds := pointsKinds(pts, aes)
+	if kinds&kindSummary != 0 {
+		// Nothing to do if it's already summaries.
+		return pts, nil
+	}
+	if kinds&kindContinuous == 0 {
+		return nil, fmt.Errorf("transformSummarize: %s data must be numeric", aes.Name())
+	}
+
+	groups, keys := groupBy(pts, func(pt point) point {
+		pt.Set(aes, value{})
+		return pt
+	})
+
+	// Compute summary for each group. We put this in a slice to avoid
+	// allocating each Summary separately.
+	summaries := make([]benchmath.Summary, len(keys))
+	for i, k := range keys {
+		sample := pointsToSample(groups[k], aes)
+		a := assume(k)
+		summaries[i] = a.Summary(sample, confidence)
+		if a == benchmath.AssumeExact {
+			// An exact value has no confidence interval, even if
+			// the values differ.
+			summaries[i].Lo, summaries[i].Hi = summaries[i].Center, summaries[i].Center
+		}
+	}
+
+	// Construct new points.
+	out := make([]point, len(keys))
+	for i, k := range keys {
+		pt := groups[k][0]
+		// Keep it as a ratio if the input is.
+		kinds := kindContinuous | kindSummary | (kinds & kindRatio)
+		summary := &summaries[i]
+		v := value{kinds: kinds, val: summary.Center, summary: summary}
+		pt.Set(aes, v)
+		out[i] = pt
+	}
+
+	return out, nil
+}
+
+// TransformCompare compares the series of each facet against the first
+// series, which is the baseline. It replaces each group of values with the
+// ratio of its median to the median of the baseline's group at the same
+// point, and tests whether the difference is significant. The result of each
+// comparison is available from [Plot.Changes]. It can't be used with a
+// difference panel, whose series it replaces.
+func (p *Plot) TransformCompare() error {
+	if p.diffBase != "" {
+		return fmt.Errorf("compare transform cannot be used with a difference panel")
+	}
+	// TODO: It feels weird to pass AesColor here. Should this be up to what
+	// type of plot we're creating?
+	p.flushStreaming()
+	pts, cmps, err := transformCompare(p.points.all(), AesColor, p.dvAes, p.assumption)
+	if err != nil {
+		return err
+	}
+	p.points = newPointTable(pts)
+	p.layout = nil
+
+	p.changes = make([]Change, len(pts))
+	for i, pt := range pts {
+		c := &p.changes[i]
+		c.Point = p.pointLabels(pt)
+		c.Baseline = pt.Get(AesColor).denom.StringValues()
+		if p.unitField != nil {
+			c.Unit = pt.Get(p.unitAes).key.Get(p.unitField)
+			c.Better = p.units.GetBetter(c.Unit)
+		}
+		c.Ratio = pt.Get(p.dvAes).val
+		c.P = cmps[i].P
+		c.Significant = cmps[i].P < cmps[i].Alpha
+	}
+	return nil
+}
+
+// A Change describes how one point differs from its baseline, as computed by
+// [Plot.TransformCompare].
+type Change struct {
+	// Point gives the value of each aesthetic that identifies this point,
+	// indexed by aesthetic name.
+	Point map[string]string `json:"point"`
+	// Baseline is the value of the compared aesthetic for the baseline.
+	Baseline string `json:"baseline"`
+	// Unit is the unit of the measurements, and Better is +1 if higher
+	// values of Unit are better, -1 if lower values are better, or 0 if
+	// unknown.
+	Unit   string `json:"unit,omitempty"`
+	Better int    `json:"better"`
+	// Ratio is the ratio of this point's median to the baseline's.
+	Ratio float64 `json:"ratio"`
+	// P is the p-value of the hypothesis that the point and the baseline
+	// have the same distribution, and Significant reports whether it is
+	// below the usual threshold. In streaming mode, each point has already
+	// been summarized to a single value, so only changes in exact units can
+	// be significant.
+	P           float64 `json:"p"`
+	Significant bool    `json:"significant"`
+}
+
+// Regression reports whether c is a significant change in the worse
+// direction by more than fraction, such as 0.05 for 5%. Changes in units
+// with no known better direction are never regressions.
+func (c Change) Regression(fraction float64) bool {
+	if !c.Significant {
+		return false
+	}
+	switch c.Better {
+	case 1:
+		return c.Ratio < 1-fraction
+	case -1:
+		return c.Ratio > 1+fraction
+	}
+	return false
+}
+
+// Changes returns the changes computed by [Plot.TransformCompare], in the order
+// of the plot's points. It returns nil if that transform has not been applied.
+func (p *Plot) Changes() []Change {
+	return p.changes
+}
+
+// transformCompare groups points that differ only in aesCompare and aesRatio
+// and for each distinct value of aesCompare, treats the first value as a
+// baseline and normalizes the aesRatio of all other values of aesCompare
+// against that baseline. It also returns the comparison of each output point
+// against its baseline, using the assumption returned by assume.
+//
+// TODO: Right now, this collapses each group down to a median and produces only
+// continuous values for aes. It really ought to compute summary values.
+func transformCompare(pts []point, aesCompare, aesRatio Aes, assume func(point) benchmath.Assumption) ([]point, []benchmath.Comparison, error) {
+	if len(pts) == 0 {
+		return nil, nil, nil
+	}
+
+	if pointsKinds(pts, aesRatio)&kindContinuous == 0 {
+		return nil, nil, fmt.Errorf("transformCompare: %s data must be numeric", aesRatio.Name())
+	}
+
+	// We want to walk through things in order of aesCompare. Sort it up-front
+	// and the grouping operations will keep it sorted.
+	slices.SortFunc(pts, func(a, b point) int {
+		return a.Get(aesCompare).compare(b.Get(aesCompare))
+	})
+	// Everything shares the same comparison baseline.
+	cmpBase := pts[0].Get(aesCompare)
+
+	groups, keys := groupBy(pts, func(pt point) point {
+		pt.Set(aesCompare, value{})
+		pt.Set(aesRatio, value{})
+		return pt
+	})
+
+	median := func(pts []point) float64 {
+		// TODO: This does a ton of wasted computation.
+		return benchmath.AssumeNothing.Summary(pointsToSample(pts, aesRatio), 1).Center
+	}
+
+	var out []point
+	var cmps []benchmath.Comparison
+	for _, k := range keys {
+		group := groups[k]
+
+		// If this group doesn't have a baseline, skip it entirely.
+		if group[0].Get(aesCompare) != cmpBase {
+			continue
+		}
+
+		// Group by value of aesCompare.
+		cmpGroups, cmpKeys := groupBy(group, func(pt point) value {
+			return pt.Get(aesCompare)
+		})
+
+		// Create a point for each value of aesCompare, normalized to the first.
+		baseline := median(cmpGroups[cmpBase])
+		baseSample := pointsToSample(cmpGroups[cmpBase], aesRatio)
+		for _, ck := range cmpKeys[1:] {
+			ratio := median(cmpGroups[ck]) / baseline
+			p0 := cmpGroups[ck][0]
+			cmps = append(cmps, assume(p0).Compare(baseSample, pointsToSample(cmpGroups[ck], aesRatio)))
+			ck.kinds |= kindRatio
+			ck.denom = cmpBase.key
+			p0.Set(aesCompare, ck)
+			p0.Set(aesRatio, value{kinds: kindContinuous | kindRatio, val: ratio})
+			out = append(out, p0)
+		}
+	}
+
+	return out, cmps, nil
+}
+
+// A variability is a statistic describing the spread of a sample.
+type variability int
+
+const (
+	varNone variability = iota
+	varStddev
+	varCoV
+)
+
+func (v variability) String() string {
+	switch v {
+	case varStddev:
+		return "stddev"
+	case varCoV:
+		return "CoV"
+	}
+	return ""
+}
+
+// TransformStddev replaces each group of values with its standard deviation.
+func (p *Plot) TransformStddev() error {
+	return p.transformVariability(varStddev)
+}
+
+// TransformCoV replaces each group of values with its coefficient of
+// variation, the standard deviation divided by the mean.
+func (p *Plot) TransformCoV() error {
+	return p.transformVariability(varCoV)
+}
+
+func (p *Plot) transformVariability(v variability) error {
+	if p.dvAes == aesNone {
+		return fmt.Errorf("%s requires a dimension showing .value", v)
+	}
+	if p.variability != varNone {
+		return fmt.Errorf("cannot compute %s of %s", v, p.variability)
+	}
+	p.flushStreaming()
+	pts, err := transformVariability(p.points.all(), p.dvAes, v)
+	if err != nil {
+		return err
+	}
+	p.points = newPointTable(pts)
+	p.layout = nil
+	p.variability = v
+	return nil
+}
+
+// transformVariability groups points that differ only in aes and produces a
+// single point for each group where aes is set to the variability of the group.
+//
+// aes must have kind kindContinuous.
+func transformVariability(pts []point, aes Aes, v variability) ([]point, error) {
+	kinds := pointsKinds(pts, aes)
+	if kinds&kindContinuous == 0 {
+		return nil, fmt.Errorf("transformVariability: %s data must be numeric", aes.Name())
+	}
+	if kinds&(kindSummary|kindRatio) != 0 {
+		return nil, fmt.Errorf("cannot compute %s of summarized or compared data", v)
+	}
+
+	groups, keys := groupBy(pts, func(pt point) point {
+		pt.Set(aes, value{})
+		return pt
+	})
+
+	out := make([]point, 0, len(keys))
+	xs := make([]float64, 0, 16)
+	for _, k := range keys {
+		xs = xs[:0]
+		for _, pt := range groups[k] {
+			xs = append(xs, pt.Get(aes).val)
+		}
+		if len(xs) < 2 {
+			// Variability is undefined for a single sample.
+			continue
+		}
+		val := stats.StdDev(xs)
+		if v == varCoV {
+			val /= stats.Mean(xs)
+		}
+		pt := groups[k][0]
+		pt.Set(aes, value{kinds: kindContinuous, val: val})
+		out = append(out, pt)
+	}
+
+	return out, nil
+}
+
+// A Noise describes the run-to-run variability of a single series, as computed
+// by [Plot.TransformNoisiest].
+type Noise struct {
+	// Series gives the value of each aesthetic that identifies this series,
+	// indexed by aesthetic name.
+	Series map[string]string `json:"series"`
+	// Unit is the unit of the measurements in this series.
+	Unit string `json:"unit,omitempty"`
+	// MeanCoV and MaxCoV are the mean and maximum coefficient of variation
+	// across the points in this series.
+	MeanCoV float64 `json:"meanCoV"`
+	MaxCoV  float64 `json:"maxCoV"`
+	// Points is the number of points in this series.
+	Points int `json:"points"`
+}
+
+// TransformNoisiest replaces each group of values with its coefficient of
+// variation, like [Plot.TransformCoV], and then keeps only the series with the
+// highest mean coefficient of variation. The number of series to keep is set
+// by [Config.SetNoisiest]. Series with a point whose mean is zero have no
+// coefficient of variation to rank them by, so they're dropped. The ranking of
+// the kept series is available from [Plot.Noise].
+func (p *Plot) TransformNoisiest() error {
+	if err := p.transformVariability(varCoV); err != nil {
+		return err
+	}
+
+	// Group into series. A series is everything except X and the DV.
+	groups, keys := groupBy(p.points.all(), func(pt point) point {
+		pt.Set(AesX, value{})
+		pt.Set(p.dvAes, value{})
+		return pt
+	})
+
+	type ranked struct {
+		noise Noise
+		pts   []point
+	}
+	series := make([]ranked, 0, len(keys))
+	for _, k := range keys {
+		pts := groups[k]
+		r := ranked{pts: pts}
+		r.noise.Series = p.pointLabels(k)
+		delete(r.noise.Series, AesX.Name())
+		if units := p.pointsUnits(pts); len(units) == 1 {
+			r.noise.Unit = units[0]
+		}
+		for _, pt := range pts {
+			cov := pt.Get(p.dvAes).val
+			r.noise.MeanCoV += cov
+			r.noise.MaxCoV = max(r.noise.MaxCoV, cov)
+		}
+		r.noise.MeanCoV /= float64(len(pts))
+		r.noise.Points = len(pts)
+		if math.IsNaN(r.noise.MeanCoV) || math.IsInf(r.noise.MeanCoV, 0) {
+			continue
+		}
+		series = append(series, r)
+	}
+
+	// Rank by mean CoV, breaking ties by the original series order.
+	slices.SortStableFunc(series, func(a, b ranked) int {
+		return -cmp.Compare(a.noise.MeanCoV, b.noise.MeanCoV)
+	})
+	if p.noisiest > 0 && len(series) > p.noisiest {
+		series = series[:p.noisiest]
+	}
+
+	var out []point
+	// Even if no series are kept, the ranking is non-nil, since the
+	// transform was applied.
+	p.noise = make([]Noise, 0, len(series))
+	for _, r := range series {
+		out = append(out, r.pts...)
+		p.noise = append(p.noise, r.noise)
+	}
+	p.points = newPointTable(out)
+	p.layout = nil
+	return nil
+}
+
+// Noise returns the ranking of series computed by [Plot.TransformNoisiest],
+// from noisiest to least noisy. It returns nil if that transform has not been
+// applied.
+func (p *Plot) Noise() []Noise {
+	return p.noise
+}
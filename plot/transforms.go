@@ -0,0 +1,299 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plot
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/perf/benchfmt"
+	"golang.org/x/perf/benchproc"
+)
+
+// A Transform rewrites the points of a Plot after all of its measurements have
+// been added.
+//
+// Transforms apply in order, each to the points produced by the previous one.
+// A transform that can't follow an earlier one, such as computing the
+// coefficient of variation of a comparison's ratios, returns an error.
+type Transform interface {
+	Apply(p *Plot) error
+}
+
+// A TransformFunc is a function that implements [Transform].
+type TransformFunc func(p *Plot) error
+
+func (f TransformFunc) Apply(p *Plot) error {
+	return f(p)
+}
+
+// A TransformParser returns the Transform for the argument of a registered
+// transform, which is the text after the ":" in "name:arg", or "" if there is
+// none.
+type TransformParser func(arg string) (Transform, error)
+
+type transformReg struct {
+	doc   string
+	parse TransformParser
+}
+
+var transformRegs struct {
+	sync.Mutex
+	m map[string]transformReg
+}
+
+// RegisterTransform makes a transform available to [ParseTransform] under
+// name, with a one-line description doc. It panics if name is already
+// registered or contains a ":" or ",".
+func RegisterTransform(name, doc string, parse TransformParser) {
+	transformRegs.Lock()
+	defer transformRegs.Unlock()
+	if strings.ContainsAny(name, ":,") {
+		panic("bad transform name " + strconv.Quote(name))
+	}
+	if _, ok := transformRegs.m[name]; ok {
+		panic("transform " + name + " already registered")
+	}
+	if transformRegs.m == nil {
+		transformRegs.m = make(map[string]transformReg)
+	}
+	transformRegs.m[name] = transformReg{doc, parse}
+}
+
+// TransformNames returns the names of the registered transforms, in sorted
+// order.
+func TransformNames() []string {
+	transformRegs.Lock()
+	defer transformRegs.Unlock()
+	names := make([]string, 0, len(transformRegs.m))
+	for name := range transformRegs.m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// TransformDoc returns the description of the registered transform name, or
+// "" if there is no such transform.
+func TransformDoc(name string) string {
+	transformRegs.Lock()
+	defer transformRegs.Unlock()
+	return transformRegs.m[name].doc
+}
+
+// ParseTransform returns the registered transform for s, which is a
+// transform name, optionally followed by ":" and an argument.
+func ParseTransform(s string) (Transform, error) {
+	name, arg, _ := strings.Cut(s, ":")
+	transformRegs.Lock()
+	reg, ok := transformRegs.m[name]
+	transformRegs.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown transform %s", name)
+	}
+	t, err := reg.parse(arg)
+	if err != nil {
+		return nil, fmt.Errorf("transform %s: %w", name, err)
+	}
+	return t, nil
+}
+
+// noArg returns a TransformParser for a transform that takes no argument.
+func noArg(t TransformFunc) TransformParser {
+	return func(arg string) (Transform, error) {
+		if arg != "" {
+			return nil, fmt.Errorf("unexpected argument %q", arg)
+		}
+		return t, nil
+	}
+}
+
+func init() {
+	RegisterTransform("compare", "normalize each value against the first value at the same X",
+		noArg((*Plot).TransformCompare))
+	RegisterTransform("stddev", "replace each group of values with its standard deviation",
+		noArg((*Plot).TransformStddev))
+	RegisterTransform("cov", "replace each group of values with its coefficient of variation",
+		noArg((*Plot).TransformCoV))
+	RegisterTransform("noisiest", "replace each group of values with its coefficient of variation and keep only the noisiest series (noisiest:N keeps N)",
+		func(arg string) (Transform, error) {
+			if arg == "" {
+				return TransformFunc((*Plot).TransformNoisiest), nil
+			}
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("bad number of series %q", arg)
+			}
+			return TransformFunc(func(p *Plot) error {
+				p.noisiest = n
+				return p.TransformNoisiest()
+			}), nil
+		})
+}
+
+// Apply applies each of ts to p in order.
+func (p *Plot) Apply(ts ...Transform) error {
+	for _, t := range ts {
+		if err := t.Apply(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A Point is a single point of a Plot, for use by transforms that are not
+// built in. It gives the value of each aesthetic. The measured value is
+// numeric and the other aesthetics may be numeric or not.
+type Point struct {
+	pt point
+	p  *Plot
+}
+
+// Points returns the current points of p. Like [Plot.Shape], this reflects
+// any transforms that have been applied to p. Before any transforms, there is
+// one point per measurement.
+func (p *Plot) Points() []Point {
+	p.flushStreaming()
+	pts := make([]Point, p.points.len())
+	for i := range pts {
+		pts[i] = Point{p.points.get(i), p}
+	}
+	return pts
+}
+
+// SetPoints replaces the points of p with pts, which must have come from
+// [Plot.Points] of p.
+func (p *Plot) SetPoints(pts []Point) {
+	out := make([]point, len(pts))
+	for i, pt := range pts {
+		if pt.p != p {
+			panic("point is from a different Plot")
+		}
+		out[i] = pt.pt
+	}
+	p.points = newPointTable(out)
+	p.layout = nil
+}
+
+// Value returns the measured value of pt.
+func (pt Point) Value() float64 {
+	return pt.pt.Get(pt.p.dvAes).val
+}
+
+// WithValue returns a copy of pt with its measured value replaced by v. If pt
+// was a summary, such as from streaming, the copy is a single measurement.
+func (pt Point) WithValue(v float64) Point {
+	kinds := kindContinuous | pt.pt.Get(pt.p.dvAes).kinds&kindRatio
+	pt.pt.Set(pt.p.dvAes, value{kinds: kinds, val: v})
+	return pt
+}
+
+// Unit returns the unit of pt's measured value.
+func (pt Point) Unit() string {
+	return pt.pt.Get(pt.p.unitAes).key.Get(pt.p.unitField)
+}
+
+// Label returns the value of aesthetic aes of pt as a string, such as
+// "linux" or "amd64 vs arm64".
+func (pt Point) Label(aes Aes) string {
+	return pt.pt.Get(aes).StringValues()
+}
+
+// Float returns the value of aesthetic aes of pt as a number, or false if it
+// isn't numeric.
+func (pt Point) Float(aes Aes) (float64, bool) {
+	v := pt.pt.Get(aes)
+	return v.val, v.kinds&kindContinuous != 0
+}
+
+// Key returns the projected fields of aesthetic aes of pt. It is the zero Key
+// for the aesthetic showing the measured value.
+func (pt Point) Key(aes Aes) benchproc.Key {
+	return pt.pt.Get(aes).key
+}
+
+// Matches reports whether p projects rec to pt, other than its measured
+// value, so that pt summarizes measurements from rec if rec was added to p.
+// For a comparison, rec matches if it projects to either of the compared
+// points.
+func (pt Point) Matches(rec *benchfmt.Result) bool {
+	for aes := range aesMax {
+		proj := pt.p.aes.Get(aes)
+		if aes == pt.p.dvAes || proj.iv == nil {
+			continue
+		}
+		v := pt.pt.Get(aes)
+		if !slices.ContainsFunc(proj.project(rec), func(rv value) bool {
+			return rv.key == v.key || v.kinds&kindRatio != 0 && rv.key == v.denom
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// Residue returns the values that each field of the residue took among the
+// measurements summarized into pt, indexed by field name, with the values of
+// each field in sorted order. It returns nil if p's Config has no residue
+// or pt didn't come from added results, such as after a comparison.
+func (pt Point) Residue() map[string][]string {
+	if pt.p.residue == nil {
+		return nil
+	}
+	key := pt.pt
+	if pt.p.dvAes != aesNone {
+		key.Set(pt.p.dvAes, value{})
+	}
+	set := pt.p.residues[key]
+	if len(set) == 0 {
+		return nil
+	}
+	out := make(map[string][]string)
+	for _, f := range pt.p.residue.FlattenedFields() {
+		if f.IsTuple {
+			continue
+		}
+		var vals []string
+		for rk := range set {
+			if v := rk.Get(f); !slices.Contains(vals, v) {
+				vals = append(vals, v)
+			}
+		}
+		slices.Sort(vals)
+		out[f.Name] = vals
+	}
+	return out
+}
+
+// Fields returns the value of each projected field of pt, other than the
+// measured value, indexed by field name, such as "goos" or "/size". For a
+// comparison, a field that differs between the compared points has a value
+// such as "amd64 vs arm64".
+func (pt Point) Fields() map[string]string {
+	fields := make(map[string]string)
+	for aes := range aesMax {
+		proj := pt.p.aes.Get(aes)
+		if aes == pt.p.dvAes || proj.iv == nil {
+			continue
+		}
+		v := pt.pt.Get(aes)
+		for _, f := range proj.iv.FlattenedFields() {
+			if f.IsTuple {
+				continue
+			}
+			val := v.key.Get(f)
+			if v.kinds&kindRatio != 0 && v.kinds&kindDiscrete != 0 {
+				if denom := v.denom.Get(f); denom != val {
+					val += " vs " + denom
+				}
+			}
+			fields[f.Name] = val
+		}
+	}
+	return fields
+}
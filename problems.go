@@ -0,0 +1,104 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// maxSummaryLines is the maximum number of distinct problems printed by
+// summarizeProblems.
+const maxSummaryLines = 20
+
+// maxSummaryPositions is the maximum number of line numbers printed for each
+// distinct problem.
+const maxSummaryPositions = 5
+
+// summarizeProblems prints a summary of problems, which are the warnings
+// about single records, to w, grouping problems by file and then by message.
+func summarizeProblems(w io.Writer, problems []warning) {
+	if len(problems) == 0 {
+		return
+	}
+
+	type group struct {
+		file, msg string
+		lines     []int
+	}
+	var groups []*group
+	index := make(map[[2]string]*group)
+	files := make(map[string]int)
+	for _, p := range problems {
+		k := [2]string{p.File, p.Kind + ": " + p.Msg}
+		g := index[k]
+		if g == nil {
+			g = &group{file: p.File, msg: k[1]}
+			index[k] = g
+			groups = append(groups, g)
+		}
+		g.lines = append(g.lines, p.Line)
+		files[p.File]++
+	}
+	// Keep groups from the same file together, in order of first
+	// appearance.
+	order := make(map[string]int)
+	for _, g := range groups {
+		if _, ok := order[g.file]; !ok {
+			order[g.file] = len(order)
+		}
+	}
+	slices.SortStableFunc(groups, func(a, b *group) int { return order[a.file] - order[b.file] })
+
+	fmt.Fprintf(w, "%d input errors in %d files:\n", len(problems), len(files))
+	file := ""
+	for i, g := range groups {
+		if i == maxSummaryLines {
+			fmt.Fprintf(w, "  ... and %d more kinds of errors\n", len(groups)-i)
+			break
+		}
+		if g.file != file {
+			file = g.file
+			fmt.Fprintf(w, "%s: %d\n", file, files[file])
+		}
+		var pos strings.Builder
+		for j, line := range g.lines {
+			if j == maxSummaryPositions {
+				pos.WriteString(", ...")
+				break
+			}
+			if j > 0 {
+				pos.WriteString(", ")
+			}
+			fmt.Fprint(&pos, line)
+		}
+		lines := "line"
+		if len(g.lines) > 1 {
+			lines = "lines"
+		}
+		fmt.Fprintf(w, "  %d× %s (%s %s)\n", len(g.lines), g.msg, lines, pos.String())
+	}
+}
+
+// writeErrorLog writes problems to path, one JSON object per line, in the
+// same form as JSON warnings.
+func writeErrorLog(path string, problems []warning) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, p := range problems {
+		if err := enc.Encode(p); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// progressInterval is how often -progress reports.
+const progressInterval = 2 * time.Second
+
+// A progressSource is a recordSource that reports how many records have been
+// read every progressInterval, and once more when the source is exhausted.
+type progressSource struct {
+	recordSource
+	w io.Writer
+
+	start, next time.Time
+	n           int
+	done        bool
+}
+
+func newProgressSource(src recordSource, w io.Writer) *progressSource {
+	now := time.Now()
+	return &progressSource{recordSource: src, w: w, start: now, next: now.Add(progressInterval)}
+}
+
+func (s *progressSource) Scan() bool {
+	if !s.recordSource.Scan() {
+		if !s.done {
+			s.done = true
+			s.report("finished: ")
+		}
+		return false
+	}
+	s.n++
+	// Checking the time is cheap, but not free.
+	if s.n%1024 == 0 {
+		if now := time.Now(); now.After(s.next) {
+			s.report("")
+			s.next = now.Add(progressInterval)
+		}
+	}
+	return true
+}
+
+func (s *progressSource) report(prefix string) {
+	elapsed := time.Since(s.start)
+	fmt.Fprintf(s.w, "%sread %d records", prefix, s.n)
+	if p, ok := s.recordSource.(interface{ Progress() (opened, total int) }); ok {
+		opened, total := p.Progress()
+		fmt.Fprintf(s.w, " from %d of %d inputs", opened, total)
+	}
+	fmt.Fprintf(s.w, " in %s (%.0f records/s)\n", elapsed.Round(100*time.Millisecond), float64(s.n)/elapsed.Seconds())
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+const replHelp = `Commands:
+  flag=value  set a plot flag, such as x=.fullname or color=goos
+  flag=       reset a plot flag to its default
+  show        print the current plot flags
+  render      plot the data with the current plot flags
+  help        print this message
+  quit        exit
+Plot flags: %s
+`
+
+// replCommands lists the commands other than flag assignments.
+var replCommands = []string{"show", "render", "help", "quit", "exit"}
+
+// repl reads commands from r and writes prompts and responses to w. The
+// commands set plot flags in flags and call render to plot the data with the
+// current flags. Errors from commands are reported to w, but do not stop the
+// loop. repl returns when r reaches EOF or on a "quit" command.
+func repl(r io.Reader, w io.Writer, flags *flag.FlagSet, render func() error) error {
+	fmt.Fprintf(w, "type help for a list of commands\n")
+	sc := bufio.NewScanner(r)
+	for {
+		fmt.Fprintf(w, "> ")
+		if !sc.Scan() {
+			fmt.Fprintf(w, "\n")
+			return sc.Err()
+		}
+		line := strings.TrimSpace(sc.Text())
+		if name, val, ok := strings.Cut(line, "="); ok {
+			name = strings.TrimPrefix(strings.TrimSpace(name), "-")
+			val = strings.TrimSpace(val)
+			if !slices.Contains(plotFlags, name) {
+				fmt.Fprintf(w, "%s is not a plot flag%s\n", name, didYouMean(name, plotFlags))
+				continue
+			}
+			if val == "" {
+				val = flags.Lookup(name).DefValue
+			}
+			if err := flags.Set(name, val); err != nil {
+				fmt.Fprintf(w, "bad %s: %s\n", name, err)
+			}
+			continue
+		}
+		switch line {
+		case "":
+		case "show":
+			for _, name := range plotFlags {
+				fmt.Fprintf(w, "%s=%s\n", name, flags.Lookup(name).Value)
+			}
+		case "render":
+			if err := render(); err != nil {
+				fmt.Fprintf(w, "%s\n", err)
+			}
+		case "help":
+			fmt.Fprintf(w, replHelp, strings.Join(plotFlags, ", "))
+		case "quit", "exit":
+			return nil
+		default:
+			if suggest := didYouMean(line, replCommands); suggest != "" {
+				fmt.Fprintf(w, "unknown command %s%s\n", line, suggest)
+				continue
+			}
+			fmt.Fprintf(w, "unknown command %s; type help for a list of commands\n", line)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/aclements/benchplot/internal/input"
+	"golang.org/x/perf/benchfmt"
+	"golang.org/x/perf/benchunit"
+)
+
+// An extract derives new configuration keys from the named capture groups of
+// a regexp matched against some part of each result.
+type extract struct {
+	src string // "name" for the full name, or a configuration key
+	re  *regexp.Regexp
+}
+
+// parseExtract parses an -extract option of the form "src:/regexp/".
+func parseExtract(opt string) (extract, error) {
+	src, reStr, ok := strings.Cut(opt, ":")
+	if !ok || len(reStr) < 2 || reStr[0] != '/' || reStr[len(reStr)-1] != '/' {
+		return extract{}, fmt.Errorf("bad -extract %q: expected source:/regexp/", opt)
+	}
+	re, err := regexp.Compile(reStr[1 : len(reStr)-1])
+	if err != nil {
+		return extract{}, fmt.Errorf("bad -extract %q: %w", opt, err)
+	}
+	named := false
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			named = true
+		}
+	}
+	if !named {
+		return extract{}, fmt.Errorf("bad -extract %q: regexp has no named groups", opt)
+	}
+	return extract{src, re}, nil
+}
+
+// apply adds a configuration key to res for each named group of e that
+// matches.
+func (e extract) apply(res *benchfmt.Result) {
+	var s []byte
+	if e.src == "name" {
+		s = res.Name.Full()
+	} else if pos, ok := res.ConfigIndex(e.src); ok {
+		s = res.Config[pos].Value
+	} else {
+		return
+	}
+	m := e.re.FindSubmatch(s)
+	if m == nil {
+		return
+	}
+	for i, name := range e.re.SubexpNames() {
+		if name != "" && m[i] != nil {
+			input.SetFileConfig(res, name, string(m[i]))
+		}
+	}
+}
+
+// A valueMap rewrites the values of a key.
+type valueMap struct {
+	key string // Configuration key, or "/key" for a name key
+	m   map[string]string
+}
+
+// parseValueMap parses a -map option of the form "key: old=new, old=new".
+func parseValueMap(opt string) (valueMap, error) {
+	key, pairs, ok := strings.Cut(opt, ":")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return valueMap{}, fmt.Errorf("bad -map %q: expected key: old=new, ...", opt)
+	}
+	vm := valueMap{key, make(map[string]string)}
+	for _, pair := range strings.Split(pairs, ",") {
+		old, new, ok := strings.Cut(pair, "=")
+		if !ok {
+			return valueMap{}, fmt.Errorf("bad -map %q: expected key: old=new, ...", opt)
+		}
+		vm.m[strings.TrimSpace(old)] = strings.TrimSpace(new)
+	}
+	return vm, nil
+}
+
+// apply rewrites the value of vm's key in res.
+func (vm valueMap) apply(res *benchfmt.Result) {
+	if nameKey, ok := strings.CutPrefix(vm.key, "/"); ok {
+		prefix := "/" + nameKey + "="
+		base, parts := res.Name.Parts()
+		changed := false
+		for i, part := range parts {
+			if val, ok := strings.CutPrefix(string(part), prefix); ok {
+				if new, ok := vm.m[val]; ok {
+					parts[i] = []byte(prefix + new)
+					changed = true
+				}
+			}
+		}
+		if changed {
+			name := append([]byte(nil), base...)
+			for _, part := range parts {
+				name = append(name, part...)
+			}
+			res.Name = name
+		}
+		return
+	}
+
+	pos, ok := res.ConfigIndex(vm.key)
+	if !ok {
+		return
+	}
+	if new, ok := vm.m[string(res.Config[pos].Value)]; ok {
+		res.Config[pos].Value = append(res.Config[pos].Value[:0], new...)
+	}
+}
+
+// unitScales gives the base unit and scale factor for scaled units that
+// benchfmt does not tidy. benchfmt only tidies "ns" and "MB", so inputs that
+// mix, say, "ms/op" and "ns/op" would otherwise produce disjoint series.
+var unitScales = map[string]struct {
+	base   string
+	factor float64
+}{
+	"s": {"sec", 1}, "ms": {"sec", 1e-3}, "us": {"sec", 1e-6}, "µs": {"sec", 1e-6},
+	"KB": {"B", 1e3}, "GB": {"B", 1e9}, "TB": {"B", 1e12},
+	"KiB": {"B", 1 << 10}, "MiB": {"B", 1 << 20}, "GiB": {"B", 1 << 30}, "TiB": {"B", 1 << 40},
+}
+
+// normalizeUnit returns the canonical unit for unit and the factor to convert
+// values in unit to the canonical unit. Only the numerator of unit is scaled,
+// so "ms/op" becomes "sec/op" with factor 1e-3, and "cpu-ms/op" becomes
+// "cpu-sec/op". Ratios such as "ratio-ms/op" are dimensionless, so they are
+// left alone.
+func normalizeUnit(unit string) (string, float64) {
+	num, denom, hasDenom := strings.Cut(unit, "/")
+	factor := 1.0
+	changed := false
+	toks := strings.Split(num, "-")
+	if slices.Contains(toks, "ratio") {
+		return unit, 1
+	}
+	for i, tok := range toks {
+		if s, ok := unitScales[tok]; ok {
+			toks[i] = s.base
+			factor *= s.factor
+			changed = true
+		}
+	}
+	if !changed {
+		return unit, 1
+	}
+	unit = strings.Join(toks, "-")
+	if hasDenom {
+		unit += "/" + denom
+	}
+	return unit, factor
+}
+
+// normalizeValues converts each value in res to its canonical unit. It leaves
+// the original value and unit in OrigValue and OrigUnit.
+func normalizeValues(res *benchfmt.Result) {
+	for i := range res.Values {
+		v := &res.Values[i]
+		unit, factor := normalizeUnit(v.Unit)
+		if unit == v.Unit {
+			continue
+		}
+		if v.OrigUnit == "" {
+			v.OrigValue, v.OrigUnit = v.Value, v.Unit
+		}
+		v.Value, v.Unit = v.Value*factor, unit
+	}
+}
+
+// normalizeUnitMetadata returns units with metadata for scaled units also
+// recorded under their canonical units, to match normalizeValues.
+func normalizeUnitMetadata(units benchfmt.UnitMetadataMap) benchfmt.UnitMetadataMap {
+	out := make(benchfmt.UnitMetadataMap, len(units))
+	for k, v := range units {
+		out[k] = v
+	}
+	for k, v := range units {
+		unit, _ := normalizeUnit(k.Unit)
+		k.Unit = unit
+		if _, ok := out[k]; !ok {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// parseBetter parses a -better option of the form "unit=higher,unit=lower".
+func parseBetter(opt string) (map[string]string, error) {
+	if opt == "" {
+		return nil, nil
+	}
+	better := make(map[string]string)
+	for _, kv := range strings.Split(opt, ",") {
+		unit, dir, ok := strings.Cut(kv, "=")
+		unit, dir = strings.TrimSpace(unit), strings.TrimSpace(dir)
+		if !ok || unit == "" || (dir != "higher" && dir != "lower") {
+			return nil, fmt.Errorf("bad -better %q: expected unit=higher or unit=lower, ...", opt)
+		}
+		better[unit] = dir
+	}
+	return better, nil
+}
+
+// overrideBetter sets the "better" metadata of units from better, which maps
+// from unit to "higher" or "lower". Like the metadata itself, an override
+// applies to a unit in any scale.
+func overrideBetter(units benchfmt.UnitMetadataMap, better map[string]string) {
+	for unit, dir := range better {
+		_, tidy := benchunit.Tidy(1, unit)
+		canon, _ := normalizeUnit(tidy)
+		for _, u := range []string{tidy, canon} {
+			k := benchfmt.UnitMetadataKey{Unit: u, Key: "better"}
+			units[k] = &benchfmt.UnitMetadata{UnitMetadataKey: k, OrigUnit: unit, Value: dir}
+		}
+	}
+}
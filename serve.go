@@ -0,0 +1,780 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aclements/benchplot/plot"
+	"golang.org/x/net/websocket"
+	"golang.org/x/perf/benchfmt"
+	"golang.org/x/perf/benchproc"
+)
+
+// viewFlags lists the flags that the serve UI can change. "filter" further
+// filters the results that passed -filter, and the rest are plot flags.
+var viewFlags = []string{"x", "y", "color", "row", "col", "filter", "transform"}
+
+// A view maps flags in urlFlags to their values.
+type view map[string]string
+
+// A serveData is the set of results plotted by the server. It changes when
+// the inputs are read again or results are posted to the server.
+type serveData struct {
+	// mu is held while reading, changing, or plotting the results.
+	mu sync.Mutex
+
+	inputs, posted []*benchfmt.Result
+	units          benchfmt.UnitMetadataMap
+
+	// version counts the changes to the results. changed is closed and
+	// replaced on each change.
+	version int
+	changed chan struct{}
+}
+
+func newServeData(recs []*benchfmt.Result, units benchfmt.UnitMetadataMap) *serveData {
+	return &serveData{inputs: recs, units: units, changed: make(chan struct{})}
+}
+
+// results returns the current results and their unit metadata. The caller
+// must hold d.mu.
+func (d *serveData) results() ([]*benchfmt.Result, benchfmt.UnitMetadataMap) {
+	if len(d.posted) == 0 {
+		return d.inputs, d.units
+	}
+	return slices.Concat(d.inputs, d.posted), d.units
+}
+
+// setInputs replaces the results read from the inputs, keeping any posted
+// results. The caller must hold d.mu.
+func (d *serveData) setInputs(recs []*benchfmt.Result, units benchfmt.UnitMetadataMap) {
+	d.inputs = recs
+	d.mergeUnits(units)
+	d.notify()
+}
+
+// post adds results posted to the server. The caller must hold d.mu.
+func (d *serveData) post(recs []*benchfmt.Result, units benchfmt.UnitMetadataMap) {
+	d.posted = append(d.posted, recs...)
+	d.mergeUnits(units)
+	d.notify()
+}
+
+func (d *serveData) mergeUnits(units benchfmt.UnitMetadataMap) {
+	if d.units == nil {
+		d.units = make(benchfmt.UnitMetadataMap)
+	}
+	for k, v := range units {
+		d.units[k] = v
+	}
+}
+
+func (d *serveData) notify() {
+	d.version++
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
+
+// A server serves a web UI for exploring a plot. The UI has a menu for each
+// aesthetic and the transforms, and a filter, and changing them re-renders
+// the plot. Open pages update their plot whenever the data changes. Hovering
+// over a point of the plot shows its fields, residue, and measurements, and
+// clicking it lists the results it summarizes.
+type server struct {
+	data *serveData
+
+	// defaults gives the value of each of viewFlags when a request doesn't
+	// set it.
+	defaults view
+
+	// newPlot returns the plot of data for view v, with its transforms
+	// applied. The caller holds data.mu, so newPlot is never called
+	// concurrently.
+	newPlot func(v view) (*plot.Plot, error)
+
+	// read reads results in the Go benchmark format that were posted to
+	// the server. The caller holds data.mu.
+	read func(r io.Reader) ([]*benchfmt.Result, benchfmt.UnitMetadataMap, error)
+
+	// views is the set of saved views, or nil if views can't be saved.
+	views *savedViews
+}
+
+// urlFlags lists the flags that may be set by the query parameters of a
+// request: viewFlags, and the other plotFlags except serverFlags.
+var urlFlags = append(slices.Clip(viewFlags), slices.DeleteFunc(slices.Clone(plotFlags), func(name string) bool {
+	return slices.Contains(viewFlags, name) || slices.Contains(serverFlags, name)
+})...)
+
+// serverFlags lists the plotFlags that requests can't set, because they name
+// files or write output besides the plot, or, like -watermark, are up to
+// whoever runs the server.
+var serverFlags = []string{"watermark", "noise-report", "table", "table-format", "o"}
+
+// view returns the view requested by query parameters q. Flags in viewFlags
+// that q doesn't set have their default values, and other flags in urlFlags
+// are only set if q sets them. Any parameters other than those in urlFlags
+// and extra are an error.
+func (s *server) view(q url.Values, extra ...string) (view, error) {
+	for _, name := range sortedKeys(q) {
+		if !slices.Contains(urlFlags, name) && !slices.Contains(extra, name) {
+			return nil, fmt.Errorf("unknown parameter %s%s", name, didYouMean(name, urlFlags))
+		}
+	}
+	v := make(view)
+	for _, name := range urlFlags {
+		if q.Has(name) {
+			v[name] = q.Get(name)
+		} else if slices.Contains(viewFlags, name) {
+			v[name] = s.defaults[name]
+		}
+	}
+	return v, nil
+}
+
+// query returns the query parameters that request view v.
+func (v view) query() url.Values {
+	q := make(url.Values)
+	for name, val := range v {
+		q.Set(name, val)
+	}
+	return q
+}
+
+// A servePage is the data for serveTemplate.
+type servePage struct {
+	Fields  []serveField
+	Filter  string
+	Hidden  []serveField // Flags set by the URL that have no menu
+	Image   template.URL // Data URL of the plot
+	Map     plotMap      // Of the plot
+	Err     string
+	Version int // Of the data that was plotted
+
+	// View gives every flag of the view, for saving it. Views lists the
+	// names of the saved views. CanSave is false if views can't be saved.
+	View    []serveField
+	Views   []string
+	CanSave bool
+}
+
+// A serveField is a drop-down menu in the serve UI.
+type serveField struct {
+	Name, Value string
+	Options     []string
+}
+
+// field returns the menu for flag name in view v, offering options. If the
+// current value is not one of options, such as a projection of several
+// fields, the menu offers it too.
+func field(v view, name string, options []string) serveField {
+	if !slices.Contains(options, v[name]) {
+		options = append(slices.Clip(options), v[name])
+	}
+	return serveField{name, v[name], options}
+}
+
+// serveKeys returns the projections offered for each aesthetic in plotting
+// recs.
+func serveKeys(recs []*benchfmt.Result) []string {
+	kc := make(keyCounts)
+	for _, rec := range recs {
+		for _, cfg := range rec.Config {
+			kc.add(cfg.Key, nil)
+		}
+		inspectName(kc, rec.Name)
+	}
+	keys := []string{"", ".fullname", ".name", ".unit", ".value", ".residue"}
+	for _, key := range sortedKeys(kc) {
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+var serveTemplate = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>benchplot</title>
+<style>
+body { font-family: sans-serif; }
+form { margin-bottom: 1em; }
+label { margin-right: 1em; white-space: nowrap; }
+#plot { cursor: crosshair; }
+#tip { position: absolute; margin: 0; padding: 0.25em; background: #ffe; border: 1px solid #888; pointer-events: none; }
+</style>
+</head>
+<body>
+<form>
+{{range .Fields}}<label>{{.Name}} <select name="{{.Name}}" onchange="this.form.submit()">
+{{- $value := .Value}}{{range .Options}}
+<option value="{{.}}"{{if eq . $value}} selected{{end}}>{{if .}}{{.}}{{else}}(none){{end}}</option>
+{{- end}}
+</select></label>
+{{end}}<label>filter <input name="filter" value="{{.Filter}}" size="40"></label>
+{{range .Hidden}}<input type="hidden" name="{{.Name}}" value="{{.Value}}">
+{{end}}<input type="submit" value="Plot">
+</form>
+{{if .CanSave}}<form method="post" action="/views">
+{{range .View}}<input type="hidden" name="{{.Name}}" value="{{.Value}}">
+{{end}}<label>save view as <input name="name" size="20"></label>
+<input type="submit" value="Save">
+{{range .Views}} <a href="/view/{{.}}">{{.}}</a>{{end}}
+</form>
+{{end}}<pre id="err"{{if not .Err}} hidden{{end}}>{{.Err}}</pre>
+<img id="plot"{{if .Err}} hidden{{else}} src="{{.Image}}"{{end}}>
+<pre id="records" hidden></pre>
+<pre id="tip" hidden></pre>
+<script>
+// Replace the plot each time the data changes.
+const q = new URLSearchParams(location.search);
+q.set("version", {{.Version}});
+let map = {{.Map}}, version = {{.Version}};
+const ws = new WebSocket((location.protocol == "https:" ? "wss://" : "ws://") + location.host + "/live?" + q);
+ws.onmessage = (ev) => {
+	const m = JSON.parse(ev.data);
+	const err = document.getElementById("err"), plot = document.getElementById("plot");
+	err.textContent = m.err;
+	err.hidden = !m.err;
+	plot.hidden = !!m.err;
+	if (!m.err) {
+		plot.src = m.image;
+	}
+	map = m.map;
+	version = m.version;
+};
+
+// Find the mark nearest a mouse event on the plot, or -1 if there is none
+// within 10 pixels.
+function frac(v, lo, hi, log) {
+	if (log) {
+		[v, lo, hi] = [Math.log(v), Math.log(lo), Math.log(hi)];
+	}
+	return (v - lo) / (hi - lo);
+}
+function nearest(ev) {
+	const plot = ev.target, w = plot.clientWidth, h = plot.clientHeight;
+	let best = -1, bestDist = 10 * 10;
+	(map.marks || []).forEach((m, i) => {
+		const a = (map.areas || []).find((a) => a.Row == m.Row && a.Col == m.Col);
+		if (!a) {
+			return;
+		}
+		const x = (a.Left + (a.Right - a.Left) * frac(m.X, a.XMin, a.XMax, a.XLog)) * w;
+		const y = (a.Bottom - (a.Bottom - a.Top) * frac(m.Y, a.YMin, a.YMax, a.YLog)) * h;
+		const dist = (x - ev.offsetX) ** 2 + (y - ev.offsetY) ** 2;
+		if (dist < bestDist) {
+			best = i, bestDist = dist;
+		}
+	});
+	return best;
+}
+
+// Show the details of the mark under the mouse.
+const tip = document.getElementById("tip");
+document.getElementById("plot").onmousemove = (ev) => {
+	const best = nearest(ev);
+	tip.hidden = best < 0;
+	if (best >= 0) {
+		tip.textContent = map.marks[best].Tip;
+		tip.style.left = (ev.pageX + 12) + "px";
+		tip.style.top = (ev.pageY + 12) + "px";
+	}
+};
+document.getElementById("plot").onmouseleave = () => {
+	tip.hidden = true;
+};
+
+// Show the results of the mark that was clicked.
+document.getElementById("plot").onclick = (ev) => {
+	const best = nearest(ev);
+	const records = document.getElementById("records");
+	if (best < 0) {
+		records.hidden = true;
+		return;
+	}
+	const rq = new URLSearchParams(location.search);
+	rq.set("version", version);
+	rq.set("mark", best);
+	fetch("/records?" + rq).then((resp) => resp.text()).then((text) => {
+		records.textContent = text;
+		records.hidden = false;
+	});
+};
+</script>
+</body>
+</html>
+`))
+
+// A liveUpdate is a message sent to open pages when the data changes.
+type liveUpdate struct {
+	Image   string  `json:"image,omitempty"` // Data URL of the plot
+	Map     plotMap `json:"map"`
+	Err     string  `json:"err,omitempty"`
+	Version int     `json:"version"`
+}
+
+// A plotMap locates the marks of a rendered plot, so the UI can find the
+// mark that was clicked. Marks are in the order of layoutMarks, and a mark's
+// index in Marks identifies it in requests for its results.
+type plotMap struct {
+	Areas []plot.Area `json:"areas"`
+	Marks []mapMark   `json:"marks"`
+}
+
+// A mapMark is the position of a mark in the area of its facet, and the text
+// of its tooltip.
+type mapMark struct {
+	Row, Col int
+	X, Y     float64
+	Tip      string
+}
+
+// layoutMarks returns the marks of l in the order of its facets and series.
+func layoutMarks(l *plot.Layout) []plot.Mark {
+	var marks []plot.Mark
+	for _, f := range l.Facets {
+		for _, s := range f.Series {
+			marks = append(marks, s.Marks...)
+		}
+	}
+	return marks
+}
+
+// newPlotMap returns the map of pl, which was rendered with areas.
+func newPlotMap(pl *plot.Plot, areas []plot.Area) (plotMap, error) {
+	l, err := pl.Layout()
+	if err != nil {
+		return plotMap{}, err
+	}
+	m := plotMap{Areas: areas}
+	for _, f := range l.Facets {
+		for _, s := range f.Series {
+			for _, mk := range s.Marks {
+				x, y := mk.X, mk.Y
+				if math.IsNaN(x) || math.IsInf(x, 0) || math.IsNaN(y) || math.IsInf(y, 0) {
+					// JSON can't represent these, and
+					// gnuplot doesn't draw them.
+					x, y = 0, math.MaxFloat64
+				}
+				m.Marks = append(m.Marks, mapMark{f.Row, f.Col, x, y, markTip(f, mk)})
+			}
+		}
+	}
+	return m, nil
+}
+
+// A plotWriter writes a plot in some format. ctx is canceled if the client
+// goes away.
+type plotWriter func(ctx context.Context, pl *plot.Plot, w io.Writer) error
+
+// renderAs returns a plotWriter that renders a plot in format.
+func renderAs(format plot.Format) plotWriter {
+	return func(ctx context.Context, pl *plot.Plot, w io.Writer) error {
+		return pl.RenderContext(ctx, plot.RenderOptions{Format: format}, w)
+	}
+}
+
+// writeCSV writes the points of a plot as CSV, as described by layoutCSV.
+func writeCSV(ctx context.Context, pl *plot.Plot, w io.Writer) error {
+	return pl.RenderWith(layoutCSV{}, w)
+}
+
+// writeMetrics writes the points of a plot as Prometheus metrics, as
+// described by prometheusMetrics.
+func writeMetrics(ctx context.Context, pl *plot.Plot, w io.Writer) error {
+	return pl.RenderWith(prometheusMetrics{}, w)
+}
+
+// write writes the plot for view v to w using pw. The caller must hold
+// s.data.mu.
+func (s *server) write(ctx context.Context, v view, pw plotWriter, w io.Writer) error {
+	pl, err := s.newPlot(v)
+	if err != nil {
+		return err
+	}
+	return pw(ctx, pl, w)
+}
+
+// markTip returns the tooltip of mark mk in facet f. It lists every projected
+// field and the values each residue field took, since the plot doesn't show
+// the residue, and the plotted value and the measurements summarized into it.
+func markTip(f plot.Facet, mk plot.Mark) string {
+	var b strings.Builder
+	fields := mk.Point.Fields()
+	for _, name := range sortedKeys(fields) {
+		fmt.Fprintf(&b, "%s: %s\n", name, fields[name])
+	}
+	residue := mk.Point.Residue()
+	for _, name := range sortedKeys(residue) {
+		fmt.Fprintf(&b, "residue %s: %s\n", name, strings.Join(residue[name], ", "))
+	}
+	fmt.Fprintf(&b, "%s: %.4g", f.Y.Label, mk.Y)
+	if !math.IsNaN(mk.Lo) {
+		fmt.Fprintf(&b, " [%.4g, %.4g]", mk.Lo, mk.Hi)
+	}
+	b.WriteByte('\n')
+	if len(mk.Samples) > 0 {
+		b.WriteString("samples:")
+		for _, v := range mk.Samples {
+			fmt.Fprintf(&b, " %.4g", v)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// image renders the plot for view v as a PNG data URL, and returns its map.
+// The caller must hold s.data.mu.
+func (s *server) image(ctx context.Context, v view) (template.URL, plotMap, error) {
+	pl, err := s.newPlot(v)
+	if err != nil {
+		return "", plotMap{}, err
+	}
+	var buf bytes.Buffer
+	areas, err := pl.RenderAreas(ctx, plot.RenderOptions{Format: plot.FormatPNG}, &buf)
+	if err != nil {
+		return "", plotMap{}, err
+	}
+	m, err := newPlotMap(pl, areas)
+	if err != nil {
+		return "", plotMap{}, err
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), m, nil
+}
+
+func (s *server) servePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	transforms := append([]string{""}, plot.TransformNames()...)
+	v, err := s.view(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.data.mu.Lock()
+	recs, _ := s.data.results()
+	keys := serveKeys(recs)
+	page := servePage{Filter: v["filter"], Version: s.data.version, CanSave: s.views != nil}
+	img, m, err := s.image(r.Context(), v)
+	s.data.mu.Unlock()
+
+	for _, f := range aesFlags {
+		page.Fields = append(page.Fields, field(v, f.aes.Name(), keys))
+	}
+	page.Fields = append(page.Fields, field(v, "transform", transforms))
+	for _, name := range urlFlags {
+		val, ok := v[name]
+		if !ok {
+			continue
+		}
+		page.View = append(page.View, serveField{Name: name, Value: val})
+		if !slices.Contains(viewFlags, name) {
+			page.Hidden = append(page.Hidden, serveField{Name: name, Value: val})
+		}
+	}
+	if s.views != nil {
+		page.Views = s.views.names()
+	}
+	if err != nil {
+		page.Err = err.Error()
+	} else {
+		page.Image, page.Map = img, m
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	serveTemplate.Execute(w, page)
+}
+
+// serveLive pushes the plot for the view in the request to the client each
+// time the data changes from the version in the request, until the client
+// goes away.
+func (s *server) serveLive(ws *websocket.Conn) {
+	r := ws.Request()
+	v, err := s.view(r.URL.Query(), "version")
+	if err != nil {
+		websocket.JSON.Send(ws, liveUpdate{Err: err.Error()})
+		return
+	}
+	version, _ := strconv.Atoi(r.URL.Query().Get("version"))
+
+	// The client doesn't send anything, so reading only ends when it
+	// goes away.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		io.Copy(io.Discard, ws)
+		cancel()
+	}()
+
+	for {
+		s.data.mu.Lock()
+		changed := s.data.changed
+		if s.data.version == version {
+			s.data.mu.Unlock()
+			select {
+			case <-changed:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		version = s.data.version
+		img, m, err := s.image(ctx, v)
+		s.data.mu.Unlock()
+
+		msg := liveUpdate{Image: string(img), Map: m, Version: version}
+		if err != nil {
+			msg.Err = err.Error()
+		}
+		if err := websocket.JSON.Send(ws, msg); err != nil {
+			return
+		}
+	}
+}
+
+// serveFile returns a handler that serves the plot for the view in the
+// request written by pw, for embedding plots by URL or fetching them from
+// scripts.
+func (s *server) serveFile(contentType string, pw plotWriter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v, err := s.view(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Write to a buffer so errors can still be reported as such.
+		var buf bytes.Buffer
+		s.data.mu.Lock()
+		err = s.write(r.Context(), v, pw, &buf)
+		s.data.mu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		// The plot changes with the data.
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(buf.Bytes())
+	}
+}
+
+// servePost adds the results in the body of a POST request to the plot.
+func (s *server) servePost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	recs, units, err := s.read(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.data.post(recs, units)
+	fmt.Fprintf(w, "added %d results\n", len(recs))
+}
+
+// serveSave saves the view in a POSTed form under the name in the form, and
+// redirects to the saved view.
+func (s *server) serveSave(w http.ResponseWriter, r *http.Request) {
+	if s.views == nil {
+		http.Error(w, "saving views requires -views", http.StatusNotFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	v, err := s.view(r.PostForm, "name")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := r.PostForm.Get("name")
+	if err := s.views.save(name, v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/view/"+url.PathEscape(name), http.StatusSeeOther)
+}
+
+// serveSaved redirects to the UI for the saved view named in the path, so
+// the path is a permanent link to the view.
+func (s *server) serveSaved(w http.ResponseWriter, r *http.Request) {
+	var v view
+	if s.views != nil {
+		v = s.views.get(r.PathValue("name"))
+	}
+	if v == nil {
+		http.Error(w, fmt.Sprintf("no saved view %q", r.PathValue("name")), http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, "/?"+v.query().Encode(), http.StatusFound)
+}
+
+// serveRecords lists the results summarized by a mark of the plot for the
+// view in the request, with their positions in the inputs and their full
+// configuration. The "mark" parameter gives the index of the mark in the
+// plotMap, and "version" gives the version of the data the map was made
+// from, since the marks change with the data.
+func (s *server) serveRecords(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	v, err := s.view(q, "version", "mark")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	idx, err := strconv.Atoi(q.Get("mark"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("bad mark %q", q.Get("mark")), http.StatusBadRequest)
+		return
+	}
+
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	if q.Get("version") != strconv.Itoa(s.data.version) {
+		http.Error(w, "the data changed since the plot was drawn", http.StatusConflict)
+		return
+	}
+	pl, err := s.newPlot(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	l, err := pl.Layout()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	marks := layoutMarks(l)
+	if idx < 0 || idx >= len(marks) {
+		http.Error(w, fmt.Sprintf("no mark %d", idx), http.StatusNotFound)
+		return
+	}
+	recs, _ := s.data.results()
+	recs, err = markRecords(recs, v["filter"], marks[idx].Point)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d results\n", len(recs))
+	for _, rec := range recs {
+		buf.WriteByte('\n')
+		if file, line := rec.Pos(); file != "" {
+			fmt.Fprintf(&buf, "%s:%d\n", file, line)
+		}
+		for _, cfg := range rec.Config {
+			fmt.Fprintf(&buf, "%s: %s\n", cfg.Key, cfg.Value)
+		}
+		// Write just the benchmark line. The configuration
+		// above includes the keys the Writer omits.
+		benchfmt.NewWriter(&buf).Write(&benchfmt.Result{Name: rec.Name, Iters: rec.Iters, Values: rec.Values})
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// markRecords returns the results in recs that match the filter query and
+// that pt summarizes, as they are in recs.
+func markRecords(recs []*benchfmt.Result, query string, pt plot.Point) ([]*benchfmt.Result, error) {
+	var filter *benchproc.Filter
+	if query != "" && query != "*" {
+		var err error
+		filter, err = benchproc.NewFilter(query)
+		if err != nil {
+			return nil, fmt.Errorf("parsing filter: %s", err)
+		}
+	}
+	var out []*benchfmt.Result
+	for _, rec := range recs {
+		// Match rec with its values filtered like the plotted
+		// results, but return it whole.
+		filtered := rec
+		if filter != nil {
+			// Apply modifies rec.
+			filtered = rec.Clone()
+			if ok, _ := filter.Apply(filtered); !ok {
+				continue
+			}
+		}
+		if pt.Matches(filtered) {
+			out = append(out, rec)
+		}
+	}
+	return out, nil
+}
+
+// serve serves the UI over HTTP on addr. It also serves the plot for the view
+// given by the query parameters as /plot.png and /plot.svg, and its points as
+// /export.csv and as Prometheus metrics at /metrics, and the results of a
+// point at /records. It accepts results in the Go benchmark format POSTed to
+// /results, and serves each saved view at /view/name. serve announces the
+// server's URL to wInfo and only returns if serving fails.
+func (s *server) serve(addr string, wInfo io.Writer) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.servePage)
+	mux.Handle("/live", websocket.Handler(s.serveLive))
+	mux.HandleFunc("/plot.png", s.serveFile("image/png", renderAs(plot.FormatPNG)))
+	mux.HandleFunc("/plot.svg", s.serveFile("image/svg+xml", renderAs(plot.FormatSVG)))
+	mux.HandleFunc("/export.csv", s.serveFile("text/csv; charset=utf-8", writeCSV))
+	mux.HandleFunc("/metrics", s.serveFile("text/plain; version=0.0.4; charset=utf-8", writeMetrics))
+	mux.HandleFunc("/records", s.serveRecords)
+	mux.HandleFunc("/results", s.servePost)
+	mux.HandleFunc("POST /views", s.serveSave)
+	mux.HandleFunc("GET /view/{name}", s.serveSaved)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(wInfo, "serving on http://%s/\n", ln.Addr())
+	return http.Serve(ln, mux)
+}
+
+// filterRecords returns the results in recs that match the filter query, with
+// their values filtered down. It doesn't modify recs.
+func filterRecords(recs []*benchfmt.Result, query string) ([]*benchfmt.Result, error) {
+	if query == "" || query == "*" {
+		return recs, nil
+	}
+	filter, err := benchproc.NewFilter(query)
+	if err != nil {
+		return nil, fmt.Errorf("parsing filter: %s", err)
+	}
+	var out []*benchfmt.Result
+	for _, rec := range recs {
+		// Apply modifies rec.
+		rec = rec.Clone()
+		if ok, _ := filter.Apply(rec); ok {
+			out = append(out, rec)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("all data filtered")
+	}
+	return out, nil
+}
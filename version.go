@@ -0,0 +1,59 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+
+	"github.com/aclements/benchplot/plot"
+)
+
+// printVersion prints the version of benchplot and of the gnuplot it will use.
+// gnuplot is the -gnuplot flag.
+func printVersion(w io.Writer, gnuplot string) error {
+	version, revision, modified := "(unknown)", "", false
+	goVersion := ""
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		version = bi.Main.Version
+		goVersion = bi.GoVersion
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				revision = s.Value
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+	}
+	fmt.Fprintf(w, "benchplot %s", version)
+	if revision != "" {
+		fmt.Fprintf(w, " (revision %s", revision)
+		if modified {
+			fmt.Fprintf(w, ", modified")
+		}
+		fmt.Fprintf(w, ")")
+	}
+	if goVersion != "" {
+		fmt.Fprintf(w, " built with %s", goVersion)
+	}
+	fmt.Fprintf(w, "\n")
+
+	bin, err := plot.FindGnuplot(gnuplot)
+	if err != nil {
+		fmt.Fprintf(w, "%s\n", err)
+		return nil
+	}
+	out, err := exec.Command(bin, "--version").Output()
+	if err != nil {
+		fmt.Fprintf(w, "gnuplot: %s: %s\n", bin, err)
+	} else {
+		fmt.Fprintf(w, "gnuplot: %s (%s)\n", strings.TrimSpace(string(out)), bin)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// savedViews is the set of named views saved in the serve UI. They are stored
+// in a YAML file mapping each name to a mapping from flag names to values,
+// like the plot specifications read by -c.
+type savedViews struct {
+	path string
+
+	mu    sync.Mutex
+	views map[string]view
+}
+
+// loadViews reads the saved views from the file at path. If the file doesn't
+// exist, there are no saved views yet.
+func loadViews(path string) (*savedViews, error) {
+	sv := &savedViews{path: path, views: make(map[string]view)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return sv, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &sv.views); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if sv.views == nil {
+		// The file was empty.
+		sv.views = make(map[string]view)
+	}
+	return sv, nil
+}
+
+// get returns the view saved as name, or nil if there is none.
+func (sv *savedViews) get(name string) view {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	return sv.views[name]
+}
+
+// names returns the names of the saved views in sorted order.
+func (sv *savedViews) names() []string {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	return sortedKeys(sv.views)
+}
+
+// save saves v as name, replacing any view already saved as name, and writes
+// the views back to the file.
+func (sv *savedViews) save(name string, v view) error {
+	if name == "" || strings.ContainsAny(name, "/?#") {
+		return fmt.Errorf("bad view name %q: must be non-empty and not contain /, ?, or #", name)
+	}
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	old, had := sv.views[name]
+	sv.views[name] = v
+	if err := sv.write(); err != nil {
+		if had {
+			sv.views[name] = old
+		} else {
+			delete(sv.views, name)
+		}
+		return err
+	}
+	return nil
+}
+
+// write writes the views to sv.path. It writes a new file and renames it over
+// the old one, so a failed write doesn't lose the views saved before.
+func (sv *savedViews) write() error {
+	data, err := yaml.Marshal(sv.views)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(sv.path), filepath.Base(sv.path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err2 := tmp.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), sv.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
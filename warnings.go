@@ -0,0 +1,71 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A warning is a problem with the data that doesn't stop benchplot, such as
+// records that didn't match -filter.
+type warning struct {
+	// Kind is one of "syntax" or "filter" for a problem with a single
+	// record, "filtered", "unit-filtered", or "duplicates" for records
+	// that were dropped, "unknown-key" or "unknown-unit" for flags that
+	// name keys or units not in the inputs, "unknown-commit" for records
+	// whose -git-commits key isn't in the git history, or "residue" or
+	// "sample" for problems with the measurements summarized into a
+	// single point.
+	Kind  string            `json:"kind"`
+	Msg   string            `json:"msg"`
+	File  string            `json:"file,omitempty"`
+	Line  int               `json:"line,omitempty"`
+	Count int               `json:"count,omitempty"`
+	Point map[string]string `json:"point,omitempty"`
+}
+
+func (w warning) String() string {
+	if w.File != "" {
+		return fmt.Sprintf("%s:%d: %s", w.File, w.Line, w.Msg)
+	}
+	return w.Msg
+}
+
+// A warner reports warnings to w as text, or, if json is set, as JSON objects,
+// one per line, so automation can react to them without scraping messages.
+type warner struct {
+	w    io.Writer
+	json bool
+}
+
+func (wr *warner) warn(w warning) {
+	if !wr.json {
+		fmt.Fprintln(wr.w, w)
+		return
+	}
+	data, err := json.Marshal(w)
+	if err != nil {
+		panic(err) // warnings are always representable
+	}
+	wr.w.Write(append(data, '\n'))
+}
+
+// openWarnings opens the -warnings-file destination, which is a file path or
+// "fd:N" for an open file descriptor.
+func openWarnings(path string) (*os.File, error) {
+	if fd, ok := strings.CutPrefix(path, "fd:"); ok {
+		n, err := strconv.ParseUint(fd, 10, 0)
+		if err != nil {
+			return nil, fmt.Errorf("bad -warnings-file %q: expected path or fd:N", path)
+		}
+		return os.NewFile(uintptr(n), path), nil
+	}
+	return os.Create(path)
+}
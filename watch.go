@@ -0,0 +1,112 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/aclements/benchplot/internal/input"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay is how long to wait after an input changes before re-rendering.
+// Tools that write benchmark output often do so in many small writes, so this
+// coalesces bursts of changes into a single render.
+const watchDelay = 250 * time.Millisecond
+
+// watch calls render once, and then again each time one of the local files
+// read by inputs changes. It only returns if watching fails. Errors from render
+// are reported to wErr, but do not stop watching. Each successful render is
+// reported to wInfo.
+func watch(inputs *input.Files, render func() error, wErr, wInfo io.Writer) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("starting watcher: %w", err)
+	}
+	defer w.Close()
+
+	// Watch the directory containing each file, rather than the file itself.
+	// Many editors and tools replace files by renaming over them, which
+	// would otherwise silently end the watch. Directory and glob inputs
+	// are watched in every directory that could contain a matching file,
+	// and expanded again when anything is created, to pick up new files.
+	files := make(map[string]bool)
+	watching := make(map[string]bool)
+	// update expands inputs and reports whether there are new files.
+	update := func() (bool, error) {
+		paths, dirs, err := inputs.Watch()
+		if err != nil {
+			return false, err
+		}
+		added := false
+		old := files
+		files = make(map[string]bool)
+		for _, path := range paths {
+			path = filepath.Clean(path)
+			files[path] = true
+			added = added || !old[path]
+		}
+		for _, dir := range dirs {
+			dir = filepath.Clean(dir)
+			if watching[dir] {
+				continue
+			}
+			if err := w.Add(dir); err != nil {
+				return false, fmt.Errorf("watching %s: %w", dir, err)
+			}
+			watching[dir] = true
+		}
+		return added, nil
+	}
+	if _, err := update(); err != nil {
+		return err
+	}
+
+	doRender := func() {
+		if err := render(); err != nil {
+			fmt.Fprintf(wErr, "%s\n", err)
+			return
+		}
+		fmt.Fprintf(wInfo, "rendered at %s\n", time.Now().Format(time.TimeOnly))
+	}
+	doRender()
+
+	var timer <-chan time.Time
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			name := filepath.Clean(ev.Name)
+			if ev.Has(fsnotify.Remove | fsnotify.Rename) {
+				// The watch of a removed directory ends.
+				delete(watching, name)
+			}
+			added := false
+			if ev.Has(fsnotify.Create) {
+				// The new file or directory may match an input.
+				if added, err = update(); err != nil {
+					fmt.Fprintf(wErr, "%s\n", err)
+				}
+			}
+			if !added && (!files[name] || !ev.Has(fsnotify.Write|fsnotify.Create)) {
+				continue
+			}
+			timer = time.After(watchDelay)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("watching inputs: %w", err)
+		case <-timer:
+			timer = nil
+			doRender()
+		}
+	}
+}